@@ -1,82 +1,292 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 
+	"concurrent-wallet-order-system/internal/auth"
 	"concurrent-wallet-order-system/internal/config"
 	"concurrent-wallet-order-system/internal/handlers"
+	"concurrent-wallet-order-system/internal/health"
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/mail"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/notify"
+	"concurrent-wallet-order-system/internal/ratelimit"
 	"concurrent-wallet-order-system/internal/repo"
+	"concurrent-wallet-order-system/internal/router"
 	"concurrent-wallet-order-system/internal/services"
+	"concurrent-wallet-order-system/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 
+	// =============================
+	// Load Configuration
+	// =============================
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	logger := logging.New(os.Stdout, cfg.Log.SlogLevel(), cfg.Log.Format)
+	slog.SetDefault(logger)
+
+	// =============================
+	// Tracing
+	// =============================
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Error("tracing setup failed", "error", err)
+		os.Exit(1)
+	}
+
 	// =============================
 	// Connect to MongoDB
 	// =============================
-	mongoURI := "mongodb://localhost:27017"
-	dbName := "wallet_order_system"
+	client, err := config.ConnectMongo(cfg.Mongo, logger)
+	if err != nil {
+		logger.Error("MongoDB connection failed", "error", err)
+		os.Exit(1)
+	}
 
-	config.ConnectMongo(mongoURI, dbName)
-	config.CreateIndexes()
+	db := client.Database(cfg.Mongo.Database)
+	if err := config.CreateIndexes(context.Background(), db, logger); err != nil {
+		logger.Error("index creation failed", "error", err)
+	}
 
 
 	// Repositories
-	userRepo := repo.NewUserRepository()
-	walletRepo := repo.NewWalletRepository()
-	stockRepo := repo.NewStockRepository()
-	orderRepo := repo.NewOrderRepository()
-	portfolioRepo := repo.NewPortfolioRepository()
+	userRepo := repo.NewMongoUserRepository(db, cfg.Mongo.OperationTimeout, logger)
+	walletRepo := repo.NewMongoWalletRepository(db, cfg.Mongo.OperationTimeout, logger)
+	stockRepo := repo.NewMongoStockRepository(db, cfg.Mongo.OperationTimeout, logger)
+	orderRepo := repo.NewMongoOrderRepository(db, cfg.Mongo.OperationTimeout, logger)
+	portfolioRepo := repo.NewMongoPortfolioRepository(db, cfg.Mongo.OperationTimeout, logger)
+	watchlistRepo := repo.NewMongoWatchlistRepository(db, cfg.Mongo.OperationTimeout, logger)
+	alertRepo := repo.NewMongoAlertRepository(db, cfg.Mongo.OperationTimeout, logger)
+	notificationRepo := repo.NewMongoNotificationRepository(db, cfg.Mongo.OperationTimeout, logger)
+	loginAttemptRepo := repo.NewMongoLoginAttemptRepository(db, cfg.Mongo.OperationTimeout, logger)
+	securityEventRepo := repo.NewMongoSecurityEventRepository(db, cfg.Mongo.OperationTimeout, logger)
+	sessionRepo := repo.NewMongoSessionRepository(db, cfg.Mongo.OperationTimeout, logger)
+	userTokenRepo := repo.NewMongoUserTokenRepository(db, cfg.Mongo.OperationTimeout, logger)
+	limitUsageRepo := repo.NewMongoLimitUsageRepository(db, cfg.Mongo.OperationTimeout, logger)
+	auditRepo := repo.NewMongoAuditRepository(db, cfg.Mongo.OperationTimeout, logger)
 
 	// Services
-	userService := services.NewUserService(userRepo)
-	walletService := services.NewWalletService(userRepo, walletRepo)
-	stockService := services.NewStockService(stockRepo)
+	auditService := services.NewAuditService(auditRepo, logger)
+	securityService := services.NewSecurityService(loginAttemptRepo, securityEventRepo, services.LoginPolicy{
+		MaxFailures:   cfg.Login.MaxFailures,
+		MaxIPFailures: cfg.Login.MaxIPFailures,
+		Window:        cfg.Login.FailureWindow,
+		Lockout:       cfg.Login.LockoutDuration,
+		BaseDelay:     cfg.Login.BaseDelay,
+		MaxDelay:      cfg.Login.MaxDelay,
+	}, logger)
+	userService := services.NewUserService(userRepo, securityService, auditService, logger)
+
+	// Access tokens: without a configured secret, tokens die with the process
+	tokenSecret := cfg.Auth.TokenSecret
+	if tokenSecret == "" {
+		logger.Warn("AUTH_TOKEN_SECRET is not set; using a random secret, so access tokens will not survive a restart or work across replicas")
+		tokenSecret = rand.Text() + rand.Text()
+	}
+	signer := auth.NewSigner([]byte(tokenSecret), cfg.Tracing.ServiceName, cfg.Auth.AccessTokenTTL)
+	sessionService := services.NewSessionService(sessionRepo, signer, securityService, cfg.Auth.RefreshTokenTTL, logger)
+	challengeSigner := auth.NewSigner([]byte(tokenSecret), cfg.Tracing.ServiceName+"/2fa", cfg.TwoFactor.ChallengeTTL)
+	twoFactorService := services.NewTwoFactorService(userRepo, securityService, challengeSigner, cfg.TwoFactor.Issuer, logger)
+	kycService := services.NewKYCService(userRepo, limitUsageRepo, []services.TierLimits{
+		models.KYCTierNone:  services.TierLimits(cfg.KYC.Unverified),
+		models.KYCTierBasic: services.TierLimits(cfg.KYC.Basic),
+		models.KYCTierFull:  services.TierLimits(cfg.KYC.Full),
+	}, logger)
+	walletService := services.NewWalletService(userRepo, walletRepo, twoFactorService, kycService, auditService, cfg.TwoFactor.StepUpAmount, logger)
+
+	stockService := services.NewStockService(stockRepo, auditService, logger)
 	orderService := services.NewOrderService(
 		orderRepo,
 		portfolioRepo,
 		walletService,
 		stockService,
+		kycService,
+		auditService,
+		logger,
 	)
-	portfolioService := services.NewPortfolioService(portfolioRepo, stockService)
+	// Account email: verification and password reset links
+	var mailSender mail.Sender = mail.NewConsoleSender(cfg.Mail.From, os.Stdout)
+	if cfg.Mail.Sender == "file" {
+		mailSender = mail.NewFileSender(cfg.Mail.From, cfg.Mail.Dir)
+	}
+	accountService := services.NewAccountService(
+		userRepo,
+		userTokenRepo,
+		portfolioRepo,
+		sessionService,
+		securityService,
+		walletService,
+		orderService,
+		mailSender,
+		auth.NewSigner([]byte(tokenSecret), cfg.Tracing.ServiceName+"/verify-email", cfg.Auth.EmailVerificationTTL),
+		auth.NewSigner([]byte(tokenSecret), cfg.Tracing.ServiceName+"/reset-password", cfg.Auth.PasswordResetTTL),
+		cfg.Mail.LinkBaseURL,
+		logger,
+	)
+	portfolioService := services.NewPortfolioService(userRepo, portfolioRepo, stockService, logger)
+	watchlistService := services.NewWatchlistService(userRepo, watchlistRepo, stockService, logger)
+	alertService := services.NewAlertService(userRepo, alertRepo, notificationRepo, stockService, logger)
+
+	// Price alerts: in-app delivery always, plus optional local file / email stubs
+	notifiers := notify.Multi{notify.NewInAppNotifier(notificationRepo)}
+	if cfg.Notify.File != "" {
+		notifiers = append(notifiers, notify.NewFileNotifier(cfg.Notify.File))
+	}
+	if cfg.Notify.SMTPStubDir != "" {
+		notifiers = append(notifiers, notify.NewSMTPStubNotifier(cfg.Notify.SMTPStubFrom, cfg.Notify.SMTPStubDir))
+	}
+
+	alertEvaluator := services.NewAlertEvaluator(alertRepo, userRepo, notifiers, logger)
+	stockService.OnPriceChange(alertEvaluator.Enqueue)
+	alertEvaluator.Start()
 
 
-	// Handlers
-	userHandler := handlers.NewUserHandler(userService)
-	walletHandler := handlers.NewWalletHandler(walletService)
-	stockHandler := handlers.NewStockHandler(stockService)
-	orderHandler := handlers.NewOrderHandler(orderService)
-	portfolioHandler := handlers.NewPortfolioHandler(portfolioService)
+	// Health: liveness only covers this process, readiness also its dependencies
+	workerCheck := func(status func() services.WorkerStatus) health.Check {
+		return func(ctx context.Context) (any, error) {
+			s := status()
+			if !s.Running {
+				return s, errors.New("worker is not running")
+			}
+			return s, nil
+		}
+	}
 
+	liveness := health.NewChecker(health.DefaultTimeout).
+		Add("alertEvaluator", workerCheck(alertEvaluator.Status))
+
+	readiness := health.NewChecker(health.DefaultTimeout).
+		Add("mongo", func(ctx context.Context) (any, error) {
+			return nil, client.Ping(ctx, nil)
+		}).
+		Add("indexes", func(ctx context.Context) (any, error) {
+			missing, err := config.MissingIndexes(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			if len(missing) > 0 {
+				return gin.H{"missing": missing}, errors.New("expected indexes are missing")
+			}
+			return nil, nil
+		}).
+		Add("alertEvaluator", workerCheck(alertEvaluator.Status))
+
+
+	// Rate limits: per replica in memory, or shared by all replicas in MongoDB
+	var limitStore ratelimit.Store
+	if cfg.RateLimit.Enabled {
+		if cfg.RateLimit.Store == "mongo" {
+			limitStore = ratelimit.NewMongoStore(db, cfg.Mongo.OperationTimeout, logger)
+		} else {
+			limitStore = ratelimit.NewMemoryStore()
+		}
+	}
 
 	// =============================
 	// Setup Router
 	// =============================
-	router := gin.Default()
-
-	// User Routes
-	router.POST("/register", userHandler.Register)
-	router.POST("/login", userHandler.Login)
-
-	// Wallet Routes
-	router.POST("/wallet/deposit", walletHandler.Deposit)
-	router.POST("/wallet/withdraw", walletHandler.Withdraw)
-	router.GET("/wallet/balance/:userId", walletHandler.GetBalance)
-	router.GET("/wallet/history/:userId", walletHandler.GetHistory)
-	router.GET("/users", userHandler.GetAllUsers)
-	router.GET("/users/:userId", userHandler.GetUser)
-	router.POST("/stocks", stockHandler.CreateStock)
-	router.GET("/stocks", stockHandler.GetAllStocks)
-	router.GET("/stocks/:symbol", stockHandler.GetStock)
-	router.POST("/orders/buy", orderHandler.Buy)
-	router.POST("/orders/sell", orderHandler.Sell)
-	router.GET("/portfolio/:userId", portfolioHandler.GetPortfolio)
+	engine := router.New(cfg, limitStore, signer, logger, router.Handlers{
+		User:      handlers.NewUserHandler(userService, sessionService, twoFactorService, accountService, logger),
+		Wallet:    handlers.NewWalletHandler(walletService, logger),
+		Stock:     handlers.NewStockHandler(stockService, orderService, logger),
+		Order:     handlers.NewOrderHandler(orderService, logger),
+		Portfolio: handlers.NewPortfolioHandler(portfolioService, logger),
+		Watchlist: handlers.NewWatchlistHandler(watchlistService, logger),
+		Alert:     handlers.NewAlertHandler(alertService, logger),
+		Session:   handlers.NewSessionHandler(sessionService, logger),
+		TwoFactor: handlers.NewTwoFactorHandler(twoFactorService, logger),
+		Account:   handlers.NewAccountHandler(accountService, logger),
+		KYC:       handlers.NewKYCHandler(kycService, logger),
+		Security:  handlers.NewSecurityHandler(securityService, logger),
+		Audit:     handlers.NewAuditHandler(auditService, logger),
+		Health:    handlers.NewHealthHandler(liveness, readiness),
+		Docs:      handlers.NewDocsHandler(router.OpenAPISpec(cfg.API)),
+	})
 
 	// =============================
 	//  Start Server
 	// =============================
-	log.Println("Server running on port 8080")
-	router.Run(":8080")
+	srv := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.HTTP.Port),
+		Handler:           engine,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if cfg.HTTP.TLS.CertFile != "" {
+			logger.Info("server running", "port", cfg.HTTP.Port, "tls", true)
+			serverErr <- srv.ListenAndServeTLS(cfg.HTTP.TLS.CertFile, cfg.HTTP.TLS.KeyFile)
+		} else {
+			logger.Info("server running", "port", cfg.HTTP.Port, "tls", false)
+			serverErr <- srv.ListenAndServe()
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown signal received, draining in-flight requests")
+	case err := <-serverErr:
+		logger.Error("server stopped", "error", err)
+		exitCode = 1
+	}
+	stop()
+
+	// =============================
+	//  Shutdown
+	// =============================
+	// Stop accepting connections and let in-flight requests (e.g. an order
+	// between the wallet debit and the portfolio update) finish first, then
+	// stop the workers that may still write to MongoDB, then disconnect.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("HTTP shutdown did not complete", "error", err)
+		exitCode = 1
+	}
+	cancel()
+
+	alertEvaluator.Stop()
+	logger.Info("background workers stopped")
+
+	disconnectCtx, cancel := context.WithTimeout(context.Background(), cfg.Mongo.ConnectTimeout)
+	if err := client.Disconnect(disconnectCtx); err != nil {
+		logger.Error("MongoDB disconnect failed", "error", err)
+		exitCode = 1
+	} else {
+		logger.Info("MongoDB disconnected")
+	}
+	cancel()
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("flushing traces failed", "error", err)
+		exitCode = 1
+	}
+	cancel()
+
+	os.Exit(exitCode)
 }
@@ -0,0 +1,114 @@
+// Command stockctl bulk-imports and exports stocks directly against MongoDB.
+//
+//	go run ./cmd/stockctl import -file stocks.csv
+//	go run ./cmd/stockctl export -format json > stocks.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"concurrent-wallet-order-system/internal/config"
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/repo"
+	"concurrent-wallet-order-system/internal/services"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	mongoURI := flags.String("mongo-uri", "", "MongoDB connection URI (overrides MONGO_URI)")
+	dbName := flags.String("db", "", "database name (overrides MONGO_DATABASE)")
+	file := flags.String("file", "", "file to import (import only)")
+	format := flags.String("format", "", "csv or json (import defaults to the file extension, export to json)")
+	flags.Parse(os.Args[2:])
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if *mongoURI != "" {
+		cfg.Mongo.URI = *mongoURI
+	}
+	if *dbName != "" {
+		cfg.Mongo.Database = *dbName
+	}
+
+	// Logs go to stderr so an export on stdout stays clean
+	logger := logging.New(os.Stderr, cfg.Log.SlogLevel(), cfg.Log.Format)
+
+	client, err := config.ConnectMongo(cfg.Mongo, logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	db := client.Database(cfg.Mongo.Database)
+	// Imports are audited, as by the admin API, with the system as the actor
+	auditService := services.NewAuditService(repo.NewMongoAuditRepository(db, cfg.Mongo.OperationTimeout, logger), logger)
+	stockService := services.NewStockService(repo.NewMongoStockRepository(db, cfg.Mongo.OperationTimeout, logger), auditService, logger)
+
+	// Ctrl-C cancels the running import or export
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch command {
+	case "import":
+		runImport(ctx, stockService, *file, *format)
+	case "export":
+		if *format == "" {
+			*format = services.StockFormatJSON
+		}
+		if err := stockService.ExportStocks(ctx, os.Stdout, *format); err != nil {
+			log.Fatal("Export failed: ", err)
+		}
+	default:
+		usage()
+	}
+}
+
+func runImport(ctx context.Context, stockService *services.StockService, file, format string) {
+	if file == "" {
+		log.Fatal("import requires -file")
+	}
+
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), ".")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		log.Fatal("Cannot open import file: ", err)
+	}
+	defer f.Close()
+
+	result, err := stockService.ImportStocksFrom(ctx, f, format)
+	if err != nil {
+		log.Fatal("Import failed: ", err)
+	}
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: stockctl import -file <path> [-format csv|json] [-mongo-uri uri] [-db name]")
+	fmt.Fprintln(os.Stderr, "       stockctl export [-format csv|json] [-mongo-uri uri] [-db name]")
+	os.Exit(2)
+}
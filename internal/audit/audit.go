@@ -0,0 +1,61 @@
+// Package audit carries who is behind a request, for the audit log. The
+// middleware records the client and, once authenticated, the actor; the
+// services read them back when they record a change.
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// Actor kinds
+const (
+	ActorUser      = "user"      // a user with an access token; ID is theirs
+	ActorAdmin     = "admin"     // a holder of the admin API key
+	ActorAnonymous = "anonymous" // a request that was not authenticated
+	ActorSystem    = "system"    // background work and tools, outside any request
+)
+
+// Actor is who made a change
+type Actor struct {
+	Kind string
+	ID   string
+}
+
+type ctxKey struct{}
+
+// requestInfo is shared by everything handling one request. The actor is
+// only known once authentication has run, so it is filled in later.
+type requestInfo struct {
+	mu       sync.Mutex
+	clientIP string
+	actor    Actor
+}
+
+// WithClient starts the audit context of a request from clientIP
+func WithClient(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &requestInfo{
+		clientIP: clientIP,
+		actor:    Actor{Kind: ActorAnonymous},
+	})
+}
+
+// SetActor records who a request acts as. It is a no-op outside a request.
+func SetActor(ctx context.Context, actor Actor) {
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		info.mu.Lock()
+		info.actor = actor
+		info.mu.Unlock()
+	}
+}
+
+// From returns the actor and client IP of the request ctx belongs to.
+// Outside a request the actor is the system.
+func From(ctx context.Context) (actor Actor, clientIP string) {
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		info.mu.Lock()
+		defer info.mu.Unlock()
+		return info.actor, info.clientIP
+	}
+	return Actor{Kind: ActorSystem}, ""
+}
@@ -0,0 +1,118 @@
+// Package auth issues and verifies the short-lived access tokens that
+// authenticate API requests, and the TOTP codes of two-factor
+// authentication. Access tokens are JWTs signed with HMAC-SHA256, so any
+// replica holding the secret can verify them without a database lookup.
+// The same signer, with issuers of their own, makes the tokens of login
+// challenges and of the links mailed for email verification and password
+// resets.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid access token")
+	ErrTokenExpired = errors.New("access token has expired")
+)
+
+// Claims identify the user and session an access token was issued to
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"` // user ID
+	SessionID string `json:"sid,omitempty"`
+	ID        string `json:"jti,omitempty"` // set by IssueWithID
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// header is fixed: Verify rejects any other algorithm, including "none"
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Signer issues and verifies access tokens
+type Signer struct {
+	secret []byte
+	issuer string
+	ttl    time.Duration
+}
+
+func NewSigner(secret []byte, issuer string, ttl time.Duration) *Signer {
+	return &Signer{secret: secret, issuer: issuer, ttl: ttl}
+}
+
+// TTL is how long an access token stays valid
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue returns a token for the user's session, valid for TTL from now
+func (s *Signer) Issue(userID, sessionID string, now time.Time) (string, time.Time, error) {
+	return s.issue(Claims{Subject: userID, SessionID: sessionID}, now)
+}
+
+// IssueWithID returns a token for the user that carries id, for tokens the
+// caller keeps a record of, e.g. to accept each one only once
+func (s *Signer) IssueWithID(userID, id string, now time.Time) (string, time.Time, error) {
+	return s.issue(Claims{Subject: userID, ID: id}, now)
+}
+
+func (s *Signer) issue(claims Claims, now time.Time) (string, time.Time, error) {
+	expires := now.Add(s.ttl)
+	claims.Issuer = s.issuer
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = expires.Unix()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.sign(unsigned), expires, nil
+}
+
+// Verify checks a token's signature, issuer and expiry and returns its claims
+func (s *Signer) Verify(token string, now time.Time) (*Claims, error) {
+	unsigned, signature, ok := cutLast(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(unsigned))) {
+		return nil, ErrInvalidToken
+	}
+
+	h, payload, ok := strings.Cut(unsigned, ".")
+	if !ok || h != header {
+		return nil, ErrInvalidToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(raw, &claims); err != nil || claims.Issuer != s.issuer || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+func (s *Signer) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
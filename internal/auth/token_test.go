@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("0123456789abcdef0123456789abcdef"), "wallet", 15*time.Minute)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	token, expires, err := signer.Issue("user-1", "session-1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expires.Equal(now.Add(15 * time.Minute)) {
+		t.Errorf("expires = %v", expires)
+	}
+
+	claims, err := signer.Verify(token, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.SessionID != "session-1" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := signer.Verify(token, expires); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("verify at expiry: err = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestSignerRejectsForgedTokens(t *testing.T) {
+	signer := NewSigner([]byte("0123456789abcdef0123456789abcdef"), "wallet", time.Minute)
+	now := time.Now()
+	token, _, _ := signer.Issue("user-1", "session-1", now)
+	parts := strings.Split(token, ".")
+
+	other, _, _ := NewSigner([]byte("another secret of thirty-two byt"), "wallet", time.Minute).Issue("user-1", "session-1", now)
+	otherIssuer, _, _ := NewSigner([]byte("0123456789abcdef0123456789abcdef"), "elsewhere", time.Minute).Issue("user-1", "session-1", now)
+	noneAlg := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." + parts[1] + "."
+
+	tests := map[string]string{
+		"empty":           "",
+		"other secret":    other,
+		"other issuer":    otherIssuer,
+		"alg none":        noneAlg,
+		"changed payload": parts[0] + "." + parts[1] + "x." + parts[2],
+		"no signature":    parts[0] + "." + parts[1],
+	}
+	for name, forged := range tests {
+		if _, err := signer.Verify(forged, now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// RFC 6238 appendix B test vector for SHA-1, truncated to six digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(59, 0)
+
+	step, ok := ValidateTOTP(secret, "287082", now)
+	if !ok || step != 1 {
+		t.Fatalf("ValidateTOTP = %d, %v; want step 1", step, ok)
+	}
+
+	// One period of drift either way is accepted, two are not
+	if _, ok := ValidateTOTP(secret, "287082", now.Add(30*time.Second)); !ok {
+		t.Error("code from the previous period rejected")
+	}
+	if _, ok := ValidateTOTP(secret, "287082", now.Add(90*time.Second)); ok {
+		t.Error("code from two periods ago accepted")
+	}
+	if _, ok := ValidateTOTP(secret, "287083", now); ok {
+		t.Error("wrong code accepted")
+	}
+	if _, ok := ValidateTOTP("not base32!", "287082", now); ok {
+		t.Error("malformed secret accepted")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret := NewTOTPSecret()
+	if len(secret) != 32 {
+		t.Errorf("secret %q is not 160 bits of base32", secret)
+	}
+
+	uri := TOTPURI("Wallet App", "alice@example.com", secret)
+	want := "otpauth://totp/Wallet%20App:alice@example.com?algorithm=SHA1&digits=6&issuer=Wallet+App&period=30&secret=" + secret
+	if uri != want {
+		t.Errorf("uri = %s\nwant  %s", uri, want)
+	}
+}
@@ -0,0 +1,680 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the application configuration. Values come from the defaults
+// below, then an optional YAML or TOML file named by CONFIG_FILE, then
+// environment variables, each layer overriding the previous one.
+type Config struct {
+	Mongo     MongoConfig     `yaml:"mongo" toml:"mongo"`
+	HTTP      HTTPConfig      `yaml:"http" toml:"http"`
+	API       APIConfig       `yaml:"api" toml:"api"`
+	RateLimit RateLimitConfig `yaml:"rateLimit" toml:"rateLimit"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	TwoFactor TwoFactorConfig `yaml:"twoFactor" toml:"twoFactor"`
+	Login     LoginConfig     `yaml:"login" toml:"login"`
+	KYC       KYCConfig       `yaml:"kyc" toml:"kyc"`
+	Admin     AdminConfig     `yaml:"admin" toml:"admin"`
+	Notify    NotifyConfig    `yaml:"notify" toml:"notify"`
+	Mail      MailConfig      `yaml:"mail" toml:"mail"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+}
+
+type MongoConfig struct {
+	URI                    string         `yaml:"uri" toml:"uri"`
+	Database               string         `yaml:"database" toml:"database"`
+	MaxPoolSize            uint64         `yaml:"maxPoolSize" toml:"maxPoolSize"`
+	MinPoolSize            uint64         `yaml:"minPoolSize" toml:"minPoolSize"`
+	ConnectTimeout         time.Duration  `yaml:"connectTimeout" toml:"connectTimeout"`
+	ServerSelectionTimeout time.Duration  `yaml:"serverSelectionTimeout" toml:"serverSelectionTimeout"`
+	OperationTimeout       time.Duration  `yaml:"operationTimeout" toml:"operationTimeout"` // per repository call, 0 disables
+	TLS                    MongoTLSConfig `yaml:"tls" toml:"tls"`
+}
+
+type MongoTLSConfig struct {
+	Enabled  bool   `yaml:"enabled" toml:"enabled"`
+	CAFile   string `yaml:"caFile" toml:"caFile"`
+	CertFile string `yaml:"certFile" toml:"certFile"` // client certificate and key, PEM encoded
+	KeyFile  string `yaml:"keyFile" toml:"keyFile"`
+	Insecure bool   `yaml:"insecure" toml:"insecure"` // skip server certificate verification
+}
+
+type HTTPConfig struct {
+	Port              int           `yaml:"port" toml:"port"`
+	ReadTimeout       time.Duration `yaml:"readTimeout" toml:"readTimeout"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" toml:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" toml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" toml:"idleTimeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout"` // how long in-flight requests get to finish
+	TrustedProxies    []string      `yaml:"trustedProxies" toml:"trustedProxies"`   // IPs or CIDRs whose X-Forwarded-For is believed
+	TLS               HTTPTLSConfig `yaml:"tls" toml:"tls"`
+}
+
+// HTTPTLSConfig enables HTTPS when both files are set
+type HTTPTLSConfig struct {
+	CertFile string `yaml:"certFile" toml:"certFile"`
+	KeyFile  string `yaml:"keyFile" toml:"keyFile"`
+}
+
+// APIConfig controls the unversioned routes that predate /api/v1
+type APIConfig struct {
+	LegacyRoutes bool   `yaml:"legacyRoutes" toml:"legacyRoutes"` // keep serving them, with deprecation headers
+	LegacySunset string `yaml:"legacySunset" toml:"legacySunset"` // YYYY-MM-DD announced in their Sunset header
+}
+
+// SunsetDate returns the legacy sunset date; Validate has already checked it
+func (c APIConfig) SunsetDate() time.Time {
+	t, _ := time.Parse(time.DateOnly, c.LegacySunset)
+	return t
+}
+
+// RateLimitConfig sets the token-bucket policy of each route group. Rates are
+// written as requests/period, e.g. 10/1m; an empty rate means no limit.
+type RateLimitConfig struct {
+	Enabled bool                  `yaml:"enabled" toml:"enabled"`
+	Store   string                `yaml:"store" toml:"store"`     // memory (per replica) or mongo (shared)
+	Auth    RateLimitPolicyConfig `yaml:"auth" toml:"auth"`       // register and login
+	Trading RateLimitPolicyConfig `yaml:"trading" toml:"trading"` // orders, deposits and withdrawals
+	Admin   RateLimitPolicyConfig `yaml:"admin" toml:"admin"`
+	Default RateLimitPolicyConfig `yaml:"default" toml:"default"` // every other API route
+}
+
+type RateLimitPolicyConfig struct {
+	PerIP   string `yaml:"perIP" toml:"perIP"`
+	PerUser string `yaml:"perUser" toml:"perUser"`
+}
+
+// ParseRate parses a rate such as 10/1m into a request count and a period.
+// The empty rate is 0 requests per 0s, which means no limit.
+func ParseRate(rate string) (int, time.Duration, error) {
+	if rate == "" {
+		return 0, 0, nil
+	}
+
+	count, period, ok := strings.Cut(rate, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("rate %q must be a positive request count and a period, e.g. 10/1m", rate)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return 0, 0, fmt.Errorf("rate %q must be a positive request count and a period, e.g. 10/1m", rate)
+	}
+
+	return n, d, nil
+}
+
+// AuthConfig sets how logins are turned into tokens. Access tokens are
+// signed with TokenSecret; every replica must share it, or tokens issued by
+// one are rejected by the others.
+type AuthConfig struct {
+	TokenSecret     string        `yaml:"tokenSecret" toml:"tokenSecret"` // random per process when empty
+	AccessTokenTTL  time.Duration `yaml:"accessTokenTTL" toml:"accessTokenTTL"`
+	RefreshTokenTTL time.Duration `yaml:"refreshTokenTTL" toml:"refreshTokenTTL"` // idle time after which a session expires
+
+	EmailVerificationTTL time.Duration `yaml:"emailVerificationTTL" toml:"emailVerificationTTL"` // lifetime of mailed verification links
+	PasswordResetTTL     time.Duration `yaml:"passwordResetTTL" toml:"passwordResetTTL"`         // lifetime of mailed password reset links
+}
+
+// TwoFactorConfig sets up TOTP two-factor authentication
+type TwoFactorConfig struct {
+	Issuer       string        `yaml:"issuer" toml:"issuer"`             // account name shown in authenticator apps
+	StepUpAmount float64       `yaml:"stepUpAmount" toml:"stepUpAmount"` // withdrawals above it need a second factor
+	ChallengeTTL time.Duration `yaml:"challengeTTL" toml:"challengeTTL"` // time to enter the code after the password
+}
+
+// LoginConfig sets the brute-force protection of logins. Each failure
+// within FailureWindow delays the next attempt on the account, doubling from
+// BaseDelay up to MaxDelay; MaxFailures of them lock the account, and
+// MaxIPFailures across all accounts lock the client IP, for LockoutDuration.
+type LoginConfig struct {
+	MaxFailures     int           `yaml:"maxFailures" toml:"maxFailures"`
+	MaxIPFailures   int           `yaml:"maxIPFailures" toml:"maxIPFailures"`
+	FailureWindow   time.Duration `yaml:"failureWindow" toml:"failureWindow"`
+	LockoutDuration time.Duration `yaml:"lockoutDuration" toml:"lockoutDuration"`
+	BaseDelay       time.Duration `yaml:"baseDelay" toml:"baseDelay"`
+	MaxDelay        time.Duration `yaml:"maxDelay" toml:"maxDelay"`
+}
+
+// KYCConfig sets the daily limits of each identity verification tier,
+// counted per user and UTC day. Unverified users cannot place orders at
+// all, so their Order limit is unused.
+type KYCConfig struct {
+	Unverified TierLimitsConfig `yaml:"unverified" toml:"unverified"`
+	Basic      TierLimitsConfig `yaml:"basic" toml:"basic"`
+	Full       TierLimitsConfig `yaml:"full" toml:"full"`
+}
+
+type TierLimitsConfig struct {
+	Deposit    float64 `yaml:"deposit" toml:"deposit"`
+	Withdrawal float64 `yaml:"withdrawal" toml:"withdrawal"`
+	Order      float64 `yaml:"order" toml:"order"` // total notional of buys and sells
+}
+
+type AdminConfig struct {
+	APIKey string `yaml:"apiKey" toml:"apiKey"`
+}
+
+type NotifyConfig struct {
+	File         string `yaml:"file" toml:"file"`
+	SMTPStubDir  string `yaml:"smtpStubDir" toml:"smtpStubDir"`
+	SMTPStubFrom string `yaml:"smtpStubFrom" toml:"smtpStubFrom"`
+}
+
+// MailConfig sets how account email, such as verification and password
+// reset links, is delivered. Sender is "console" (standard output) or
+// "file" (an .eml file per message in Dir). Links in the mail point to
+// LinkBaseURL, the frontend that posts their token back to the API.
+type MailConfig struct {
+	Sender      string `yaml:"sender" toml:"sender"`
+	From        string `yaml:"from" toml:"from"`
+	Dir         string `yaml:"dir" toml:"dir"`
+	LinkBaseURL string `yaml:"linkBaseURL" toml:"linkBaseURL"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level"`   // debug, info, warn or error
+	Format string `yaml:"format" toml:"format"` // json or text
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" toml:"exporter"`         // none, otlp, stdout or file
+	OTLPEndpoint string  `yaml:"otlpEndpoint" toml:"otlpEndpoint"` // host:port of the collector's OTLP/HTTP receiver
+	OTLPInsecure bool    `yaml:"otlpInsecure" toml:"otlpInsecure"` // plain HTTP instead of HTTPS
+	File         string  `yaml:"file" toml:"file"`                 // spans as JSON lines, for the file exporter
+	ServiceName  string  `yaml:"serviceName" toml:"serviceName"`
+	SampleRatio  float64 `yaml:"sampleRatio" toml:"sampleRatio"` // fraction of new traces recorded
+}
+
+// SlogLevel returns the configured level; Validate has already checked it
+func (c LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.Level))
+	return level
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		Mongo: MongoConfig{
+			URI:                    "mongodb://localhost:27017",
+			Database:               "wallet_order_system",
+			MaxPoolSize:            100,
+			ConnectTimeout:         10 * time.Second,
+			ServerSelectionTimeout: 10 * time.Second,
+			OperationTimeout:       10 * time.Second,
+		},
+		HTTP: HTTPConfig{
+			Port:              8080,
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		API: APIConfig{
+			LegacyRoutes: true,
+			LegacySunset: "2027-04-30",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Store:   "memory",
+			Auth:    RateLimitPolicyConfig{PerIP: "10/1m"},
+			Trading: RateLimitPolicyConfig{PerIP: "120/1m", PerUser: "60/1m"},
+			Admin:   RateLimitPolicyConfig{PerIP: "30/1m"},
+			Default: RateLimitPolicyConfig{PerIP: "600/1m", PerUser: "300/1m"},
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+
+			EmailVerificationTTL: 48 * time.Hour,
+			PasswordResetTTL:     time.Hour,
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:       "Wallet Order System",
+			StepUpAmount: 1000,
+			ChallengeTTL: 5 * time.Minute,
+		},
+		Login: LoginConfig{
+			MaxFailures:     5,
+			MaxIPFailures:   50,
+			FailureWindow:   15 * time.Minute,
+			LockoutDuration: 15 * time.Minute,
+			BaseDelay:       time.Second,
+			MaxDelay:        30 * time.Second,
+		},
+		KYC: KYCConfig{
+			Unverified: TierLimitsConfig{Deposit: 1000, Withdrawal: 1000},
+			Basic:      TierLimitsConfig{Deposit: 10000, Withdrawal: 10000, Order: 25000},
+			Full:       TierLimitsConfig{Deposit: 100000, Withdrawal: 100000, Order: 250000},
+		},
+		Notify: NotifyConfig{
+			SMTPStubFrom: "alerts@wallet-order-system.local",
+		},
+		Mail: MailConfig{
+			Sender:      "console",
+			From:        "Wallet Order System <no-reply@wallet-order-system.local>",
+			LinkBaseURL: "http://localhost:3000",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			OTLPEndpoint: "localhost:4318",
+			ServiceName:  "wallet-order-system",
+			SampleRatio:  1,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, CONFIG_FILE and the
+// environment, and validates the result
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s: extension must be .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv overrides the configuration with any environment variables that are set
+func applyEnv(cfg *Config) error {
+	var errs []error
+
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	integer := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	unsigned := func(name string, dst *uint64) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a non-negative integer", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a duration such as 10s", name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+	float := func(name string, dst *float64) {
+		if v, ok := os.LookupEnv(name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", name, v))
+				return
+			}
+			*dst = f
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a boolean", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	str("MONGO_URI", &cfg.Mongo.URI)
+	str("MONGO_DATABASE", &cfg.Mongo.Database)
+	unsigned("MONGO_MAX_POOL_SIZE", &cfg.Mongo.MaxPoolSize)
+	unsigned("MONGO_MIN_POOL_SIZE", &cfg.Mongo.MinPoolSize)
+	duration("MONGO_CONNECT_TIMEOUT", &cfg.Mongo.ConnectTimeout)
+	duration("MONGO_SERVER_SELECTION_TIMEOUT", &cfg.Mongo.ServerSelectionTimeout)
+	duration("MONGO_OPERATION_TIMEOUT", &cfg.Mongo.OperationTimeout)
+	boolean("MONGO_TLS_ENABLED", &cfg.Mongo.TLS.Enabled)
+	str("MONGO_TLS_CA_FILE", &cfg.Mongo.TLS.CAFile)
+	str("MONGO_TLS_CERT_FILE", &cfg.Mongo.TLS.CertFile)
+	str("MONGO_TLS_KEY_FILE", &cfg.Mongo.TLS.KeyFile)
+	boolean("MONGO_TLS_INSECURE", &cfg.Mongo.TLS.Insecure)
+
+	integer("HTTP_PORT", &cfg.HTTP.Port)
+	duration("HTTP_READ_TIMEOUT", &cfg.HTTP.ReadTimeout)
+	duration("HTTP_READ_HEADER_TIMEOUT", &cfg.HTTP.ReadHeaderTimeout)
+	duration("HTTP_WRITE_TIMEOUT", &cfg.HTTP.WriteTimeout)
+	duration("HTTP_IDLE_TIMEOUT", &cfg.HTTP.IdleTimeout)
+	duration("HTTP_SHUTDOWN_TIMEOUT", &cfg.HTTP.ShutdownTimeout)
+	list("HTTP_TRUSTED_PROXIES", &cfg.HTTP.TrustedProxies)
+	str("HTTP_TLS_CERT_FILE", &cfg.HTTP.TLS.CertFile)
+	str("HTTP_TLS_KEY_FILE", &cfg.HTTP.TLS.KeyFile)
+
+	boolean("API_LEGACY_ROUTES", &cfg.API.LegacyRoutes)
+	str("API_LEGACY_SUNSET", &cfg.API.LegacySunset)
+
+	boolean("RATE_LIMIT_ENABLED", &cfg.RateLimit.Enabled)
+	str("RATE_LIMIT_STORE", &cfg.RateLimit.Store)
+	str("RATE_LIMIT_AUTH_PER_IP", &cfg.RateLimit.Auth.PerIP)
+	str("RATE_LIMIT_AUTH_PER_USER", &cfg.RateLimit.Auth.PerUser)
+	str("RATE_LIMIT_TRADING_PER_IP", &cfg.RateLimit.Trading.PerIP)
+	str("RATE_LIMIT_TRADING_PER_USER", &cfg.RateLimit.Trading.PerUser)
+	str("RATE_LIMIT_ADMIN_PER_IP", &cfg.RateLimit.Admin.PerIP)
+	str("RATE_LIMIT_ADMIN_PER_USER", &cfg.RateLimit.Admin.PerUser)
+	str("RATE_LIMIT_DEFAULT_PER_IP", &cfg.RateLimit.Default.PerIP)
+	str("RATE_LIMIT_DEFAULT_PER_USER", &cfg.RateLimit.Default.PerUser)
+
+	str("AUTH_TOKEN_SECRET", &cfg.Auth.TokenSecret)
+	duration("AUTH_ACCESS_TOKEN_TTL", &cfg.Auth.AccessTokenTTL)
+	duration("AUTH_REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL)
+	duration("AUTH_EMAIL_VERIFICATION_TTL", &cfg.Auth.EmailVerificationTTL)
+	duration("AUTH_PASSWORD_RESET_TTL", &cfg.Auth.PasswordResetTTL)
+
+	str("TWO_FACTOR_ISSUER", &cfg.TwoFactor.Issuer)
+	float("TWO_FACTOR_STEP_UP_AMOUNT", &cfg.TwoFactor.StepUpAmount)
+	duration("TWO_FACTOR_CHALLENGE_TTL", &cfg.TwoFactor.ChallengeTTL)
+
+	integer("LOGIN_MAX_FAILURES", &cfg.Login.MaxFailures)
+	integer("LOGIN_MAX_IP_FAILURES", &cfg.Login.MaxIPFailures)
+	duration("LOGIN_FAILURE_WINDOW", &cfg.Login.FailureWindow)
+	duration("LOGIN_LOCKOUT_DURATION", &cfg.Login.LockoutDuration)
+	duration("LOGIN_BASE_DELAY", &cfg.Login.BaseDelay)
+	duration("LOGIN_MAX_DELAY", &cfg.Login.MaxDelay)
+
+	float("KYC_UNVERIFIED_DAILY_DEPOSIT", &cfg.KYC.Unverified.Deposit)
+	float("KYC_UNVERIFIED_DAILY_WITHDRAWAL", &cfg.KYC.Unverified.Withdrawal)
+	float("KYC_BASIC_DAILY_DEPOSIT", &cfg.KYC.Basic.Deposit)
+	float("KYC_BASIC_DAILY_WITHDRAWAL", &cfg.KYC.Basic.Withdrawal)
+	float("KYC_BASIC_DAILY_ORDER", &cfg.KYC.Basic.Order)
+	float("KYC_FULL_DAILY_DEPOSIT", &cfg.KYC.Full.Deposit)
+	float("KYC_FULL_DAILY_WITHDRAWAL", &cfg.KYC.Full.Withdrawal)
+	float("KYC_FULL_DAILY_ORDER", &cfg.KYC.Full.Order)
+
+	str("ADMIN_API_KEY", &cfg.Admin.APIKey)
+
+	str("NOTIFY_FILE", &cfg.Notify.File)
+	str("NOTIFY_SMTP_STUB_DIR", &cfg.Notify.SMTPStubDir)
+	str("NOTIFY_SMTP_STUB_FROM", &cfg.Notify.SMTPStubFrom)
+
+	str("MAIL_SENDER", &cfg.Mail.Sender)
+	str("MAIL_FROM", &cfg.Mail.From)
+	str("MAIL_DIR", &cfg.Mail.Dir)
+	str("MAIL_LINK_BASE_URL", &cfg.Mail.LinkBaseURL)
+
+	str("LOG_LEVEL", &cfg.Log.Level)
+	str("LOG_FORMAT", &cfg.Log.Format)
+
+	str("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	str("TRACING_OTLP_ENDPOINT", &cfg.Tracing.OTLPEndpoint)
+	boolean("TRACING_OTLP_INSECURE", &cfg.Tracing.OTLPInsecure)
+	str("TRACING_FILE", &cfg.Tracing.File)
+	str("TRACING_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	float("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every problem with the configuration at once
+func (c Config) Validate() error {
+	var errs []error
+
+	if u, err := url.Parse(c.Mongo.URI); err != nil || (u.Scheme != "mongodb" && u.Scheme != "mongodb+srv") {
+		errs = append(errs, errors.New("mongo.uri must be a mongodb:// or mongodb+srv:// URI"))
+	}
+	if c.Mongo.Database == "" {
+		errs = append(errs, errors.New("mongo.database is required"))
+	}
+	if c.Mongo.MaxPoolSize > 0 && c.Mongo.MinPoolSize > c.Mongo.MaxPoolSize {
+		errs = append(errs, errors.New("mongo.minPoolSize cannot exceed mongo.maxPoolSize"))
+	}
+	if c.Mongo.ConnectTimeout <= 0 {
+		errs = append(errs, errors.New("mongo.connectTimeout must be positive"))
+	}
+	if c.Mongo.ServerSelectionTimeout <= 0 {
+		errs = append(errs, errors.New("mongo.serverSelectionTimeout must be positive"))
+	}
+	if c.Mongo.OperationTimeout < 0 {
+		errs = append(errs, errors.New("mongo.operationTimeout cannot be negative"))
+	}
+	if c.Mongo.TLS.Enabled {
+		errs = append(errs, checkFile("mongo.tls.caFile", c.Mongo.TLS.CAFile))
+		if (c.Mongo.TLS.CertFile == "") != (c.Mongo.TLS.KeyFile == "") {
+			errs = append(errs, errors.New("mongo.tls.certFile and mongo.tls.keyFile must be set together"))
+		}
+		errs = append(errs, checkFile("mongo.tls.certFile", c.Mongo.TLS.CertFile))
+		errs = append(errs, checkFile("mongo.tls.keyFile", c.Mongo.TLS.KeyFile))
+	}
+
+	if c.HTTP.Port < 1 || c.HTTP.Port > 65535 {
+		errs = append(errs, fmt.Errorf("http.port %d is out of range", c.HTTP.Port))
+	}
+	for _, t := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"http.readTimeout", c.HTTP.ReadTimeout},
+		{"http.readHeaderTimeout", c.HTTP.ReadHeaderTimeout},
+		{"http.writeTimeout", c.HTTP.WriteTimeout},
+		{"http.idleTimeout", c.HTTP.IdleTimeout},
+		{"http.shutdownTimeout", c.HTTP.ShutdownTimeout},
+	} {
+		if t.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", t.name))
+		}
+	}
+	if (c.HTTP.TLS.CertFile == "") != (c.HTTP.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("http.tls.certFile and http.tls.keyFile must be set together"))
+	}
+	errs = append(errs, checkFile("http.tls.certFile", c.HTTP.TLS.CertFile))
+	errs = append(errs, checkFile("http.tls.keyFile", c.HTTP.TLS.KeyFile))
+
+	if _, err := time.Parse(time.DateOnly, c.API.LegacySunset); c.API.LegacyRoutes && err != nil {
+		errs = append(errs, fmt.Errorf("api.legacySunset %q must be a date such as 2027-04-30", c.API.LegacySunset))
+	}
+
+	for _, proxy := range c.HTTP.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("http.trustedProxies: %q is not an IP address or CIDR", proxy))
+			}
+		}
+	}
+
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "mongo" {
+		errs = append(errs, fmt.Errorf("rateLimit.store %q must be memory or mongo", c.RateLimit.Store))
+	}
+	for _, p := range []struct {
+		name   string
+		policy RateLimitPolicyConfig
+	}{
+		{"auth", c.RateLimit.Auth},
+		{"trading", c.RateLimit.Trading},
+		{"admin", c.RateLimit.Admin},
+		{"default", c.RateLimit.Default},
+	} {
+		if _, _, err := ParseRate(p.policy.PerIP); err != nil {
+			errs = append(errs, fmt.Errorf("rateLimit.%s.perIP: %w", p.name, err))
+		}
+		if _, _, err := ParseRate(p.policy.PerUser); err != nil {
+			errs = append(errs, fmt.Errorf("rateLimit.%s.perUser: %w", p.name, err))
+		}
+	}
+
+	if c.Auth.TokenSecret != "" && len(c.Auth.TokenSecret) < 32 {
+		errs = append(errs, errors.New("auth.tokenSecret must be at least 32 bytes"))
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.accessTokenTTL must be positive"))
+	}
+	if c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+		errs = append(errs, errors.New("auth.refreshTokenTTL must exceed auth.accessTokenTTL"))
+	}
+	if c.Auth.EmailVerificationTTL <= 0 {
+		errs = append(errs, errors.New("auth.emailVerificationTTL must be positive"))
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("auth.passwordResetTTL must be positive"))
+	}
+
+	if c.TwoFactor.Issuer == "" || strings.Contains(c.TwoFactor.Issuer, ":") {
+		errs = append(errs, errors.New("twoFactor.issuer is required and cannot contain a colon"))
+	}
+	if c.TwoFactor.StepUpAmount < 0 {
+		errs = append(errs, errors.New("twoFactor.stepUpAmount cannot be negative"))
+	}
+	if c.TwoFactor.ChallengeTTL <= 0 {
+		errs = append(errs, errors.New("twoFactor.challengeTTL must be positive"))
+	}
+
+	if c.Login.MaxFailures <= 0 {
+		errs = append(errs, errors.New("login.maxFailures must be positive"))
+	}
+	if c.Login.MaxIPFailures <= 0 {
+		errs = append(errs, errors.New("login.maxIPFailures must be positive"))
+	}
+	if c.Login.FailureWindow <= 0 {
+		errs = append(errs, errors.New("login.failureWindow must be positive"))
+	}
+	if c.Login.LockoutDuration <= 0 {
+		errs = append(errs, errors.New("login.lockoutDuration must be positive"))
+	}
+	if c.Login.BaseDelay < 0 || c.Login.MaxDelay < c.Login.BaseDelay {
+		errs = append(errs, errors.New("login.baseDelay cannot be negative or exceed login.maxDelay"))
+	}
+
+	for _, t := range []struct {
+		name   string
+		limits TierLimitsConfig
+	}{
+		{"unverified", c.KYC.Unverified},
+		{"basic", c.KYC.Basic},
+		{"full", c.KYC.Full},
+	} {
+		if t.limits.Deposit < 0 || t.limits.Withdrawal < 0 || t.limits.Order < 0 {
+			errs = append(errs, fmt.Errorf("kyc.%s limits cannot be negative", t.name))
+		}
+	}
+
+	switch c.Mail.Sender {
+	case "console":
+	case "file":
+		if c.Mail.Dir == "" {
+			errs = append(errs, errors.New("mail.dir is required for the file sender"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.sender %q must be console or file", c.Mail.Sender))
+	}
+	if u, err := url.Parse(c.Mail.LinkBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("mail.linkBaseURL %q must be an absolute URL", c.Mail.LinkBaseURL))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		errs = append(errs, fmt.Errorf("log.format %q must be json or text", c.Log.Format))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.OTLPEndpoint == "" {
+			errs = append(errs, errors.New("tracing.otlpEndpoint is required for the otlp exporter"))
+		}
+	case "file":
+		if c.Tracing.File == "" {
+			errs = append(errs, errors.New("tracing.file is required for the file exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q must be none, otlp, stdout or file", c.Tracing.Exporter))
+	}
+	if c.Tracing.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.serviceName is required"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sampleRatio must be between 0 and 1"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkFile verifies that an optional file setting points at a readable file
+func checkFile(name, path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Mongo.URI != "mongodb://localhost:27017" || cfg.Mongo.Database != "wallet_order_system" || cfg.HTTP.Port != 8080 {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadFileThenEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", "mongo:\n  database: from_file\n  maxPoolSize: 20\n  connectTimeout: 3s\nhttp:\n  port: 9000\n"},
+		{"config.toml", "[mongo]\ndatabase = \"from_file\"\nmaxPoolSize = 20\nconnectTimeout = \"3s\"\n\n[http]\nport = 9000\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.name)
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Setenv("CONFIG_FILE", path)
+			t.Setenv("HTTP_PORT", "9100")
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			if cfg.Mongo.Database != "from_file" || cfg.Mongo.MaxPoolSize != 20 || cfg.Mongo.ConnectTimeout != 3*time.Second {
+				t.Errorf("file values not applied: %+v", cfg.Mongo)
+			}
+
+			if cfg.HTTP.Port != 9100 {
+				t.Errorf("port = %d, want env override 9100", cfg.HTTP.Port)
+			}
+
+			if cfg.Mongo.URI != "mongodb://localhost:27017" {
+				t.Errorf("uri = %q, want default kept", cfg.Mongo.URI)
+			}
+		})
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("MONGO_URI", "localhost:27017")
+	t.Setenv("MONGO_DATABASE", "")
+	t.Setenv("HTTP_PORT", "70000")
+	t.Setenv("HTTP_TLS_CERT_FILE", "cert.pem")
+	t.Setenv("HTTP_SHUTDOWN_TIMEOUT", "0s")
+	t.Setenv("TRACING_EXPORTER", "file")
+	t.Setenv("TRACING_SAMPLE_RATIO", "1.5")
+	t.Setenv("API_LEGACY_SUNSET", "soon")
+	t.Setenv("RATE_LIMIT_AUTH_PER_IP", "10 per minute")
+	t.Setenv("HTTP_TRUSTED_PROXIES", "10.0.0.0/8, proxy.local")
+	t.Setenv("LOGIN_MAX_FAILURES", "0")
+	t.Setenv("AUTH_TOKEN_SECRET", "too short")
+	t.Setenv("TWO_FACTOR_STEP_UP_AMOUNT", "-1")
+	t.Setenv("MAIL_SENDER", "file")
+	t.Setenv("KYC_BASIC_DAILY_ORDER", "-100")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load succeeded, want error")
+	}
+
+	for _, want := range []string{"mongo.uri", "mongo.database", "http.port", "http.shutdownTimeout", "http.tls.certFile and http.tls.keyFile", "tracing.file", "tracing.sampleRatio", "api.legacySunset", "rateLimit.auth.perIP", "http.trustedProxies", "login.maxFailures", "auth.tokenSecret", "twoFactor.stepUpAmount", "kyc.basic", "mail.dir"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsMalformedEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("MONGO_CONNECT_TIMEOUT", "ten seconds")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MONGO_CONNECT_TIMEOUT") {
+		t.Fatalf("err = %v, want MONGO_CONNECT_TIMEOUT error", err)
+	}
+}
+
+func TestExpectedIndexNames(t *testing.T) {
+	seen := map[string]bool{}
+
+	for _, c := range expectedIndexes {
+		for _, index := range c.indexes {
+			name := c.collection + "." + indexName(index)
+			if seen[name] {
+				t.Errorf("duplicate index %s", name)
+			}
+			seen[name] = true
+		}
+	}
+
+	for _, want := range []string{"users.email_1", "portfolio.userId_1_symbol_1", "notifications.userId_1_createdAt_-1", "stocks.stocks_text"} {
+		if !seen[want] {
+			t.Errorf("missing expected index %s", want)
+		}
+	}
+}
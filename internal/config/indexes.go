@@ -2,76 +2,315 @@ package config
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func CreateIndexes() {
+// collectionIndexes lists the indexes a collection relies on. Every index is
+// named so that readiness checks can tell whether it exists; unnamed ones get
+// the name MongoDB would generate from their keys.
+type collectionIndexes struct {
+	collection string
+	indexes    []mongo.IndexModel
+}
 
+var expectedIndexes = []collectionIndexes{
 	// ======================
 	// Users Collection Indexes
 	// ======================
-	users := DB.Collection("users")
-
-	_, err := users.Indexes().CreateOne(context.Background(), mongo.IndexModel{
-		Keys: bson.M{"email": 1},
-		Options: options.Index().
-			SetUnique(true).
-			SetBackground(true),
-	})
-	if err != nil {
-		log.Println("Failed to create users index:", err)
-	}
+	{"users", []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// The admin user search, newest first
+		{
+			Keys: bson.D{
+				{Key: "createdAt", Value: -1},
+				{Key: "_id", Value: -1},
+			},
+		},
+		// The admin KYC review queue
+		{
+			Keys: bson.D{
+				{Key: "kyc.status", Value: 1},
+				{Key: "kyc.submittedAt", Value: 1},
+			},
+		},
+	}},
 
 	// ======================
 	// Stocks Collection Indexes
 	// ======================
-	stocks := DB.Collection("stocks")
-
-	_, err = stocks.Indexes().CreateOne(context.Background(), mongo.IndexModel{
-		Keys: bson.M{"symbol": 1},
-		Options: options.Index().
-			SetUnique(true).
-			SetBackground(true),
-	})
-	if err != nil {
-		log.Println("Failed to create stocks index:", err)
-	}
+	{"stocks", []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "symbol", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Search and filtering on GET /stocks
+		{
+			Keys: bson.D{
+				{Key: "symbol", Value: "text"},
+				{Key: "name", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("stocks_text").
+				SetWeights(bson.M{"symbol": 10, "name": 5}),
+		},
+		{
+			Keys: bson.D{
+				{Key: "sector", Value: 1},
+				{Key: "price", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "exchange", Value: 1},
+				{Key: "symbol", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "symbol", Value: 1},
+			},
+		},
+	}},
 
 	// ======================
 	// Portfolio Collection Indexes
 	// ======================
-	portfolio := DB.Collection("portfolio")
-
-	_, err = portfolio.Indexes().CreateOne(context.Background(), mongo.IndexModel{
-		Keys: bson.M{
-			"userId": 1,
-			"symbol": 1,
+	{"portfolio", []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "symbol", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
 		},
-		Options: options.Index().
-			SetUnique(true).
-			SetBackground(true),
-	})
-	if err != nil {
-		log.Println("Failed to create portfolio index:", err)
-	}
+	}},
 
 	// ======================
 	// Orders Collection Index
 	// ======================
-	orders := DB.Collection("orders")
+	{"orders", []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+		},
+	}},
+
+	// ======================
+	// Watchlists Collection Index
+	// ======================
+	{"watchlists", []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "name", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	}},
+
+	// ======================
+	// Alerts & Notifications Collection Indexes
+	// ======================
+	{"alerts", []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "symbol", Value: 1},
+				{Key: "status", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+		},
+	}},
+	{"notifications", []mongo.IndexModel{
+		// One notification per alert, however many times it is evaluated
+		{
+			Keys:    bson.D{{Key: "alertId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "createdAt", Value: -1},
+			},
+		},
+	}},
+
+	// ======================
+	// Rate Limit Buckets Index
+	// ======================
+	{"rate_limits", []mongo.IndexModel{
+		// Buckets are deleted once they would have refilled
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}},
+
+	// ======================
+	// Login Failures / Security Events Collection Indexes
+	// ======================
+	{"login_failures", []mongo.IndexModel{
+		// Counters are deleted once their window and any lockout have passed
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}},
+	{"sessions", []mongo.IndexModel{
+		// Listing a user's active sessions and logging out everywhere
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "lastUsedAt", Value: -1},
+			},
+		},
+		// Sessions are deleted once they could no longer be refreshed
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}},
+	{"user_tokens", []mongo.IndexModel{
+		// Using up a user's other tokens once one of them is used
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "purpose", Value: 1},
+			},
+		},
+		// Tokens are deleted once they have expired, used or not
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}},
+	{"limit_usage", []mongo.IndexModel{
+		// Daily totals are deleted once their day is over
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}},
+	{"audit_log", []mongo.IndexModel{
+		// One entry per position in the hash chain, so concurrent writers
+		// cannot both append after the same entry
+		{
+			Keys:    bson.D{{Key: "seq", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "actorId", Value: 1},
+				{Key: "seq", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "targetType", Value: 1},
+				{Key: "targetId", Value: 1},
+				{Key: "seq", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "action", Value: 1},
+				{Key: "seq", Value: -1},
+			},
+		},
+	}},
+	{"security_events", []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{
+				{Key: "type", Value: 1},
+				{Key: "createdAt", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "createdAt", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "email", Value: 1},
+				{Key: "createdAt", Value: -1},
+			},
+		},
+	}},
+}
+
+// CreateIndexes creates the indexes every collection relies on. A failure on
+// one collection does not stop the others; all failures are returned.
+func CreateIndexes(ctx context.Context, db *mongo.Database, logger *slog.Logger) error {
+	var failed []string
+
+	for _, c := range expectedIndexes {
+		_, err := db.Collection(c.collection).Indexes().CreateMany(ctx, c.indexes)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", c.collection, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to create indexes:\n%s", strings.Join(failed, "\n"))
+	}
+
+	logger.InfoContext(ctx, "indexes created")
+	return nil
+}
+
+// MissingIndexes returns the expected indexes, as collection.name, that do
+// not exist in the database
+func MissingIndexes(ctx context.Context, db *mongo.Database) ([]string, error) {
+	missing := []string{}
+
+	for _, c := range expectedIndexes {
+		specs, err := db.Collection(c.collection).Indexes().ListSpecifications(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		existing := make(map[string]bool, len(specs))
+		for _, spec := range specs {
+			existing[spec.Name] = true
+		}
+
+		for _, index := range c.indexes {
+			if name := indexName(index); !existing[name] {
+				missing = append(missing, c.collection+"."+name)
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+// indexName returns the explicit name of an index, or the one MongoDB
+// generates from its keys, e.g. userId_1_symbol_1
+func indexName(index mongo.IndexModel) string {
+	if index.Options != nil && index.Options.Name != nil {
+		return *index.Options.Name
+	}
 
-	_, err = orders.Indexes().CreateOne(context.Background(), mongo.IndexModel{
-		Keys: bson.M{"userId": 1},
-		Options: options.Index().
-			SetBackground(true),
-	})
-	if err != nil {
-		log.Println("Failed to create orders index:", err)
+	keys := index.Keys.(bson.D)
+	parts := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		parts = append(parts, key.Key, fmt.Sprint(key.Value))
 	}
 
-	log.Println("Indexes created successfully")
+	return strings.Join(parts, "_")
 }
@@ -2,33 +2,113 @@ package config
 
 import (
 	"context"
-	"log"
-	"time"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
 
+	"concurrent-wallet-order-system/internal/metrics"
+
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
-var DB *mongo.Database
-
-// ConnectMongo initializes MongoDB connection
-func ConnectMongo(uri string, dbName string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// ConnectMongo connects to MongoDB and verifies the connection with a ping.
+// The caller owns the client and must disconnect it on shutdown.
+func ConnectMongo(cfg MongoConfig, logger *slog.Logger) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
-	clientOptions := options.Client().ApplyURI(uri)
+	clientOptions := options.Client().
+		ApplyURI(cfg.URI).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetServerSelectionTimeout(cfg.ServerSelectionTimeout).
+		SetMinPoolSize(cfg.MinPoolSize).
+		SetMonitor(commandMonitor(logger))
+
+	if cfg.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(cfg.MaxPoolSize)
+	}
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := mongoTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
 
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		log.Fatal("MongoDB connection error:", err)
+		return nil, fmt.Errorf("MongoDB connection error: %w", err)
 	}
 
 	err = client.Ping(ctx, nil)
 	if err != nil {
-		log.Fatal("MongoDB ping failed:", err)
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("MongoDB ping failed: %w", err)
 	}
 
-	DB = client.Database(dbName)
+	logger.Info("MongoDB connected", "database", cfg.Database, "max_pool_size", cfg.MaxPoolSize)
+
+	return client, nil
+}
+
+// commandMonitor traces each command as a child span of the operation that
+// issued it, records command latencies and logs the command with the request
+// context. Command documents are left out of the spans as they can hold
+// password hashes.
+func commandMonitor(logger *slog.Logger) *event.CommandMonitor {
+	monitor := metrics.MongoMonitor()
+	tracer := otelmongo.NewMonitor(otelmongo.WithCommandAttributeDisabled(true))
+
+	return &event.CommandMonitor{
+		Started: tracer.Started,
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			tracer.Succeeded(ctx, e)
+			monitor.Succeeded(ctx, e)
+			logger.DebugContext(ctx, "mongo command",
+				"command", e.CommandName, "duration_ms", float64(e.Duration.Microseconds())/1000)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			tracer.Failed(ctx, e)
+			monitor.Failed(ctx, e)
+			logger.WarnContext(ctx, "mongo command failed",
+				"command", e.CommandName, "duration_ms", float64(e.Duration.Microseconds())/1000, "error", e.Failure)
+		},
+	}
+}
+
+func mongoTLSConfig(cfg MongoTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.Insecure,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading MongoDB CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("MongoDB CA file contains no PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading MongoDB client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
 
-	log.Println("MongoDB connected successfully")
+	return tlsConfig, nil
 }
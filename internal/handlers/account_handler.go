@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountHandler serves self-service account management: profile, password,
+// closure, email verification and password resets
+type AccountHandler struct {
+	accountService *services.AccountService
+	logger         *slog.Logger
+}
+
+func NewAccountHandler(accountService *services.AccountService, logger *slog.Logger) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		logger:         logger,
+	}
+}
+
+// EmailTokenRequest carries the token of a mailed link
+type EmailTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// UpdateProfileRequest changes the fields that are set. Changing the email
+// needs the current password.
+type UpdateProfileRequest struct {
+	Name     *string `json:"name"`
+	Email    *string `json:"email"`
+	Password string  `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+type CloseAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// SendVerification mails the caller a new verification link
+func (h *AccountHandler) SendVerification(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	if err := h.accountService.SendVerification(c.Request.Context(), userID); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "verification email sent"})
+}
+
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req EmailTokenRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "email verified"})
+}
+
+// ForgotPassword answers the same whether or not the email is registered
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, MessageResponse{Message: "if the email is registered, a password reset link has been sent to it"})
+}
+
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	if err := h.accountService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "password reset; log in with the new password"})
+}
+
+func (h *AccountHandler) UpdateProfile(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	user, err := h.accountService.UpdateProfile(c.Request.Context(), userID, req.Name, req.Email, req.Password, c.ClientIP())
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword logs the caller out everywhere, including this session
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	if err := h.accountService.ChangePassword(c.Request.Context(), userID, req.CurrentPassword, req.NewPassword, c.ClientIP()); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "password changed; log in with the new password"})
+}
+
+func (h *AccountHandler) Close(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	var req CloseAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	if err := h.accountService.Close(c.Request.Context(), userID, req.Password, c.ClientIP()); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "account closed"})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AlertHandler struct {
+	alertService *services.AlertService
+	logger       *slog.Logger
+}
+
+func NewAlertHandler(alertService *services.AlertService, logger *slog.Logger) *AlertHandler {
+	return &AlertHandler{
+		alertService: alertService,
+		logger:       logger,
+	}
+}
+
+type CreateAlertRequest struct {
+	Symbol    string  `json:"symbol" binding:"required"`
+	Condition string  `json:"condition" binding:"required"` // ABOVE, BELOW, PCT_RISE or PCT_DROP
+	Threshold float64 `json:"threshold" binding:"required"`
+}
+
+func (h *AlertHandler) Create(c *gin.Context) {
+	var req CreateAlertRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	// Alerts are created for the user of the access token
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	alert, err := h.alertService.CreateAlert(c.Request.Context(), userID, req.Symbol, req.Condition, req.Threshold)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, alert)
+}
+
+func (h *AlertHandler) GetUserAlerts(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid userId"))
+		return
+	}
+
+	alerts, err := h.alertService.GetUserAlerts(c.Request.Context(), callerID, userID)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+func (h *AlertHandler) Delete(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid alert id"))
+		return
+	}
+
+	if err := h.alertService.DeleteAlert(c.Request.Context(), callerID, id); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "alert deleted"})
+}
+
+func (h *AlertHandler) GetNotifications(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid userId"))
+		return
+	}
+
+	notifications, err := h.alertService.GetNotifications(c.Request.Context(), callerID, userID, c.Query("unread") == "true")
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+func (h *AlertHandler) MarkNotificationRead(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid notification id"))
+		return
+	}
+
+	if err := h.alertService.MarkNotificationRead(c.Request.Context(), callerID, id); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "notification marked as read"})
+}
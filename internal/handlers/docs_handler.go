@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"concurrent-wallet-order-system/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocsHandler serves the OpenAPI document and a Swagger UI that renders it
+type DocsHandler struct {
+	spec *openapi.Document
+}
+
+func NewDocsHandler(spec *openapi.Document) *DocsHandler {
+	return &DocsHandler{spec: spec}
+}
+
+func (h *DocsHandler) Spec(c *gin.Context) {
+	c.JSON(http.StatusOK, h.spec)
+}
+
+// UI loads Swagger UI from a CDN, so the page needs internet access
+func (h *DocsHandler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+}
+
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Concurrent Wallet Order System API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
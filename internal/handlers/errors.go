@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"concurrent-wallet-order-system/internal/problem"
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestError is a malformed request caught by a handler before it reaches
+// a service, e.g. an unparsable body or ID
+type requestError struct {
+	detail string
+}
+
+func (e *requestError) Error() string {
+	return e.detail
+}
+
+func badRequest(detail string) error {
+	return &requestError{detail: detail}
+}
+
+var kindStatus = map[services.Kind]int{
+	services.KindInvalid:      http.StatusUnprocessableEntity,
+	services.KindNotFound:     http.StatusNotFound,
+	services.KindConflict:     http.StatusConflict,
+	services.KindUnauthorized: http.StatusUnauthorized,
+	services.KindThrottled:    http.StatusTooManyRequests,
+	services.KindForbidden:    http.StatusForbidden,
+}
+
+// respondError sends err to the client as a problem+json response. Domain
+// errors carry their own status and code; anything else is an internal
+// failure whose cause is logged but not shown to the client.
+func respondError(c *gin.Context, logger *slog.Logger, err error) {
+	var (
+		reqErr        *requestError
+		validationErr *services.ValidationError
+		retryErr      *services.RetryError
+		domainErr     *services.Error
+		p             *problem.Details
+	)
+
+	switch {
+	case errors.As(err, &reqErr):
+		p = problem.New(http.StatusBadRequest, "bad_request", reqErr.detail)
+	case errors.As(err, &validationErr):
+		p = problem.New(http.StatusUnprocessableEntity, "validation_failed", validationErr.Message)
+		p.Field = validationErr.Field
+	case errors.As(err, &domainErr):
+		status, ok := kindStatus[domainErr.Kind]
+		if !ok {
+			status = http.StatusBadRequest
+		}
+		p = problem.New(status, domainErr.Code, domainErr.Message)
+
+		if errors.As(err, &retryErr) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryErr.RetryAfter.Seconds()))))
+		}
+	default:
+		p = problem.New(http.StatusInternalServerError, "internal_error", "the request could not be completed")
+	}
+
+	if p.Status >= 500 {
+		logger.ErrorContext(c.Request.Context(), "request failed", "status", p.Status, "error", err)
+	} else {
+		logger.DebugContext(c.Request.Context(), "request rejected", "status", p.Status, "code", p.Code, "error", err)
+	}
+
+	problem.Abort(c, p)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/problem"
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{"bad request", badRequest("invalid userId"), http.StatusBadRequest, "bad_request", "invalid userId"},
+		{"not found", services.ErrUserNotFound, http.StatusNotFound, "user_not_found", "user not found"},
+		{"conflict", fmt.Errorf("buy: %w", services.ErrInsufficientBalance), http.StatusConflict, "insufficient_balance", "insufficient balance"},
+		{"unauthorized", services.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials", "invalid email or password"},
+		{"validation", &services.ValidationError{Field: "amount", Message: "amount must be greater than zero"}, http.StatusUnprocessableEntity, "validation_failed", "amount must be greater than zero"},
+		{"internal", errors.New("connection reset by mongo-0:27017"), http.StatusInternalServerError, "internal_error", "the request could not be completed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/wallet/balance/1", nil)
+
+			respondError(c, logging.Discard(), tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("Content-Type = %q", ct)
+			}
+
+			var p problem.Details
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != tt.wantStatus || p.Code != tt.wantCode || p.Detail != tt.wantDetail || p.Instance != "/wallet/balance/1" {
+				t.Errorf("problem = %+v", p)
+			}
+		})
+	}
+}
+
+func TestRespondErrorSetsRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/login", nil)
+
+	respondError(c, logging.Discard(), &services.RetryError{Err: services.ErrAccountLocked, RetryAfter: 1500 * time.Millisecond})
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+}
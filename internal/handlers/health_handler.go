@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"concurrent-wallet-order-system/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	liveness  *health.Checker
+	readiness *health.Checker
+}
+
+func NewHealthHandler(liveness, readiness *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		liveness:  liveness,
+		readiness: readiness,
+	}
+}
+
+// Liveness reports whether the process should be restarted
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	respondHealth(c, h.liveness.Run(c.Request.Context()))
+}
+
+// Readiness reports whether the instance can serve traffic
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	respondHealth(c, h.readiness.Run(c.Request.Context()))
+}
+
+func respondHealth(c *gin.Context, report health.Report) {
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
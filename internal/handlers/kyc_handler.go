@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KYCHandler serves identity verification: the caller's own status and
+// submissions, and the admin review queue
+type KYCHandler struct {
+	kycService *services.KYCService
+	logger     *slog.Logger
+}
+
+func NewKYCHandler(kycService *services.KYCService, logger *slog.Logger) *KYCHandler {
+	return &KYCHandler{
+		kycService: kycService,
+		logger:     logger,
+	}
+}
+
+type KYCSubmitRequest struct {
+	Documents []models.KYCDocument `json:"documents" binding:"required"`
+}
+
+type KYCApproveRequest struct {
+	Tier int `json:"tier" binding:"required"` // 1 (basic) or 2 (full)
+}
+
+type KYCRejectRequest struct {
+	Reason string `json:"reason" binding:"required"` // shown to the user
+}
+
+// Status returns the caller's verification, daily limits and usage today
+func (h *KYCHandler) Status(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	status, err := h.kycService.Status(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *KYCHandler) Submit(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	var req KYCSubmitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	status, err := h.kycService.Submit(c.Request.Context(), userID, req.Documents)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, status)
+}
+
+// List returns the users with a KYC status, pending by default; admin only
+func (h *KYCHandler) List(c *gin.Context) {
+	users, err := h.kycService.List(c.Request.Context(), c.DefaultQuery("status", models.KYCPending))
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// Approve verifies a user at a tier; admin only
+func (h *KYCHandler) Approve(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid userId"))
+		return
+	}
+
+	var req KYCApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	status, err := h.kycService.Approve(c.Request.Context(), userID, req.Tier)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Reject turns down a user's pending documents; admin only
+func (h *KYCHandler) Reject(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid userId"))
+		return
+	}
+
+	var req KYCRejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	status, err := h.kycService.Reject(c.Request.Context(), userID, req.Reason)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
@@ -1,26 +1,30 @@
 package handlers
 
 import (
+	"log/slog"
 	"net/http"
 
 	"concurrent-wallet-order-system/internal/services"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type OrderHandler struct {
 	orderService *services.OrderService
+	logger       *slog.Logger
 }
 
-func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
+func NewOrderHandler(orderService *services.OrderService, logger *slog.Logger) *OrderHandler {
 	return &OrderHandler{
 		orderService: orderService,
+		logger:       logger,
 	}
 }
 
+// OrderRequest trades for the user of the access token. UserID is optional
+// and, if sent, must be that user.
 type OrderRequest struct {
-	UserID   string `json:"userId" binding:"required"`
+	UserID   string `json:"userId,omitempty"`
 	Symbol   string `json:"symbol" binding:"required"`
 	Quantity int    `json:"quantity" binding:"required"`
 }
@@ -29,19 +33,19 @@ func (h *OrderHandler) Buy(c *gin.Context) {
 	var req OrderRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, badRequest(err.Error()))
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	userID, err := authUser(c, req.UserID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	order, err := h.orderService.Buy(userID, req.Symbol, req.Quantity)
+	order, err := h.orderService.Buy(c.Request.Context(), userID, req.Symbol, req.Quantity)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
@@ -52,22 +56,21 @@ func (h *OrderHandler) Sell(c *gin.Context) {
 	var req OrderRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, badRequest(err.Error()))
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	userID, err := authUser(c, req.UserID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	order, err := h.orderService.Sell(userID, req.Symbol, req.Quantity)
+	order, err := h.orderService.Sell(c.Request.Context(), userID, req.Symbol, req.Quantity)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, order)
 }
-
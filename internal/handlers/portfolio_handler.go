@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log/slog"
 	"net/http"
 
 	"concurrent-wallet-order-system/internal/services"
@@ -11,26 +12,34 @@ import (
 
 type PortfolioHandler struct {
 	portfolioService *services.PortfolioService
+	logger           *slog.Logger
 }
 
-func NewPortfolioHandler(portfolioService *services.PortfolioService) *PortfolioHandler {
+func NewPortfolioHandler(portfolioService *services.PortfolioService, logger *slog.Logger) *PortfolioHandler {
 	return &PortfolioHandler{
 		portfolioService: portfolioService,
+		logger:           logger,
 	}
 }
 
 func (h *PortfolioHandler) GetPortfolio(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
 	userIDParam := c.Param("userId")
 
 	userID, err := primitive.ObjectIDFromHex(userIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		respondError(c, h.logger, badRequest("invalid userId"))
 		return
 	}
 
-	portfolio, err := h.portfolioService.GetPortfolio(userID)
+	portfolio, err := h.portfolioService.GetPortfolio(c.Request.Context(), callerID, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
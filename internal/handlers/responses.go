@@ -0,0 +1,6 @@
+package handlers
+
+// MessageResponse confirms an operation that has nothing else to return
+type MessageResponse struct {
+	Message string `json:"message"`
+}
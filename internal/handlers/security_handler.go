@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"concurrent-wallet-order-system/internal/repo"
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SecurityHandler struct {
+	securityService *services.SecurityService
+	logger          *slog.Logger
+}
+
+func NewSecurityHandler(securityService *services.SecurityService, logger *slog.Logger) *SecurityHandler {
+	return &SecurityHandler{
+		securityService: securityService,
+		logger:          logger,
+	}
+}
+
+// ListEvents returns the security log, newest first; admin only
+func (h *SecurityHandler) ListEvents(c *gin.Context) {
+	filter := repo.SecurityEventFilter{
+		Type:  c.Query("type"),
+		Email: c.Query("email"),
+	}
+
+	if v := c.Query("userId"); v != "" {
+		userID, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			respondError(c, h.logger, badRequest("invalid userId"))
+			return
+		}
+		filter.UserID = userID
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondError(c, h.logger, badRequest("invalid limit"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.securityService.ListEvents(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"concurrent-wallet-order-system/internal/middleware"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+	logger         *slog.Logger
+}
+
+func NewSessionHandler(sessionService *services.SessionService, logger *slog.Logger) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		logger:         logger,
+	}
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// TokenResponse carries a new access token and the refresh token that
+// replaces the one just used
+type TokenResponse struct {
+	AccessToken      string    `json:"accessToken"`
+	TokenType        string    `json:"tokenType"`
+	ExpiresIn        int       `json:"expiresIn"` // seconds
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+func newTokenResponse(t *services.Tokens) TokenResponse {
+	return TokenResponse{
+		AccessToken:      t.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(time.Until(t.AccessExpiresAt).Round(time.Second).Seconds()),
+		RefreshToken:     t.RefreshToken,
+		RefreshExpiresAt: t.RefreshExpiresAt,
+	}
+}
+
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"` // the session of the access token used to list them
+}
+
+type LogoutAllResponse struct {
+	Message string `json:"message"`
+	Revoked int64  `json:"revoked"`
+}
+
+// authSession returns the user and session of the request's access token.
+// The routes are behind middleware.RequireAuth, and the token's signature
+// vouches for its IDs, so these only fail on a misconfigured router.
+func authSession(c *gin.Context) (userID, sessionID primitive.ObjectID, err error) {
+	userID, err = primitive.ObjectIDFromHex(middleware.AuthenticatedUser(c))
+	if err != nil {
+		return userID, sessionID, err
+	}
+	sessionID, err = primitive.ObjectIDFromHex(middleware.AuthenticatedSession(c))
+	return userID, sessionID, err
+}
+
+// authUser returns the user of the request's access token for the routes
+// that used to take the user from the body. A userId still sent there must
+// name the same user.
+func authUser(c *gin.Context, bodyUserID string) (primitive.ObjectID, error) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		return userID, err
+	}
+	if bodyUserID != "" && bodyUserID != userID.Hex() {
+		return userID, services.ErrUserMismatch
+	}
+	return userID, nil
+}
+
+func (h *SessionHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(c.Request.Context(), req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTokenResponse(tokens))
+}
+
+// Logout ends the session of the access token
+func (h *SessionHandler) Logout(c *gin.Context) {
+	userID, sessionID, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	if err := h.sessionService.Logout(c.Request.Context(), userID, sessionID); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "logged out"})
+}
+
+// LogoutAll ends every session of the user, on all devices
+func (h *SessionHandler) LogoutAll(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	revoked, err := h.sessionService.LogoutAll(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LogoutAllResponse{Message: "logged out of all sessions", Revoked: revoked})
+}
+
+func (h *SessionHandler) List(c *gin.Context) {
+	userID, sessionID, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	response := make([]SessionResponse, len(sessions))
+	for i, s := range sessions {
+		response[i] = SessionResponse{Session: s, Current: s.ID == sessionID}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
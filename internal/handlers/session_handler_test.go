@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/auth"
+	"concurrent-wallet-order-system/internal/middleware"
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAuthUserTakesTheTokensUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	caller := primitive.NewObjectID()
+	signer := auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "test", time.Minute)
+	token, _, err := signer.Issue(caller.Hex(), primitive.NewObjectID().Hex(), time.Now())
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"no userId", "", nil},
+		{"caller's userId", caller.Hex(), nil},
+		{"someone else's userId", primitive.NewObjectID().Hex(), services.ErrUserMismatch},
+		{"malformed userId", "not-an-id", services.ErrUserMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/orders/buy", nil)
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+			middleware.Authenticate(signer)(c)
+
+			userID, err := authUser(c, tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && userID != caller {
+				t.Errorf("user = %s, want the caller %s", userID.Hex(), caller.Hex())
+			}
+		})
+	}
+}
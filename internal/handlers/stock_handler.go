@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"bytes"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
 	"concurrent-wallet-order-system/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -10,55 +17,251 @@ import (
 
 type StockHandler struct {
 	stockService *services.StockService
+	orderService *services.OrderService
+	logger       *slog.Logger
 }
 
-func NewStockHandler(stockService *services.StockService) *StockHandler {
+func NewStockHandler(
+	stockService *services.StockService,
+	orderService *services.OrderService,
+	logger *slog.Logger,
+) *StockHandler {
 	return &StockHandler{
 		stockService: stockService,
+		orderService: orderService,
+		logger:       logger,
 	}
 }
 
 type CreateStockRequest struct {
-	Symbol string  `json:"symbol" binding:"required"`
-	Name   string  `json:"name" binding:"required"`
-	Price  float64 `json:"price" binding:"required"`
+	Symbol    string  `json:"symbol" binding:"required"`
+	Name      string  `json:"name" binding:"required"`
+	Price     float64 `json:"price" binding:"required"`
+	Sector    string  `json:"sector"`
+	Industry  string  `json:"industry"`
+	Exchange  string  `json:"exchange"`
+	MarketCap float64 `json:"marketCap"`
+}
+
+type StockPriceRequest struct {
+	Price float64 `json:"price" binding:"required"`
+}
+
+type StockStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+type DelistStockRequest struct {
+	FinalPrice float64 `json:"finalPrice" binding:"required"`
+	Mode       string  `json:"mode" binding:"required"` // LIQUIDATE or FREEZE
 }
 
 func (h *StockHandler) CreateStock(c *gin.Context) {
 	var req CreateStockRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, badRequest(err.Error()))
 		return
 	}
 
-	stock, err := h.stockService.CreateStock(req.Symbol, req.Name, req.Price)
+	stock, err := h.stockService.CreateStock(c.Request.Context(), &models.Stock{
+		Symbol:    req.Symbol,
+		Name:      req.Name,
+		Price:     req.Price,
+		Sector:    req.Sector,
+		Industry:  req.Industry,
+		Exchange:  req.Exchange,
+		MarketCap: req.MarketCap,
+	})
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, stock)
 }
 
+// GetAllStocks lists stocks. Supported query parameters:
+// q or prefix, sector (comma separated), industry, exchange, minPrice, maxPrice,
+// sort (prefix with "-" for descending), page, limit and includeDelisted.
+// The total number of matches is returned in the X-Total-Count header.
 func (h *StockHandler) GetAllStocks(c *gin.Context) {
-	stocks, err := h.stockService.GetAllStocks()
+	filter := repo.StockFilter{
+		Text:            c.Query("q"),
+		Prefix:          c.Query("prefix"),
+		Industry:        c.Query("industry"),
+		Exchange:        c.Query("exchange"),
+		IncludeDelisted: c.Query("includeDelisted") == "true",
+	}
+
+	if sectors := c.Query("sector"); sectors != "" {
+		filter.Sectors = strings.Split(sectors, ",")
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		filter.SortDesc = strings.HasPrefix(sort, "-")
+		filter.SortBy = strings.TrimPrefix(sort, "-")
+	}
+
+	var err error
+	if filter.MinPrice, err = queryFloat(c, "minPrice"); err != nil {
+		respondError(c, h.logger, badRequest("invalid minPrice"))
+		return
+	}
+	if filter.MaxPrice, err = queryFloat(c, "maxPrice"); err != nil {
+		respondError(c, h.logger, badRequest("invalid maxPrice"))
+		return
+	}
+	if filter.Page, err = queryInt(c, "page"); err != nil {
+		respondError(c, h.logger, badRequest("invalid page"))
+		return
+	}
+	if filter.Limit, err = queryInt(c, "limit"); err != nil {
+		respondError(c, h.logger, badRequest("invalid limit"))
+		return
+	}
+
+	stocks, total, err := h.stockService.SearchStocks(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
 	c.JSON(http.StatusOK, stocks)
 }
 
 func (h *StockHandler) GetStock(c *gin.Context) {
 	symbol := c.Param("symbol")
 
-	stock, err := h.stockService.GetStockBySymbol(symbol)
+	stock, err := h.stockService.GetStockBySymbol(c.Request.Context(), symbol)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stock)
+}
+
+func (h *StockHandler) UpdatePrice(c *gin.Context) {
+	var req StockPriceRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	stock, err := h.stockService.UpdatePrice(c.Request.Context(), c.Param("symbol"), req.Price)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stock)
+}
+
+func (h *StockHandler) SetStatus(c *gin.Context) {
+	var req StockStatusRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	stock, err := h.stockService.SetStatus(c.Request.Context(), c.Param("symbol"), req.Status)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		respondError(c, h.logger, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, stock)
 }
+
+func (h *StockHandler) Delist(c *gin.Context) {
+	var req DelistStockRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	result, err := h.orderService.DelistStock(c.Request.Context(), c.Param("symbol"), req.FinalPrice, req.Mode)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Upper bound on the size of a bulk import upload
+const maxImportBytes = 10 << 20
+
+// Import bulk-upserts stocks from a CSV or JSON body. The format comes from
+// the format query parameter, falling back to the Content-Type.
+func (h *StockHandler) Import(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = services.StockFormatJSON
+		if strings.Contains(c.ContentType(), "csv") {
+			format = services.StockFormatCSV
+		}
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	result, err := h.stockService.ImportStocksFrom(c.Request.Context(), body, format)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *StockHandler) Export(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", services.StockFormatJSON))
+
+	var buf bytes.Buffer
+	if err := h.stockService.ExportStocks(c.Request.Context(), &buf, format); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	contentType := "application/json"
+	if format == services.StockFormatCSV {
+		contentType = "text/csv"
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=stocks."+format)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+func queryFloat(c *gin.Context, key string) (float64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func queryInt(c *gin.Context, key string) (int64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// queryTime parses an RFC 3339 timestamp or a date, which means its UTC
+// midnight
+func queryTime(c *gin.Context, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler manages the caller's own two-factor enrolment
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+	logger           *slog.Logger
+}
+
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService, logger *slog.Logger) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		logger:           logger,
+	}
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"` // render as a QR code for authenticator apps
+}
+
+type BackupCodesResponse struct {
+	Message     string   `json:"message"`
+	BackupCodes []string `json:"backupCodes"` // shown once; each works once
+}
+
+func (h *TwoFactorHandler) Status(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	status, err := h.twoFactorService.Status(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	secret, uri, err := h.twoFactorService.Setup(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorSetupResponse{Secret: secret, OTPAuthURI: uri})
+}
+
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	var req TwoFactorCodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	codes, err := h.twoFactorService.Enable(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, BackupCodesResponse{Message: "two-factor authentication enabled", BackupCodes: codes})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req TwoFactorCodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	if err := h.twoFactorService.Disable(c.Request.Context(), userID, req.Code, c.ClientIP()); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "two-factor authentication disabled"})
+}
+
+func (h *TwoFactorHandler) RegenerateBackupCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateBackupCodes(c.Request.Context(), userID, req.Code, c.ClientIP())
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, BackupCodesResponse{Message: "backup codes replaced", BackupCodes: codes})
+}
@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
 	"concurrent-wallet-order-system/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -10,12 +16,26 @@ import (
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService      *services.UserService
+	sessionService   *services.SessionService
+	twoFactorService *services.TwoFactorService
+	accountService   *services.AccountService
+	logger           *slog.Logger
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
+func NewUserHandler(
+	userService *services.UserService,
+	sessionService *services.SessionService,
+	twoFactorService *services.TwoFactorService,
+	accountService *services.AccountService,
+	logger *slog.Logger,
+) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:      userService,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+		accountService:   accountService,
+		logger:           logger,
 	}
 }
 
@@ -30,23 +50,47 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type UserIDResponse struct {
+	Message string             `json:"message"`
+	UserID  primitive.ObjectID `json:"userId"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP or backup code
+}
+
+// LoginResponse carries the tokens of a new session or, for users with
+// two-factor authentication, a challenge to complete at /login/2fa
+type LoginResponse struct {
+	UserIDResponse
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
+	*TokenResponse
+}
+
 func (h *UserHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, badRequest(err.Error()))
 		return
 	}
 
-	user, err := h.userService.Register(req.Name, req.Email, req.Password)
+	user, err := h.userService.Register(c.Request.Context(), req.Name, req.Email, req.Password)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "user registered successfully",
-		"userId":  user.ID,
+	// The account exists either way; the user can ask for another link
+	if err := h.accountService.SendVerification(c.Request.Context(), user.ID); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "sending verification email failed", "user_id", user.ID.Hex(), "error", err)
+	}
+
+	c.JSON(http.StatusCreated, UserIDResponse{
+		Message: "user registered successfully; check your inbox to verify your email",
+		UserID:  user.ID,
 	})
 }
 
@@ -54,45 +98,211 @@ func (h *UserHandler) Login(c *gin.Context) {
 	var req LoginRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	user, err := h.userService.Login(c.Request.Context(), req.Email, req.Password, c.ClientIP())
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+	logging.SetUserID(c.Request.Context(), user.ID.Hex())
+
+	if user.TwoFactorEnabled() {
+		challenge, _, err := h.twoFactorService.Challenge(user)
+		if err != nil {
+			respondError(c, h.logger, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, LoginResponse{
+			UserIDResponse:    UserIDResponse{Message: "second factor required", UserID: user.ID},
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		})
+		return
+	}
+
+	h.startSession(c, user.ID)
+}
+
+// LoginTwoFactor completes a login with the second factor
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorLoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	user, err := h.twoFactorService.CompleteLogin(c.Request.Context(), req.ChallengeToken, req.Code, c.ClientIP())
+	if err != nil {
+		respondError(c, h.logger, err)
 		return
 	}
+	logging.SetUserID(c.Request.Context(), user.ID.Hex())
 
-	user, err := h.userService.Login(req.Email, req.Password)
+	h.startSession(c, user.ID)
+}
+
+func (h *UserHandler) startSession(c *gin.Context, userID primitive.ObjectID) {
+	tokens, err := h.sessionService.Start(c.Request.Context(), userID, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "login successful",
-		"userId":  user.ID,
+	response := newTokenResponse(tokens)
+	c.JSON(http.StatusOK, LoginResponse{
+		UserIDResponse: UserIDResponse{Message: "login successful", UserID: userID},
+		TokenResponse:  &response,
 	})
 }
 
+// GetUser returns a profile; callers other than the user need the admin role
 func (h *UserHandler) GetUser(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
 	userIDParam := c.Param("userId")
 
 	userID, err := primitive.ObjectIDFromHex(userIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		respondError(c, h.logger, badRequest("invalid userId"))
 		return
 	}
 
-	user, err := h.userService.GetUserByID(userID)
+	user, err := h.userService.GetUser(c.Request.Context(), callerID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
+
+// Unlock lifts a login lockout; admin only
+func (h *UserHandler) Unlock(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid userId"))
+		return
+	}
+
+	if err := h.userService.UnlockUser(c.Request.Context(), userID); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "account unlocked"})
+}
+
+// UserSummary is a user as listed by the admin search, without the
+// two-factor and identity document details of the full profile
+type UserSummary struct {
+	ID            primitive.ObjectID `json:"id"`
+	Name          string             `json:"name"`
+	Email         string             `json:"email"`
+	EmailVerified bool               `json:"emailVerified"`
+	Role          string             `json:"role"`
+	Status        string             `json:"status"`
+	KYCStatus     string             `json:"kycStatus"`
+	KYCTier       int                `json:"kycTier"`
+	WalletBalance float64            `json:"walletbalance"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	ClosedAt      *time.Time         `json:"closedAt,omitempty"`
+}
+
+// GetAllUsers searches the users, newest first; admin role only
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
-	users, err := h.userService.GetAllUsers()
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	filter := repo.UserFilter{
+		Name:   c.Query("name"),
+		Email:  c.Query("email"),
+		Status: c.Query("status"),
+	}
+
+	if filter.CreatedFrom, err = queryTime(c, "createdFrom"); err != nil {
+		respondError(c, h.logger, badRequest("invalid createdFrom"))
+		return
+	}
+	if filter.CreatedTo, err = queryTime(c, "createdTo"); err != nil {
+		respondError(c, h.logger, badRequest("invalid createdTo"))
+		return
+	}
+	if filter.Page, err = queryInt(c, "page"); err != nil {
+		respondError(c, h.logger, badRequest("invalid page"))
+		return
+	}
+	if filter.Limit, err = queryInt(c, "limit"); err != nil {
+		respondError(c, h.logger, badRequest("invalid limit"))
+		return
+	}
+
+	users, total, err := h.userService.SearchUsers(c.Request.Context(), callerID, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	summaries := make([]UserSummary, len(users))
+	for i, u := range users {
+		role := u.Role
+		if role == "" {
+			role = models.RoleUser
+		}
+		summaries[i] = UserSummary{
+			ID:            u.ID,
+			Name:          u.Name,
+			Email:         u.Email,
+			EmailVerified: u.EmailVerified,
+			Role:          role,
+			Status:        u.Status(),
+			KYCStatus:     u.KYCStatus(),
+			KYCTier:       u.KYCTier(),
+			WalletBalance: u.WalletBalance,
+			CreatedAt:     u.CreatedAt,
+			ClosedAt:      u.ClosedAt,
+		}
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, summaries)
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// SetRole grants or revokes the admin role; admin only
+func (h *UserHandler) SetRole(c *gin.Context) {
+	var req SetRoleRequest
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid userId"))
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	user, err := h.userService.SetRole(c.Request.Context(), userID, req.Role)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
 }
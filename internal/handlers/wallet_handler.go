@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log/slog"
 	"net/http"
 
 	"concurrent-wallet-order-system/internal/services"
@@ -11,103 +12,120 @@ import (
 
 type WalletHandler struct {
 	walletService *services.WalletService
+	logger        *slog.Logger
 }
 
-func NewWalletHandler(walletService *services.WalletService) *WalletHandler {
+func NewWalletHandler(walletService *services.WalletService, logger *slog.Logger) *WalletHandler {
 	return &WalletHandler{
 		walletService: walletService,
+		logger:        logger,
 	}
 }
 
+// WalletRequest acts on the user of the access token. UserID is optional and,
+// if sent, must be that user.
 type WalletRequest struct {
-	UserID string  `json:"userId" binding:"required"`
+	UserID string  `json:"userId,omitempty"`
 	Amount float64 `json:"amount" binding:"required"`
 }
 
+type WithdrawRequest struct {
+	WalletRequest
+	Code string `json:"code"` // second factor, needed above the step-up amount
+}
+
+type BalanceResponse struct {
+	Balance float64 `json:"balance"`
+}
+
 func (h *WalletHandler) Deposit(c *gin.Context) {
 	var req WalletRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, badRequest(err.Error()))
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	userID, err := authUser(c, req.UserID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	err = h.walletService.Deposit(userID, req.Amount)
+	err = h.walletService.Deposit(c.Request.Context(), userID, req.Amount)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "deposit successful",
-	})
+	c.JSON(http.StatusOK, MessageResponse{Message: "deposit successful"})
 }
 
 func (h *WalletHandler) Withdraw(c *gin.Context) {
-	var req WalletRequest
+	var req WithdrawRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, badRequest(err.Error()))
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	userID, err := authUser(c, req.UserID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	err = h.walletService.Withdraw(userID, req.Amount)
+	err = h.walletService.Withdraw(c.Request.Context(), userID, req.Amount, req.Code)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "withdraw successful",
-	})
+	c.JSON(http.StatusOK, MessageResponse{Message: "withdraw successful"})
 }
 
-
 func (h *WalletHandler) GetBalance(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
 	userIDParam := c.Param("userId")
 
 	userID, err := primitive.ObjectIDFromHex(userIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		respondError(c, h.logger, badRequest("invalid userId"))
 		return
 	}
 
-	balance, err := h.walletService.GetBalance(userID)
+	balance, err := h.walletService.GetBalance(c.Request.Context(), callerID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"balance": balance,
-	})
+	c.JSON(http.StatusOK, BalanceResponse{Balance: balance})
 }
 
-
 func (h *WalletHandler) GetHistory(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
 	userIDParam := c.Param("userId")
 
 	userID, err := primitive.ObjectIDFromHex(userIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
+		respondError(c, h.logger, badRequest("invalid userId"))
 		return
 	}
 
-	history, err := h.walletService.GetHistory(userID)
+	history, err := h.walletService.GetHistory(c.Request.Context(), callerID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, h.logger, err)
 		return
 	}
 
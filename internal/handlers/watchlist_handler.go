@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"concurrent-wallet-order-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WatchlistHandler struct {
+	watchlistService *services.WatchlistService
+	logger           *slog.Logger
+}
+
+func NewWatchlistHandler(watchlistService *services.WatchlistService, logger *slog.Logger) *WatchlistHandler {
+	return &WatchlistHandler{
+		watchlistService: watchlistService,
+		logger:           logger,
+	}
+}
+
+type CreateWatchlistRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Symbols []string `json:"symbols"`
+}
+
+type UpdateWatchlistRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Symbols []string `json:"symbols"`
+}
+
+type WatchlistSymbolRequest struct {
+	Symbol string `json:"symbol" binding:"required"`
+}
+
+func (h *WatchlistHandler) Create(c *gin.Context) {
+	var req CreateWatchlistRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	// Watchlists are created for the user of the access token
+	userID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	watchlist, err := h.watchlistService.CreateWatchlist(c.Request.Context(), userID, req.Name, req.Symbols)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, watchlist)
+}
+
+func (h *WatchlistHandler) GetUserWatchlists(c *gin.Context) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid userId"))
+		return
+	}
+
+	watchlists, err := h.watchlistService.GetUserWatchlists(c.Request.Context(), callerID, userID)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlists)
+}
+
+func (h *WatchlistHandler) Get(c *gin.Context) {
+	callerID, id, ok := h.watchlistID(c)
+	if !ok {
+		return
+	}
+
+	watchlist, err := h.watchlistService.GetWatchlist(c.Request.Context(), callerID, id)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlist)
+}
+
+func (h *WatchlistHandler) Update(c *gin.Context) {
+	callerID, id, ok := h.watchlistID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateWatchlistRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	watchlist, err := h.watchlistService.UpdateWatchlist(c.Request.Context(), callerID, id, req.Name, req.Symbols)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlist)
+}
+
+func (h *WatchlistHandler) AddSymbol(c *gin.Context) {
+	callerID, id, ok := h.watchlistID(c)
+	if !ok {
+		return
+	}
+
+	var req WatchlistSymbolRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, h.logger, badRequest(err.Error()))
+		return
+	}
+
+	watchlist, err := h.watchlistService.AddSymbol(c.Request.Context(), callerID, id, req.Symbol)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlist)
+}
+
+func (h *WatchlistHandler) RemoveSymbol(c *gin.Context) {
+	callerID, id, ok := h.watchlistID(c)
+	if !ok {
+		return
+	}
+
+	watchlist, err := h.watchlistService.RemoveSymbol(c.Request.Context(), callerID, id, c.Param("symbol"))
+	if err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlist)
+}
+
+func (h *WatchlistHandler) Delete(c *gin.Context) {
+	callerID, id, ok := h.watchlistID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.watchlistService.DeleteWatchlist(c.Request.Context(), callerID, id); err != nil {
+		respondError(c, h.logger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "watchlist deleted"})
+}
+
+// watchlistID returns the caller and the watchlist id of the path
+func (h *WatchlistHandler) watchlistID(c *gin.Context) (callerID, id primitive.ObjectID, ok bool) {
+	callerID, _, err := authSession(c)
+	if err != nil {
+		respondError(c, h.logger, err)
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	id, err = primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, h.logger, badRequest("invalid watchlist id"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return callerID, id, true
+}
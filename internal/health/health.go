@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DefaultTimeout bounds each check so that one hung dependency cannot stall
+// the whole probe
+const DefaultTimeout = 2 * time.Second
+
+// Check probes one component. Details, if any, are reported alongside the
+// status whether or not the check failed.
+type Check func(ctx context.Context) (details any, err error)
+
+type ComponentStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+	Details   any     `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Checker runs a fixed set of named checks concurrently
+type Checker struct {
+	timeout time.Duration
+	names   []string
+	checks  []Check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Checker{
+		timeout: timeout,
+	}
+}
+
+// Add registers a check. Checks are added during startup, before Run is called.
+func (c *Checker) Add(name string, check Check) *Checker {
+	c.names = append(c.names, name)
+	c.checks = append(c.checks, check)
+	return c
+}
+
+// Run executes every check and reports the overall status, which is up only
+// when every component is up. A check that has not returned by the timeout
+// is reported as down without waiting for it.
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type result struct {
+		index  int
+		status ComponentStatus
+	}
+
+	// Buffered so that late checks never block
+	done := make(chan result, len(c.checks))
+	for i, check := range c.checks {
+		go func() {
+			done <- result{i, run(ctx, check)}
+		}()
+	}
+
+	report := Report{
+		Status:     StatusUp,
+		Components: make(map[string]ComponentStatus, len(c.checks)),
+	}
+
+	start := time.Now()
+collect:
+	for range c.checks {
+		select {
+		case r := <-done:
+			report.Components[c.names[r.index]] = r.status
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	for _, name := range c.names {
+		if _, ok := report.Components[name]; !ok {
+			report.Components[name] = ComponentStatus{
+				Status:    StatusDown,
+				LatencyMs: milliseconds(time.Since(start)),
+				Error:     "check timed out",
+			}
+		}
+		if report.Components[name].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+func run(ctx context.Context, check Check) ComponentStatus {
+	start := time.Now()
+
+	details, err := check(ctx)
+
+	status := ComponentStatus{
+		Status:    StatusUp,
+		LatencyMs: milliseconds(time.Since(start)),
+		Details:   details,
+	}
+
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+
+	return status
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
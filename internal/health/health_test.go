@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckerAllUp(t *testing.T) {
+	report := NewChecker(time.Second).
+		Add("a", func(ctx context.Context) (any, error) { return nil, nil }).
+		Add("b", func(ctx context.Context) (any, error) { return map[string]int{"queued": 3}, nil }).
+		Run(t.Context())
+
+	if report.Status != StatusUp {
+		t.Fatalf("status = %s, want up", report.Status)
+	}
+	if len(report.Components) != 2 {
+		t.Fatalf("components = %v, want a and b", report.Components)
+	}
+	if report.Components["b"].Details == nil {
+		t.Error("details of b were dropped")
+	}
+}
+
+func TestCheckerOneDown(t *testing.T) {
+	report := NewChecker(time.Second).
+		Add("ok", func(ctx context.Context) (any, error) { return nil, nil }).
+		Add("broken", func(ctx context.Context) (any, error) { return nil, errors.New("connection refused") }).
+		Run(t.Context())
+
+	if report.Status != StatusDown {
+		t.Fatalf("status = %s, want down", report.Status)
+	}
+	if got := report.Components["broken"]; got.Status != StatusDown || got.Error != "connection refused" {
+		t.Errorf("broken = %+v", got)
+	}
+	if got := report.Components["ok"]; got.Status != StatusUp {
+		t.Errorf("ok = %+v", got)
+	}
+}
+
+func TestCheckerTimesOutHungCheck(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	report := NewChecker(50*time.Millisecond).
+		Add("hung", func(ctx context.Context) (any, error) {
+			<-release // ignores ctx on purpose
+			return nil, nil
+		}).
+		Run(t.Context())
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Run took %v, want it bounded by the timeout", elapsed)
+	}
+	if got := report.Components["hung"]; got.Status != StatusDown || got.Error != "check timed out" {
+		t.Errorf("hung = %+v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New builds the application logger. Every record logged with a request
+// context carries that request's request_id, user_id and route, and the
+// trace_id and span_id when the context is being traced.
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// Discard returns a logger that drops everything, for tests and tools
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+type ctxKey struct{}
+
+// requestInfo is shared by everything handling one request. The user ID is
+// only known once a handler has parsed it, so it is filled in later.
+type requestInfo struct {
+	mu        sync.Mutex
+	requestID string
+	route     string
+	userID    string
+}
+
+// WithRequest starts the logging context of a request
+func WithRequest(ctx context.Context, requestID, route string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &requestInfo{
+		requestID: requestID,
+		route:     route,
+	})
+}
+
+// SetUserID records the user a request acts for. It is a no-op outside a request.
+func SetUserID(ctx context.Context, userID string) {
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		info.mu.Lock()
+		info.userID = userID
+		info.mu.Unlock()
+	}
+}
+
+// RequestID returns the ID of the request ctx belongs to, if any
+func RequestID(ctx context.Context) string {
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		return info.requestID
+	}
+	return ""
+}
+
+// UserID returns the user recorded with SetUserID, if any
+func UserID(ctx context.Context) string {
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		info.mu.Lock()
+		defer info.mu.Unlock()
+		return info.userID
+	}
+	return ""
+}
+
+// contextHandler adds the request attributes found in the context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info, ok := ctx.Value(ctxKey{}).(*requestInfo); ok {
+		info.mu.Lock()
+		r.AddAttrs(slog.String("request_id", info.requestID), slog.String("route", info.route))
+		if info.userID != "" {
+			r.AddAttrs(slog.String("user_id", info.userID))
+		}
+		info.mu.Unlock()
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,92 @@
+// Package mail delivers transactional email, such as verification and
+// password reset links. Senders are pluggable; the ones here are for local
+// development and write the messages out instead of sending them.
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers a message
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// ConsoleSender writes each message to a writer, e.g. os.Stdout
+type ConsoleSender struct {
+	from string
+	w    io.Writer
+	mu   sync.Mutex
+}
+
+func NewConsoleSender(from string, w io.Writer) *ConsoleSender {
+	return &ConsoleSender{
+		from: from,
+		w:    w,
+	}
+}
+
+func (s *ConsoleSender) Send(ctx context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "----- mail -----\r\n%s----- end mail -----\r\n", render(s.from, msg, time.Now()))
+	return err
+}
+
+// FileSender writes each message to a directory as an .eml file
+type FileSender struct {
+	from string
+	dir  string
+}
+
+func NewFileSender(from, dir string) *FileSender {
+	return &FileSender{
+		from: from,
+		dir:  dir,
+	}
+}
+
+func (s *FileSender) Send(ctx context.Context, msg *Message) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	// Sortable by time, and unique within the same second
+	now := time.Now()
+	name := now.UTC().Format("20060102T150405Z") + "-" + rand.Text()[:8] + ".eml"
+	return os.WriteFile(filepath.Join(s.dir, name), []byte(render(s.from, msg, now)), 0o600)
+}
+
+func render(from string, msg *Message, date time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", header(from))
+	fmt.Fprintf(&b, "To: %s\r\n", header(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// header drops line breaks from a header value, so that an address a user
+// registered cannot add headers of its own
+func header(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
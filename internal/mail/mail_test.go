@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := NewFileSender("Wallet <no-reply@example.com>", dir)
+
+	err := sender.Send(t.Context(), &Message{
+		To:      "alice@example.com\r\nBcc: mallory@example.com",
+		Subject: "Verify your email",
+		Body:    "Hi Alice,\n\nyour code is 123.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	raw, _ := os.ReadFile(files[0])
+	msg := string(raw)
+
+	if !strings.Contains(msg, "To: alice@example.comBcc: mallory@example.com\r\n") {
+		t.Errorf("line break in a header was kept:\n%s", msg)
+	}
+	if !strings.HasSuffix(msg, "\r\n\r\nHi Alice,\r\n\r\nyour code is 123.\r\n") {
+		t.Errorf("body = %q", msg)
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Outcome label values
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected" // refused by a business rule, e.g. insufficient balance
+	OutcomeError    = "error"    // failed in the backing store
+)
+
+// Registry holds every collector served on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	Trades = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "trades_total",
+		Help: "Buy and sell orders by side and outcome.",
+	}, []string{"side", "outcome"})
+
+	WalletOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_operations_total",
+		Help: "Deposits and withdrawals requested through the wallet API, by outcome.",
+	}, []string{"operation", "outcome"})
+
+	LockWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lock_wait_seconds",
+		Help:    "Time spent waiting to acquire a service mutex.",
+		Buckets: []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"lock"})
+
+	OrdersInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "orders_in_flight",
+		Help: "Orders accepted but not yet filled or rejected, including those waiting for the order lock.",
+	})
+
+	LoginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "login_attempts_total",
+		Help: "Login attempts by result: success, an error code such as invalid_credentials or account_locked, or error.",
+	}, []string{"result"})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limited_requests_total",
+		Help: "Requests rejected with 429 by rate-limit policy and bucket scope (ip or user).",
+	}, []string{"policy", "scope"})
+
+	AlertPriceChangesDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "alert_price_changes_dropped_total",
+		Help: "Price changes not evaluated for alerts because the evaluator queue was full.",
+	})
+
+	MongoCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_command_duration_seconds",
+		Help:    "MongoDB command latency by command name and outcome.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		Trades,
+		WalletOperations,
+		LockWait,
+		OrdersInFlight,
+		LoginAttempts,
+		RateLimited,
+		AlertPriceChangesDropped,
+		MongoCommandDuration,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveLockWait records how long the caller waited for lock since start
+func ObserveLockWait(lock string, start time.Time) {
+	LockWait.WithLabelValues(lock).Observe(time.Since(start).Seconds())
+}
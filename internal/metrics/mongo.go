@@ -0,0 +1,19 @@
+package metrics
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MongoMonitor records the latency of every command the driver runs
+func MongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			MongoCommandDuration.WithLabelValues(e.CommandName, OutcomeSuccess).Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			MongoCommandDuration.WithLabelValues(e.CommandName, OutcomeError).Observe(e.Duration.Seconds())
+		},
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/audit"
+	"concurrent-wallet-order-system/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	signer := auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "test", time.Minute)
+	valid, _, _ := signer.Issue("user-1", "session-1", time.Now())
+	expired, _, _ := signer.Issue("user-1", "session-1", time.Now().Add(-time.Hour))
+
+	router := gin.New()
+	router.Use(Authenticate(signer))
+	router.GET("/public", func(c *gin.Context) { c.String(http.StatusOK, AuthenticatedUser(c)) })
+	router.GET("/private", RequireAuth(), func(c *gin.Context) { c.String(http.StatusOK, AuthenticatedSession(c)) })
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"anonymous public", "/public", "", http.StatusOK, ""},
+		{"authenticated public", "/public", "Bearer " + valid, http.StatusOK, "user-1"},
+		{"anonymous private", "/private", "", http.StatusUnauthorized, ""},
+		{"authenticated private", "/private", "bearer " + valid, http.StatusOK, "session-1"},
+		{"expired", "/public", "Bearer " + expired, http.StatusUnauthorized, ""},
+		{"forged", "/public", "Bearer " + valid + "x", http.StatusUnauthorized, ""},
+		{"basic auth", "/public", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code == http.StatusOK && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAuditActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	signer := auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "test", time.Minute)
+	token, _, _ := signer.Issue("user-1", "session-1", time.Now())
+
+	actor := func(c *gin.Context) {
+		a, ip := audit.From(c.Request.Context())
+		c.String(http.StatusOK, a.Kind+":"+a.ID+"@"+ip)
+	}
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/api", Authenticate(signer), actor)
+	router.GET("/admin", AdminAuth("secret"), actor)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		want   string
+	}{
+		{"anonymous", "/api", "", "", "anonymous:@192.0.2.1"},
+		{"user", "/api", "Authorization", "Bearer " + token, "user:user-1@192.0.2.1"},
+		{"admin", "/admin", AdminKeyHeader, "secret", "admin:@192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Body.String() != tt.want {
+				t.Errorf("actor = %q, want %q", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks every response of a route group as deprecated (RFC 9745)
+// with its sunset date (RFC 8594), and links to the same path under
+// successorPrefix so clients can find the replacement.
+func Deprecated(since, sunset time.Time, successorPrefix string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", since.Unix())
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("Deprecation", deprecation)
+		h.Set("Sunset", sunsetDate)
+		h.Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successorPrefix, c.Request.URL.Path))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeprecated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	since := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 4, 30, 0, 0, 0, 0, time.UTC)
+
+	router := gin.New()
+	router.GET("/portfolio/:userId", Deprecated(since, sunset, "/api/v1"), func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/portfolio/abc", nil))
+
+	// Error responses are deprecated too
+	for header, want := range map[string]string{
+		"Deprecation": "@1792368000",
+		"Sunset":      "Fri, 30 Apr 2027 00:00:00 GMT",
+		"Link":        `</api/v1/portfolio/abc>; rel="successor-version"`,
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
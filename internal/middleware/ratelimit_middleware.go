@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"concurrent-wallet-order-system/internal/metrics"
+	"concurrent-wallet-order-system/internal/problem"
+	"concurrent-wallet-order-system/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit draws a token from the client IP's bucket and, once the request
+// is authenticated, from the user's bucket of the policy. Each policy has its
+// own buckets. An empty bucket aborts with 429 and a Retry-After header. If
+// the store fails the request is let through: an unavailable store should
+// not take the API down with it.
+func RateLimit(store ratelimit.Store, policy ratelimit.Policy, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checks := []struct {
+			scope string
+			id    string
+			limit ratelimit.Limit
+		}{
+			{"ip", c.ClientIP(), policy.PerIP},
+			{"user", AuthenticatedUser(c), policy.PerUser},
+		}
+
+		for _, check := range checks {
+			if check.id == "" || !check.limit.Enabled() {
+				continue
+			}
+
+			key := fmt.Sprintf("%s:%s:%s", policy.Name, check.scope, check.id)
+			result, err := store.Take(c.Request.Context(), key, check.limit)
+			if err != nil {
+				logger.WarnContext(c.Request.Context(), "rate limit check failed, allowing request",
+					"policy", policy.Name, "scope", check.scope, "error", err)
+				continue
+			}
+
+			if !result.Allowed {
+				retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+				metrics.RateLimited.WithLabelValues(policy.Name, check.scope).Inc()
+				logger.InfoContext(c.Request.Context(), "request rate limited",
+					"policy", policy.Name, "scope", check.scope, "client_ip", c.ClientIP(), "retry_after_s", retryAfter)
+
+				c.Header("Retry-After", strconv.Itoa(retryAfter))
+				problem.Abort(c, problem.New(http.StatusTooManyRequests, "rate_limited",
+					fmt.Sprintf("too many requests, retry in %d seconds", retryAfter)))
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store unavailable")
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policy := ratelimit.Policy{
+		Name:    "trading",
+		PerIP:   ratelimit.Limit{Requests: 3, Period: time.Minute},
+		PerUser: ratelimit.Limit{Requests: 1, Period: time.Minute},
+	}
+
+	newRouter := func(store ratelimit.Store) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if user := c.GetHeader("X-Test-User"); user != "" {
+				SetAuthenticatedUser(c, user)
+			}
+		}, RateLimit(store, policy, logging.Discard()))
+		router.POST("/orders/buy", func(c *gin.Context) { c.Status(http.StatusCreated) })
+		return router
+	}
+
+	send := func(router *gin.Engine, ip, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders/buy", nil)
+		req.RemoteAddr = ip + ":1234"
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	router := newRouter(ratelimit.NewMemoryStore())
+
+	// The user bucket runs out first
+	if w := send(router, "10.0.0.1", "alice"); w.Code != http.StatusCreated {
+		t.Fatalf("first request: status = %d", w.Code)
+	}
+	if w := send(router, "10.0.0.1", "alice"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("second request by alice: status = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// Another user from the same IP still has tokens, until the IP runs out
+	if w := send(router, "10.0.0.1", "bob"); w.Code != http.StatusCreated {
+		t.Fatalf("bob: status = %d", w.Code)
+	}
+	w := send(router, "10.0.0.1", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "20" {
+		t.Fatalf("fourth request from the IP: status = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("content type = %q", ct)
+	}
+
+	if w := send(router, "10.0.0.2", ""); w.Code != http.StatusCreated {
+		t.Errorf("other IP: status = %d", w.Code)
+	}
+
+	// A failing store lets requests through
+	if w := send(newRouter(failingStore{}), "10.0.0.1", "alice"); w.Code != http.StatusCreated {
+		t.Errorf("failing store: status = %d", w.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"concurrent-wallet-order-system/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelDebug, "json")
+
+	router := gin.New()
+	router.Use(RequestID(), AccessLog(logger))
+	router.GET("/portfolio/:userId", func(c *gin.Context) {
+		logger.InfoContext(c.Request.Context(), "handler")
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		reuse    bool
+	}{
+		{"propagated", "abc-123.X_y", true},
+		{"missing", "", false},
+		{"invalid", "bad id\n", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+
+			req := httptest.NewRequest(http.MethodGet, "/portfolio/507f1f77bcf86cd799439011", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.reuse && id != tt.incoming {
+				t.Fatalf("request ID = %q, want %q", id, tt.incoming)
+			}
+			if !tt.reuse && (id == tt.incoming || !validRequestID(id)) {
+				t.Fatalf("request ID = %q, want a newly generated one", id)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got %d log lines, want handler and access log:\n%s", len(lines), buf.String())
+			}
+
+			for _, line := range lines {
+				var record map[string]any
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("log line is not JSON: %v", err)
+				}
+				if record["request_id"] != id {
+					t.Errorf("request_id = %v, want %q", record["request_id"], id)
+				}
+				if record["route"] != "/portfolio/:userId" {
+					t.Errorf("route = %v", record["route"])
+				}
+				if record["user_id"] != "507f1f77bcf86cd799439011" {
+					t.Errorf("user_id = %v", record["user_id"])
+				}
+			}
+
+			var access map[string]any
+			json.Unmarshal([]byte(lines[1]), &access)
+			if access["status"] != float64(http.StatusOK) || access["latency_ms"] == nil {
+				t.Errorf("access log = %v", access)
+			}
+		})
+	}
+}
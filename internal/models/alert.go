@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Alert conditions. ABOVE and BELOW compare the price with Threshold;
+// PCT_RISE and PCT_DROP compare the day change in percent.
+const (
+	AlertConditionAbove   = "ABOVE"
+	AlertConditionBelow   = "BELOW"
+	AlertConditionPctRise = "PCT_RISE"
+	AlertConditionPctDrop = "PCT_DROP"
+)
+
+const (
+	AlertStatusActive    = "ACTIVE"
+	AlertStatusTriggered = "TRIGGERED"
+)
+
+type Alert struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"userId" json:"userId"`
+	Symbol       string             `bson:"symbol" json:"symbol"`
+	Condition    string             `bson:"condition" json:"condition"`
+	Threshold    float64            `bson:"threshold" json:"threshold"`
+	Status       string             `bson:"status" json:"status"`
+	TriggerPrice float64            `bson:"triggerPrice,omitempty" json:"triggerPrice,omitempty"`
+	TriggeredAt  *time.Time         `bson:"triggeredAt,omitempty" json:"triggeredAt,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditWalletDeposit    = "WALLET_DEPOSIT"
+	AuditWalletWithdrawal = "WALLET_WITHDRAWAL"
+	AuditOrderBuy         = "ORDER_BUY"
+	AuditOrderSell        = "ORDER_SELL"
+	AuditStockCreated     = "STOCK_CREATED"
+	AuditStocksImported   = "STOCKS_IMPORTED"
+	AuditRoleChanged      = "ROLE_CHANGED"
+)
+
+// Audit target types
+const (
+	AuditTargetUser  = "user"
+	AuditTargetOrder = "order"
+	AuditTargetStock = "stock"
+)
+
+// AuditEntry records one state change for compliance. Entries are only ever
+// inserted. Each carries the hash of the one before it, so editing or
+// deleting an entry breaks the chain from there on.
+type AuditEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Seq        int64              `bson:"seq" json:"seq"` // position in the chain, from 1 (unique index)
+	At         time.Time          `bson:"at" json:"at"`
+	ActorType  string             `bson:"actorType" json:"actorType"` // user, admin, anonymous or system
+	ActorID    string             `bson:"actorId,omitempty" json:"actorId,omitempty"`
+	Action     string             `bson:"action" json:"action"`
+	TargetType string             `bson:"targetType" json:"targetType"`
+	TargetID   string             `bson:"targetId,omitempty" json:"targetId,omitempty"`
+	Before     json.RawMessage    `bson:"before,omitempty" json:"before,omitempty"` // JSON snapshot; none for creations
+	After      json.RawMessage    `bson:"after,omitempty" json:"after,omitempty"`
+	IP         string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	RequestID  string             `bson:"requestId,omitempty" json:"requestId,omitempty"`
+	PrevHash   string             `bson:"prevHash" json:"prevHash"` // empty for the first entry
+	Hash       string             `bson:"hash" json:"hash"`         // SHA-256 of the entry's contents and PrevHash
+}
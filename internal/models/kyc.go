@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KYC statuses. A user starts unverified, submits documents to become
+// pending, and an admin moves them to verified or rejected; a rejected user
+// can submit again.
+const (
+	KYCUnverified = "unverified"
+	KYCPending    = "pending"
+	KYCVerified   = "verified"
+	KYCRejected   = "rejected"
+)
+
+// KYC tiers pick a user's daily limits. Everyone is on KYCTierNone until an
+// admin approves their documents at one of the others.
+const (
+	KYCTierNone  = 0
+	KYCTierBasic = 1
+	KYCTierFull  = 2
+)
+
+// KYC document types
+const (
+	KYCDocumentPassport       = "passport"
+	KYCDocumentNationalID     = "national_id"
+	KYCDocumentDrivingLicence = "driving_licence"
+	KYCDocumentProofOfAddress = "proof_of_address"
+)
+
+// KYC is a user's identity verification
+type KYC struct {
+	Status          string        `bson:"status" json:"status"`
+	Tier            int           `bson:"tier" json:"tier"`
+	Documents       []KYCDocument `bson:"documents,omitempty" json:"documents,omitempty"`
+	SubmittedAt     *time.Time    `bson:"submittedAt,omitempty" json:"submittedAt,omitempty"`
+	ReviewedAt      *time.Time    `bson:"reviewedAt,omitempty" json:"reviewedAt,omitempty"`
+	RejectionReason string        `bson:"rejectionReason,omitempty" json:"rejectionReason,omitempty"`
+}
+
+// KYCDocument describes an identity document. The scan itself lives in
+// document storage under Reference; only its metadata is kept here.
+type KYCDocument struct {
+	Type      string `bson:"type" json:"type"`
+	Country   string `bson:"country" json:"country"` // ISO 3166-1 alpha-2 code of the issuer
+	Number    string `bson:"number" json:"number"`
+	ExpiresOn string `bson:"expiresOn,omitempty" json:"expiresOn,omitempty"` // YYYY-MM-DD
+	Reference string `bson:"reference" json:"reference"`
+}
+
+// Daily limit kinds
+const (
+	LimitDeposit    = "deposit"
+	LimitWithdrawal = "withdrawal"
+	LimitOrder      = "order" // order notional: quantity × price, buys and sells
+)
+
+// LimitUsage adds up what a user deposited, withdrew and traded on one UTC
+// day. Key is "<user ID>:<YYYY-MM-DD>".
+type LimitUsage struct {
+	Key        string             `bson:"_id" json:"-"`
+	UserID     primitive.ObjectID `bson:"userId" json:"-"`
+	Day        string             `bson:"day" json:"day"`
+	Deposit    float64            `bson:"deposit" json:"deposit"`
+	Withdrawal float64            `bson:"withdrawal" json:"withdrawal"`
+	Order      float64            `bson:"order" json:"order"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"-"` // TTL: the record is dropped after its day
+}
+
+// Amount returns the usage of one kind of limit
+func (u *LimitUsage) Amount(kind string) float64 {
+	switch kind {
+	case LimitDeposit:
+		return u.Deposit
+	case LimitWithdrawal:
+		return u.Withdrawal
+	case LimitOrder:
+		return u.Order
+	}
+	return 0
+}
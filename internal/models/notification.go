@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	AlertID   primitive.ObjectID `bson:"alertId" json:"alertId"`
+	Symbol    string             `bson:"symbol" json:"symbol"`
+	Price     float64            `bson:"price" json:"price"`
+	Message   string             `bson:"message" json:"message"`
+	Read      bool               `bson:"read" json:"read"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
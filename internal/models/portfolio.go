@@ -9,4 +9,9 @@ type Portfolio struct {
 	UserID primitive.ObjectID `bson:"userId" json:"userId"`
 	Symbol string             `bson:"symbol" json:"symbol"`
 	Qty    int                `bson:"quantity" json:"quantity"`
+	Frozen bool               `bson:"frozen,omitempty" json:"frozen,omitempty"` // set when the stock is delisted without liquidation
+
+	// Settling holds the shares a liquidating delisting has taken but not
+	// yet paid for
+	Settling int `bson:"settling,omitempty" json:"settling,omitempty"`
 }
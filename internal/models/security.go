@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginFailures counts recent failed logins for one account or client IP.
+// Key is "account:<email>" or "ip:<address>".
+type LoginFailures struct {
+	Key         string    `bson:"_id" json:"key"`
+	Count       int       `bson:"count" json:"count"`
+	LastFailure time.Time `bson:"lastFailure" json:"lastFailure"`
+	LockedUntil time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	ExpiresAt   time.Time `bson:"expiresAt" json:"-"` // TTL: the record is dropped once it no longer matters
+}
+
+// Security event types
+const (
+	SecurityEventAccountLocked     = "ACCOUNT_LOCKED"
+	SecurityEventAccountUnlocked   = "ACCOUNT_UNLOCKED"
+	SecurityEventIPLocked          = "IP_LOCKED"
+	SecurityEventTokenReused       = "REFRESH_TOKEN_REUSED"
+	SecurityEventTwoFactorEnabled  = "TWO_FACTOR_ENABLED"
+	SecurityEventTwoFactorDisabled = "TWO_FACTOR_DISABLED"
+	SecurityEventPasswordReset     = "PASSWORD_RESET"
+	SecurityEventPasswordChanged   = "PASSWORD_CHANGED"
+	SecurityEventEmailChanged      = "EMAIL_CHANGED"
+	SecurityEventAccountClosed     = "ACCOUNT_CLOSED"
+)
+
+// SecurityEvent is an entry in the security audit log
+type SecurityEvent struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Type      string              `bson:"type" json:"type"`
+	UserID    *primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
+	Email     string              `bson:"email,omitempty" json:"email,omitempty"`
+	IP        string              `bson:"ip,omitempty" json:"ip,omitempty"`
+	Detail    string              `bson:"detail,omitempty" json:"detail,omitempty"`
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+}
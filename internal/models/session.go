@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one login of a user on one device, and the family of refresh
+// tokens rotated from it. Only hashes of the tokens are stored: the current
+// one, and those already exchanged so that their reuse can be detected.
+type Session struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"userId" json:"userId"`
+	RefreshHash    string             `bson:"refreshHash" json:"-"`
+	PreviousHashes []string           `bson:"previousHashes,omitempty" json:"-"`
+	UserAgent      string             `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	IP             string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt     time.Time          `bson:"lastUsedAt" json:"lastUsedAt"`
+	ExpiresAt      time.Time          `bson:"expiresAt" json:"expiresAt"` // TTL: refreshing slides it forward
+	RevokedAt      *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	RevokeReason   string             `bson:"revokeReason,omitempty" json:"revokeReason,omitempty"`
+}
+
+// Session revocation reasons
+const (
+	SessionRevokedLogout    = "logout"
+	SessionRevokedLogoutAll = "logout_all"
+	SessionRevokedReuse     = "refresh_token_reused"
+	SessionRevokedPassword  = "password_reset"
+	SessionRevokedChanged   = "password_changed"
+	SessionRevokedClosed    = "account_closed"
+)
@@ -6,10 +6,37 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type Stock struct{
-	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Symbol string `bson:"symbol" json:"symbol"`
-	Name string `bson:"name" json:"name"`
-	Price float64 `bson:"price" json:"price"`
-	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
-}
\ No newline at end of file
+// Instrument lifecycle states
+const (
+	StockStatusActive   = "ACTIVE"
+	StockStatusHalted   = "HALTED"
+	StockStatusDelisted = "DELISTED"
+)
+
+type Stock struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Symbol     string             `bson:"symbol" json:"symbol"`
+	Name       string             `bson:"name" json:"name"`
+	Price      float64            `bson:"price" json:"price"`
+	PrevClose  float64            `bson:"previousClose,omitempty" json:"previousClose,omitempty"` // reference price for the day change
+	Sector     string             `bson:"sector,omitempty" json:"sector,omitempty"`
+	Industry   string             `bson:"industry,omitempty" json:"industry,omitempty"`
+	Exchange   string             `bson:"exchange,omitempty" json:"exchange,omitempty"`
+	MarketCap  float64            `bson:"marketCap,omitempty" json:"marketCap,omitempty"`
+	Status     string             `bson:"status" json:"status"`
+	PriceAt    *time.Time         `bson:"priceUpdatedAt,omitempty" json:"priceUpdatedAt,omitempty"`
+	DelistedAt *time.Time         `bson:"delistedAt,omitempty" json:"delistedAt,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// CurrentStatus treats stocks created before lifecycle states existed as active.
+func (s *Stock) CurrentStatus() string {
+	if s.Status == "" {
+		return StockStatusActive
+	}
+	return s.Status
+}
+
+func (s *Stock) IsTradeable() bool {
+	return s.CurrentStatus() == StockStatusActive
+}
@@ -10,7 +10,74 @@ type User struct {
 	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name string `bson:"name" json:"name"`
 	Email string `bson:"email" json:"email"`
+	EmailVerified bool `bson:"emailVerified" json:"emailVerified"`
+	EmailVerifiedAt *time.Time `bson:"emailVerifiedAt,omitempty" json:"emailVerifiedAt,omitempty"`
 	Password string `bson:"password" json:"-"`
 	WalletBalance float64 `bson:"walletbalance" json:"walletbalance"`
 	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	TwoFactor *TwoFactor `bson:"twoFactor,omitempty" json:"-"`
+	ClosedAt *time.Time `bson:"closedAt,omitempty" json:"closedAt,omitempty"` // soft delete: the user and their history stay
+	KYC *KYC `bson:"kyc,omitempty" json:"kyc,omitempty"`
+	Role string `bson:"role,omitempty" json:"role,omitempty"` // empty is RoleUser
+	Settlements []string `bson:"settlements,omitempty" json:"-"` // keys of the one-off credits paid, e.g. for delisted shares
+}
+
+// Roles. Admins can search every user and read their profiles.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Account statuses, as filtered on by the admin user search
+const (
+	UserStatusActive = "active"
+	UserStatusClosed = "closed"
+)
+
+// TwoFactor is a user's TOTP enrolment. It is pending, with only a Secret,
+// until the user proves their authenticator works by entering a code.
+type TwoFactor struct {
+	Secret       string     `bson:"secret"`
+	Enabled      bool       `bson:"enabled"`
+	BackupCodes  []string   `bson:"backupCodes,omitempty"` // bcrypt hashes of the unused codes
+	LastUsedStep int64      `bson:"lastUsedStep"`          // TOTP time step of the last accepted code
+	EnabledAt    *time.Time `bson:"enabledAt,omitempty"`
+}
+
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.Enabled
+}
+
+func (u *User) Closed() bool {
+	return u.ClosedAt != nil
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Status returns UserStatusClosed for a closed account, UserStatusActive
+// otherwise
+func (u *User) Status() string {
+	if u.Closed() {
+		return UserStatusClosed
+	}
+	return UserStatusActive
+}
+
+// KYCStatus returns the user's verification status; users who never
+// submitted documents are unverified
+func (u *User) KYCStatus() string {
+	if u.KYC == nil || u.KYC.Status == "" {
+		return KYCUnverified
+	}
+	return u.KYC.Status
+}
+
+// KYCTier returns the tier whose limits apply, KYCTierNone unless verified
+func (u *User) KYCTier() int {
+	if u.KYCStatus() != KYCVerified {
+		return KYCTierNone
+	}
+	return u.KYC.Tier
 }
\ No newline at end of file
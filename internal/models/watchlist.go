@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Watchlist struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Name      string             `bson:"name" json:"name"`
+	Symbols   []string           `bson:"symbols" json:"symbols"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// WatchlistQuote is one watchlist symbol joined with its stock
+type WatchlistQuote struct {
+	Symbol        string  `bson:"symbol" json:"symbol"`
+	Name          string  `bson:"name" json:"name"`
+	Price         float64 `bson:"price" json:"price"`
+	PreviousClose float64 `bson:"previousClose" json:"previousClose"`
+	Status        string  `bson:"status" json:"status"`
+}
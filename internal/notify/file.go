@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FileNotifier appends each notification as a JSON line to a local file. An
+// alert already in the file is not appended again, so a delivery retried
+// because another channel failed is written once.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+	sent map[primitive.ObjectID]bool // alerts in the file, read on first use
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{
+		path: path,
+	}
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, user *models.User, notification *models.Notification) error {
+	line, err := json.Marshal(map[string]any{
+		"time":    notification.CreatedAt,
+		"userId":  notification.UserID,
+		"email":   user.Email,
+		"alertId": notification.AlertID,
+		"symbol":  notification.Symbol,
+		"price":   notification.Price,
+		"message": notification.Message,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.sent == nil {
+		sent, err := readSent(n.path)
+		if err != nil {
+			return err
+		}
+		n.sent = sent
+	}
+	if n.sent[notification.AlertID] {
+		return nil
+	}
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	n.sent[notification.AlertID] = true
+	return nil
+}
+
+// readSent lists the alerts already in a notification file
+func readSent(path string) (map[primitive.ObjectID]bool, error) {
+	sent := map[primitive.ObjectID]bool{}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sent, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line struct {
+			AlertID primitive.ObjectID `json:"alertId"`
+		}
+		// A line cut short by a failed write was not delivered
+		if json.Unmarshal(scanner.Bytes(), &line) == nil {
+			sent[line.AlertID] = true
+		}
+	}
+
+	return sent, scanner.Err()
+}
+
+// SMTPStubNotifier renders each notification as an email and writes it to a
+// directory as an .eml file instead of sending it, for local development. An
+// alert that already has its file is not sent again.
+type SMTPStubNotifier struct {
+	from string
+	dir  string
+}
+
+func NewSMTPStubNotifier(from, dir string) *SMTPStubNotifier {
+	return &SMTPStubNotifier{
+		from: from,
+		dir:  dir,
+	}
+}
+
+func (n *SMTPStubNotifier) Notify(ctx context.Context, user *models.User, notification *models.Notification) error {
+	// Named after the alert, so a retried delivery finds it already sent
+	path := filepath.Join(n.dir, notification.AlertID.Hex()+".eml")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(n.dir, 0o755); err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", user.Email)
+	fmt.Fprintf(&msg, "Subject: Price alert: %s\r\n", notification.Symbol)
+	fmt.Fprintf(&msg, "Date: %s\r\n", notification.CreatedAt.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Hi %s,\r\n\r\n%s\r\n", user.Name, notification.Message)
+
+	// Written aside first, so that a failed write is not taken for a sent email
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(msg.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
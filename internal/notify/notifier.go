@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Notifier delivers a triggered notification to a user over one channel. A
+// delivery that failed on any channel is retried on all of them, so a
+// notifier delivers each alert at most once.
+type Notifier interface {
+	Notify(ctx context.Context, user *models.User, n *models.Notification) error
+}
+
+// Multi fans a notification out to several notifiers. Every notifier is
+// tried; their errors are joined.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, user *models.User, n *models.Notification) error {
+	var errs []error
+
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, user, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// InAppNotifier stores notifications in the user's in-app inbox, served by
+// GET /notifications/:userId
+type InAppNotifier struct {
+	notificationRepo repo.NotificationRepository
+}
+
+func NewInAppNotifier(notificationRepo repo.NotificationRepository) *InAppNotifier {
+	return &InAppNotifier{
+		notificationRepo: notificationRepo,
+	}
+}
+
+func (n *InAppNotifier) Notify(ctx context.Context, user *models.User, notification *models.Notification) error {
+	err := n.notificationRepo.CreateNotification(ctx, notification)
+
+	// Already in the inbox from an earlier delivery attempt
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+
+	return err
+}
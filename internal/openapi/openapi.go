@@ -0,0 +1,422 @@
+// Package openapi builds OpenAPI 3 documents. Schemas are derived from the Go
+// types the handlers bind and return, so they follow the json and binding
+// struct tags instead of being written by hand.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of one path, keyed by lower-case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`       // for type http, e.g. bearer
+	BearerFormat string `json:"bearerFormat,omitempty"` // for scheme bearer, e.g. JWT
+}
+
+// Route describes one operation in terms of gin routes and Go types
+type Route struct {
+	Method      string
+	Path        string // gin syntax, e.g. /portfolio/:userId
+	Tag         string
+	Summary     string
+	Description string
+	Query       []Parameter
+
+	Request      any      // body type, nil for none
+	RequestTypes []string // body media types, default application/json
+
+	Status       int // success status, default 200
+	Response     any // success body type, nil for none
+	ResponseType string
+	Headers      map[string]Header // success response headers
+
+	Errors     []int  // statuses documented with the error schema
+	Security   string // name of a security scheme, if required
+	Deprecated bool
+}
+
+// Builder assembles a Document
+type Builder struct {
+	doc         *Document
+	names       map[reflect.Type]string
+	errorSchema *Schema
+}
+
+func NewBuilder(info Info) *Builder {
+	return &Builder{
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   map[string]*PathItem{},
+			Components: Components{
+				Schemas:         map[string]*Schema{},
+				SecuritySchemes: map[string]SecurityScheme{},
+			},
+		},
+		names: map[reflect.Type]string{},
+	}
+}
+
+// Define registers v's type as a component under name instead of its Go
+// type name
+func (b *Builder) Define(name string, v any) *Schema {
+	t := indirect(reflect.TypeOf(v))
+	b.names[t] = name
+	return b.Schema(v)
+}
+
+// ErrorSchema sets the body schema of the documented error responses
+func (b *Builder) ErrorSchema(s *Schema) {
+	b.errorSchema = s
+}
+
+func (b *Builder) Tag(name, description string) {
+	b.doc.Tags = append(b.doc.Tags, Tag{Name: name, Description: description})
+}
+
+func (b *Builder) SecurityScheme(name string, scheme SecurityScheme) {
+	b.doc.Components.SecuritySchemes[name] = scheme
+}
+
+// Add documents a route
+func (b *Builder) Add(r Route) {
+	path, params := convertPath(r.Path)
+
+	op := &Operation{
+		Summary:     r.Summary,
+		Description: r.Description,
+		OperationID: operationID(r.Method, r.Path),
+		Deprecated:  r.Deprecated,
+		Parameters:  append(params, r.Query...),
+		Responses:   map[string]*Response{},
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+	if r.Security != "" {
+		op.Security = []map[string][]string{{r.Security: {}}}
+	}
+
+	if r.Request != nil {
+		types := r.RequestTypes
+		if len(types) == 0 {
+			types = []string{"application/json"}
+		}
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+		for _, mediaType := range types {
+			op.RequestBody.Content[mediaType] = MediaType{Schema: b.Schema(r.Request)}
+		}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status), Headers: r.Headers}
+	if r.Response != nil {
+		mediaType := r.ResponseType
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+		success.Content = map[string]MediaType{mediaType: {Schema: b.Schema(r.Response)}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	for _, code := range r.Errors {
+		resp := &Response{Description: http.StatusText(code)}
+		if b.errorSchema != nil {
+			resp.Content = map[string]MediaType{"application/problem+json": {Schema: b.errorSchema}}
+		}
+		op.Responses[strconv.Itoa(code)] = resp
+	}
+
+	item, ok := b.doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+	(*item)[strings.ToLower(r.Method)] = op
+}
+
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	rawJSONType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// ObjectIDPattern matches the hex form of a MongoDB ObjectID
+const ObjectIDPattern = "^[0-9a-fA-F]{24}$"
+
+// Schema returns the schema of v's type. Named struct types become
+// components and are referenced; fields follow their json tags, and fields
+// with binding:"required" are required.
+func (b *Builder) Schema(v any) *Schema {
+	return b.schemaOf(reflect.TypeOf(v))
+}
+
+func (b *Builder) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	t = indirect(t)
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return &Schema{Type: "string", Pattern: ObjectIDPattern}
+	case rawJSONType:
+		// Embedded as is, so any value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name, ok := b.names[t]
+		if !ok {
+			name = t.Name()
+			b.names[t] = name
+		}
+		if _, done := b.doc.Components.Schemas[name]; !done {
+			// Reserve the name first so recursive types terminate
+			b.doc.Components.Schemas[name] = &Schema{}
+			*b.doc.Components.Schemas[name] = *b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// Interfaces and anything else accept any value
+	return &Schema{}
+}
+
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	b.addFields(s, t)
+	return s
+}
+
+func (b *Builder) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Like encoding/json, promote the fields of untagged embedded structs,
+		// even unexported ones
+		if field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct {
+			b.addFields(s, indirect(field.Type))
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = b.schemaOf(field.Type)
+
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			if rule == "required" {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// convertPath turns /watchlists/:id/symbols/:symbol into
+// /watchlists/{id}/symbols/{symbol} and describes its parameters. IDs are
+// ObjectIDs, anything else a plain string.
+func convertPath(path string) (string, []Parameter) {
+	var params []Parameter
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+
+		name := segment[1:]
+		schema := &Schema{Type: "string"}
+		if name == "id" || strings.HasSuffix(name, "Id") {
+			schema.Pattern = ObjectIDPattern
+		}
+
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+		segments[i] = "{" + name + "}"
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// GinPath converts an OpenAPI path back to gin syntax
+func GinPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = ":" + segment[1:len(segment)-1]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// operationID derives a stable ID such as getPortfolioByUserId
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+
+	for _, segment := range strings.Split(path, "/") {
+		switch {
+		case segment == "":
+		case strings.HasPrefix(segment, ":"):
+			b.WriteString("By")
+			b.WriteString(upperFirst(segment[1:]))
+		default:
+			for _, part := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '.' || r == '_' }) {
+				b.WriteString(upperFirst(part))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// Operations lists every documented method and gin path, e.g. "GET /stocks/:symbol"
+func (d *Document) Operations() []string {
+	var ops []string
+	for path, item := range d.Paths {
+		for method := range *item {
+			ops = append(ops, fmt.Sprintf("%s %s", strings.ToUpper(method), GinPath(path)))
+		}
+	}
+	return ops
+}
@@ -0,0 +1,78 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type address struct {
+	City string `json:"city" binding:"required"`
+}
+
+type Person struct {
+	ID        primitive.ObjectID `json:"id"`
+	Name      string             `json:"name" binding:"required,max=50"`
+	Age       int                `json:"age,omitempty"`
+	Tags      []string           `json:"tags"`
+	Secret    string             `json:"-"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Extra     json.RawMessage    `json:"extra"`
+	address
+}
+
+func TestSchemaFollowsTags(t *testing.T) {
+	b := NewBuilder(Info{Title: "test", Version: "1"})
+
+	ref := b.Schema([]Person{})
+	if ref.Type != "array" || ref.Items.Ref != "#/components/schemas/Person" {
+		t.Fatalf("schema = %+v", ref)
+	}
+
+	s := b.Document().Components.Schemas["Person"]
+	if _, ok := s.Properties["Secret"]; ok {
+		t.Error("json:\"-\" field documented")
+	}
+	if got := s.Properties["id"].Pattern; got != ObjectIDPattern {
+		t.Errorf("id pattern = %q", got)
+	}
+	if got := s.Properties["createdAt"].Format; got != "date-time" {
+		t.Errorf("createdAt format = %q", got)
+	}
+	if got := s.Properties["extra"]; got == nil || got.Type != "" || got.Items != nil {
+		t.Errorf("raw JSON schema = %+v, want any value", got)
+	}
+	if s.Properties["city"] == nil {
+		t.Error("embedded struct not flattened")
+	}
+	if !slices.Equal(s.Required, []string{"name", "city"}) {
+		t.Errorf("required = %v", s.Required)
+	}
+}
+
+func TestAddRoute(t *testing.T) {
+	b := NewBuilder(Info{Title: "test", Version: "1"})
+	b.Add(Route{Method: http.MethodDelete, Path: "/people/:id/tags/:tag", Errors: []int{http.StatusNotFound}})
+
+	doc := b.Document()
+	op := (*doc.Paths["/people/{id}/tags/{tag}"])["delete"]
+	if op == nil {
+		t.Fatalf("paths = %v", doc.Paths)
+	}
+	if op.OperationID != "deletePeopleByIdTagsByTag" {
+		t.Errorf("operationId = %q", op.OperationID)
+	}
+	if len(op.Parameters) != 2 || op.Parameters[0].Schema.Pattern != ObjectIDPattern || op.Parameters[1].Schema.Pattern != "" {
+		t.Errorf("parameters = %+v", op.Parameters)
+	}
+	if op.Responses["200"] == nil || op.Responses["404"] == nil {
+		t.Errorf("responses = %v", op.Responses)
+	}
+	if got := doc.Operations(); !slices.Equal(got, []string{"DELETE /people/:id/tags/:tag"}) {
+		t.Errorf("operations = %v", got)
+	}
+}
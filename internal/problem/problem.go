@@ -0,0 +1,46 @@
+package problem
+
+import (
+	"net/http"
+
+	"concurrent-wallet-order-system/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of error responses (RFC 7807)
+const ContentType = "application/problem+json"
+
+// Details is an RFC 7807 problem document. Code is a stable, machine-readable
+// identifier clients can rely on; Detail is meant for humans and may change.
+type Details struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	Field     string `json:"field,omitempty"`     // the offending input, for validation problems
+	RequestID string `json:"requestId,omitempty"` // matches the X-Request-ID header and the logs
+}
+
+// New builds a problem of the generic about:blank type, whose title is the
+// text of the status code
+func New(status int, code, detail string) *Details {
+	return &Details{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Abort sends p as the response and stops the remaining handlers
+func Abort(c *gin.Context, p *Details) {
+	p.Instance = c.Request.URL.Path
+	p.RequestID = logging.RequestID(c.Request.Context())
+
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
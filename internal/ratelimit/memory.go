@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in process memory; each replica limits on its own
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled completely
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	tokens := float64(limit.Requests)
+	if b, ok := s.buckets[key]; ok {
+		tokens = limit.refill(b.tokens, now.Sub(b.updated))
+	}
+
+	result, tokens := limit.result(tokens)
+
+	missing := float64(limit.Requests) - tokens
+	s.buckets[key] = &bucket{
+		tokens:  tokens,
+		updated: now,
+		full:    now.Add(time.Duration(missing / limit.perSecond() * float64(time.Second))),
+	}
+
+	return result, nil
+}
+
+// sweep drops buckets that have refilled, since a missing bucket is full.
+// Callers hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of buckets held
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
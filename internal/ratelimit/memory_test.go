@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+
+	for i := 2; i >= 0; i-- {
+		r, _ := store.Take(t.Context(), "ip:1.2.3.4", limit)
+		if !r.Allowed || r.Remaining != i {
+			t.Fatalf("request %d: %+v", 3-i, r)
+		}
+	}
+
+	r, _ := store.Take(t.Context(), "ip:1.2.3.4", limit)
+	if r.Allowed || r.RetryAfter != time.Second {
+		t.Fatalf("over limit: %+v, want retry after 1s", r)
+	}
+
+	// Other keys have their own bucket
+	if r, _ := store.Take(t.Context(), "ip:5.6.7.8", limit); !r.Allowed {
+		t.Errorf("other key limited: %+v", r)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if r, _ := store.Take(t.Context(), "ip:1.2.3.4", limit); !r.Allowed {
+		t.Errorf("after refill: %+v", r)
+	}
+	if r, _ := store.Take(t.Context(), "ip:1.2.3.4", limit); r.Allowed || r.RetryAfter != 500*time.Millisecond {
+		t.Errorf("after refill and drain: %+v, want retry after 500ms", r)
+	}
+
+	// Refilled buckets are swept
+	now = now.Add(time.Hour)
+	store.Take(t.Context(), "ip:9.9.9.9", limit)
+	if got := store.Len(); got != 1 {
+		t.Errorf("%d buckets after sweep, want 1", got)
+	}
+}
+
+func TestMemoryStoreConcurrentTakes(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 50, Period: time.Hour}
+
+	var mu sync.Mutex
+	allowed := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, _ := store.Take(t.Context(), "user:1", limit); r.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 50 {
+		t.Errorf("%d requests allowed, want 50", allowed)
+	}
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps buckets in the rate_limits collection so every replica
+// draws from the same bucket. Buckets expire through a TTL index on
+// expiresAt once they would have refilled.
+type MongoStore struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoStore(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoStore {
+	return &MongoStore{
+		collection: db.Collection("rate_limits"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+type bucketDocument struct {
+	Tokens  float64 `bson:"tokens"`
+	Allowed bool    `bson:"allowed"`
+}
+
+// Take refills and draws from the bucket in a single pipeline update, using
+// the server's clock so that replicas with skewed clocks agree
+func (s *MongoStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	capacity := float64(limit.Requests)
+	elapsedSeconds := bson.M{"$divide": bson.A{
+		bson.M{"$subtract": bson.A{"$$NOW", bson.M{"$ifNull": bson.A{"$updatedAt", "$$NOW"}}}},
+		1000,
+	}}
+	refillMillis := limit.Period.Milliseconds()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{capacity, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", capacity}},
+				bson.M{"$multiply": bson.A{elapsedSeconds, limit.perSecond()}},
+			}}}},
+			"updatedAt": "$$NOW",
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens":    bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"expiresAt": bson.M{"$add": bson.A{"$$NOW", refillMillis}},
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var doc bucketDocument
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&doc)
+	if mongo.IsDuplicateKeyError(err) {
+		// Two replicas created the bucket at once; the document exists now
+		err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&doc)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	if doc.Allowed {
+		return Result{Allowed: true, Remaining: int(doc.Tokens)}, nil
+	}
+	result, _ := limit.result(doc.Tokens)
+	return result, nil
+}
@@ -0,0 +1,65 @@
+// Package ratelimit implements token buckets keyed by client, kept in memory
+// for a single instance or in MongoDB when several replicas share limits.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit allows Requests per Period on average, in bursts of up to Requests.
+// The zero Limit allows everything.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// perSecond is the refill rate of the bucket
+func (l Limit) perSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// refill returns the tokens in a bucket that held tokens elapsed ago
+func (l Limit) refill(tokens float64, elapsed time.Duration) float64 {
+	return math.Min(float64(l.Requests), tokens+elapsed.Seconds()*l.perSecond())
+}
+
+// result takes a token from a bucket holding tokens, if there is one
+func (l Limit) result(tokens float64) (Result, float64) {
+	if tokens >= 1 {
+		return Result{Allowed: true, Remaining: int(tokens - 1)}, tokens - 1
+	}
+	wait := (1 - tokens) / l.perSecond()
+	return Result{RetryAfter: time.Duration(wait * float64(time.Second))}, tokens
+}
+
+type Result struct {
+	Allowed    bool
+	Remaining  int           // tokens left after this request
+	RetryAfter time.Duration // until the next token, when not allowed
+}
+
+// Store keeps token buckets. Take must be atomic per key.
+type Store interface {
+	// Take removes a token from the bucket under key, creating a full
+	// bucket for an unknown key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Policy limits one group of routes, per client IP and per authenticated user
+type Policy struct {
+	Name    string
+	PerIP   Limit
+	PerUser Limit
+}
+
+// Compile-time checks that the stores satisfy the interface
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*MongoStore)(nil)
+)
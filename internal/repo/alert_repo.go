@@ -0,0 +1,171 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAlertRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoAlertRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoAlertRepository {
+	return &MongoAlertRepository{
+		collection: db.Collection("alerts"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+// CreateAlert inserts a new price alert
+func (r *MongoAlertRepository) CreateAlert(ctx context.Context, alert *models.Alert) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	alert.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, alert)
+	if err != nil {
+		return err
+	}
+
+	alert.ID = result.InsertedID.(primitive.ObjectID)
+	r.logger.DebugContext(ctx, "alert created", "alert_id", alert.ID.Hex(), "symbol", alert.Symbol)
+	return nil
+}
+
+// GetAlert returns an alert by id
+func (r *MongoAlertRepository) GetAlert(ctx context.Context, id primitive.ObjectID) (*models.Alert, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var alert models.Alert
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&alert); err != nil {
+		return nil, err
+	}
+
+	return &alert, nil
+}
+
+// GetActiveAlertsBySymbol returns the alerts still waiting on a stock
+func (r *MongoAlertRepository) GetActiveAlertsBySymbol(ctx context.Context, symbol string) ([]models.Alert, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"symbol": symbol, "status": models.AlertStatusActive},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var alerts []models.Alert
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+// GetAlertsByUser lists a user's alerts, newest first
+func (r *MongoAlertRepository) GetAlertsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Alert, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"userId": userID},
+		options.Find().SetSort(bson.M{"createdAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	alerts := []models.Alert{}
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+// MarkTriggered moves an alert from ACTIVE to TRIGGERED. It reports false when
+// the alert was already triggered, so only one caller ever wins the transition.
+func (r *MongoAlertRepository) MarkTriggered(ctx context.Context, id primitive.ObjectID, price float64, at time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "status": models.AlertStatusActive},
+		bson.M{"$set": bson.M{
+			"status":       models.AlertStatusTriggered,
+			"triggerPrice": price,
+			"triggeredAt":  at,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	claimed := result.ModifiedCount == 1
+	r.logger.DebugContext(ctx, "alert trigger", "alert_id", id.Hex(), "price", price, "claimed", claimed)
+	return claimed, nil
+}
+
+// ReleaseTrigger moves an alert claimed by MarkTriggered back to ACTIVE, so
+// that a later price change can trigger it again
+func (r *MongoAlertRepository) ReleaseTrigger(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "status": models.AlertStatusTriggered},
+		bson.M{
+			"$set":   bson.M{"status": models.AlertStatusActive},
+			"$unset": bson.M{"triggerPrice": "", "triggeredAt": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "alert trigger released", "alert_id", id.Hex())
+	return nil
+}
+
+// DeleteAlert removes an alert
+func (r *MongoAlertRepository) DeleteAlert(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "alert deleted", "alert_id", id.Hex())
+	return nil
+}
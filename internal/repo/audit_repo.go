@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditFilter narrows a listing of the audit log. Zero values mean "no
+// constraint".
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	Page       int64
+	Limit      int64
+}
+
+// MongoAuditRepository has no update or delete: the audit log is append-only
+type MongoAuditRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoAuditRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoAuditRepository {
+	return &MongoAuditRepository{
+		collection: db.Collection("audit_log"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+// LastAuditEntry returns the entry with the highest seq, or
+// mongo.ErrNoDocuments while the log is empty
+func (r *MongoAuditRepository) LastAuditEntry(ctx context.Context) (*models.AuditEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var e models.AuditEntry
+	opts := options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})
+	if err := r.collection.FindOne(ctx, bson.M{}, opts).Decode(&e); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// InsertAuditEntry appends an entry. One with a seq already taken, by a
+// writer that got there first, fails on the unique index.
+func (r *MongoAuditRepository) InsertAuditEntry(ctx context.Context, e *models.AuditEntry) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	e.ID = result.InsertedID.(primitive.ObjectID)
+	r.logger.DebugContext(ctx, "audit entry inserted", "seq", e.Seq, "action", e.Action)
+	return nil
+}
+
+// ListAuditEntries returns a page of matching entries, newest first, and
+// how many match in all
+func (r *MongoAuditRepository) ListAuditEntries(ctx context.Context, f AuditFilter) ([]models.AuditEntry, int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if f.ActorID != "" {
+		filter["actorId"] = f.ActorID
+	}
+	if f.Action != "" {
+		filter["action"] = f.Action
+	}
+	if f.TargetType != "" {
+		filter["targetType"] = f.TargetType
+	}
+	if f.TargetID != "" {
+		filter["targetId"] = f.TargetID
+	}
+
+	at := bson.M{}
+	if !f.From.IsZero() {
+		at["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		at["$lt"] = f.To
+	}
+	if len(at) > 0 {
+		filter["at"] = at
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSkip((f.Page - 1) * f.Limit).
+		SetLimit(f.Limit).
+		SetSort(bson.D{{Key: "seq", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// AuditEntriesFrom returns up to limit entries from seq onwards, in chain
+// order
+func (r *MongoAuditRepository) AuditEntriesFrom(ctx context.Context, seq, limit int64) ([]models.AuditEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{"seq": bson.M{"$gte": seq}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoLimitUsageRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoLimitUsageRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoLimitUsageRepository {
+	return &MongoLimitUsageRepository{
+		collection: db.Collection("limit_usage"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+func limitUsageKey(userID primitive.ObjectID, day string) string {
+	return userID.Hex() + ":" + day
+}
+
+// GetLimitUsage returns the user's usage on day
+func (r *MongoLimitUsageRepository) GetLimitUsage(ctx context.Context, userID primitive.ObjectID, day string) (*models.LimitUsage, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var u models.LimitUsage
+	if err := r.collection.FindOne(ctx, bson.M{"_id": limitUsageKey(userID, day)}).Decode(&u); err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// AddLimitUsage adds amount to the user's usage of kind on day in a single
+// atomic update, unless that would take it above limit; then it returns
+// mongo.ErrNoDocuments. A negative amount gives back an earlier one.
+func (r *MongoLimitUsageRepository) AddLimitUsage(ctx context.Context, userID primitive.ObjectID, day, kind string, amount, limit float64, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if amount > limit {
+		return mongo.ErrNoDocuments
+	}
+
+	filter := bson.M{"_id": limitUsageKey(userID, day)}
+	if amount > 0 {
+		filter[kind] = bson.M{"$not": bson.M{"$gt": limit - amount}}
+	}
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		filter,
+		bson.M{
+			"$inc":         bson.M{kind: amount},
+			"$setOnInsert": bson.M{"userId": userID, "day": day, "expiresAt": expiresAt},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		// The record exists but has no room left, so the upsert tried to insert
+		return mongo.ErrNoDocuments
+	}
+	return err
+}
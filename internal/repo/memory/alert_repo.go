@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AlertRepository struct {
+	store *Store
+}
+
+func NewAlertRepository(store *Store) *AlertRepository {
+	return &AlertRepository{store: store}
+}
+
+func (r *AlertRepository) CreateAlert(ctx context.Context, alert *models.Alert) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	alert.ID = primitive.NewObjectID()
+	alert.CreatedAt = time.Now()
+
+	r.store.alerts[alert.ID] = *alert
+	return nil
+}
+
+func (r *AlertRepository) GetAlert(ctx context.Context, id primitive.ObjectID) (*models.Alert, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	a, ok := r.store.alerts[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return &a, nil
+}
+
+func (r *AlertRepository) GetActiveAlertsBySymbol(ctx context.Context, symbol string) ([]models.Alert, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var alerts []models.Alert
+	for _, a := range r.store.alerts {
+		if a.Symbol == symbol && a.Status == models.AlertStatusActive {
+			alerts = append(alerts, a)
+		}
+	}
+
+	return alerts, nil
+}
+
+func (r *AlertRepository) GetAlertsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Alert, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	alerts := []models.Alert{}
+	for _, a := range r.store.alerts {
+		if a.UserID == userID {
+			alerts = append(alerts, a)
+		}
+	}
+
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].CreatedAt.After(alerts[j].CreatedAt) })
+	return alerts, nil
+}
+
+func (r *AlertRepository) MarkTriggered(ctx context.Context, id primitive.ObjectID, price float64, at time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	a, ok := r.store.alerts[id]
+	if !ok || a.Status != models.AlertStatusActive {
+		return false, nil
+	}
+
+	a.Status = models.AlertStatusTriggered
+	a.TriggerPrice = price
+	a.TriggeredAt = &at
+	r.store.alerts[id] = a
+	return true, nil
+}
+
+func (r *AlertRepository) ReleaseTrigger(ctx context.Context, id primitive.ObjectID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	a, ok := r.store.alerts[id]
+	if !ok || a.Status != models.AlertStatusTriggered {
+		return mongo.ErrNoDocuments
+	}
+
+	a.Status = models.AlertStatusActive
+	a.TriggerPrice = 0
+	a.TriggeredAt = nil
+	r.store.alerts[id] = a
+	return nil
+}
+
+func (r *AlertRepository) DeleteAlert(ctx context.Context, id primitive.ObjectID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.alerts[id]; !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	delete(r.store.alerts, id)
+	return nil
+}
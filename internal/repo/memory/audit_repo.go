@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AuditRepository struct {
+	store *Store
+}
+
+func NewAuditRepository(store *Store) *AuditRepository {
+	return &AuditRepository{store: store}
+}
+
+// copyAuditEntry keeps callers from changing stored snapshots through their
+// byte slices
+func copyAuditEntry(e models.AuditEntry) models.AuditEntry {
+	e.Before = slices.Clone(e.Before)
+	e.After = slices.Clone(e.After)
+	return e
+}
+
+func (r *AuditRepository) LastAuditEntry(ctx context.Context) (*models.AuditEntry, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if len(r.store.auditEntries) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	// Entries are kept in seq order
+	e := copyAuditEntry(r.store.auditEntries[len(r.store.auditEntries)-1])
+	return &e, nil
+}
+
+func (r *AuditRepository) InsertAuditEntry(ctx context.Context, e *models.AuditEntry) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Like the unique seq index, and any higher seq breaks the order kept
+	if n := len(r.store.auditEntries); n > 0 && r.store.auditEntries[n-1].Seq >= e.Seq {
+		return duplicateKeyError(fmt.Sprintf("audit_log.seq %d", e.Seq))
+	}
+
+	e.ID = primitive.NewObjectID()
+	r.store.auditEntries = append(r.store.auditEntries, copyAuditEntry(*e))
+	return nil
+}
+
+func (r *AuditRepository) ListAuditEntries(ctx context.Context, f repo.AuditFilter) ([]models.AuditEntry, int64, error) {
+	r.store.mu.RLock()
+	var matches []models.AuditEntry
+	for _, e := range r.store.auditEntries {
+		switch {
+		case f.ActorID != "" && e.ActorID != f.ActorID,
+			f.Action != "" && e.Action != f.Action,
+			f.TargetType != "" && e.TargetType != f.TargetType,
+			f.TargetID != "" && e.TargetID != f.TargetID,
+			!f.From.IsZero() && e.At.Before(f.From),
+			!f.To.IsZero() && !e.At.Before(f.To):
+			continue
+		}
+		matches = append(matches, copyAuditEntry(e))
+	}
+	r.store.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Seq > matches[j].Seq })
+
+	total := int64(len(matches))
+
+	// A zero limit means no limit, as with options.Find().SetLimit(0)
+	if f.Limit <= 0 {
+		return matches, total, nil
+	}
+
+	start := max(f.Page-1, 0) * f.Limit
+	start = min(start, total)
+	end := min(start+f.Limit, total)
+
+	return matches[start:end], total, nil
+}
+
+func (r *AuditRepository) AuditEntriesFrom(ctx context.Context, seq, limit int64) ([]models.AuditEntry, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	entries := []models.AuditEntry{}
+	for _, e := range r.store.auditEntries {
+		if limit > 0 && int64(len(entries)) == limit {
+			break
+		}
+		if e.Seq >= seq {
+			entries = append(entries, copyAuditEntry(e))
+		}
+	}
+	return entries, nil
+}
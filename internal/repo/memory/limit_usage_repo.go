@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type LimitUsageRepository struct {
+	store *Store
+}
+
+func NewLimitUsageRepository(store *Store) *LimitUsageRepository {
+	return &LimitUsageRepository{store: store}
+}
+
+func (r *LimitUsageRepository) GetLimitUsage(ctx context.Context, userID primitive.ObjectID, day string) (*models.LimitUsage, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	u, ok := r.store.limitUsage[userID.Hex()+":"+day]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &u, nil
+}
+
+func (r *LimitUsageRepository) AddLimitUsage(ctx context.Context, userID primitive.ObjectID, day, kind string, amount, limit float64, expiresAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := userID.Hex() + ":" + day
+	u, ok := r.store.limitUsage[key]
+	if !ok {
+		u = models.LimitUsage{Key: key, UserID: userID, Day: day, ExpiresAt: expiresAt}
+	}
+
+	if amount > 0 && u.Amount(kind) > limit-amount {
+		return mongo.ErrNoDocuments
+	}
+
+	switch kind {
+	case models.LimitDeposit:
+		u.Deposit += amount
+	case models.LimitWithdrawal:
+		u.Withdrawal += amount
+	case models.LimitOrder:
+		u.Order += amount
+	}
+
+	r.store.limitUsage[key] = u
+	return nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type NotificationRepository struct {
+	store *Store
+}
+
+func NewNotificationRepository(store *Store) *NotificationRepository {
+	return &NotificationRepository{store: store}
+}
+
+func (r *NotificationRepository) CreateNotification(ctx context.Context, n *models.Notification) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.store.notifications {
+		if existing.AlertID == n.AlertID {
+			return duplicateKeyError("notifications.alertId")
+		}
+	}
+
+	n.ID = primitive.NewObjectID()
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+
+	r.store.notifications[n.ID] = *n
+	return nil
+}
+
+func (r *NotificationRepository) GetNotificationsByUser(ctx context.Context, userID primitive.ObjectID, unreadOnly bool) ([]models.Notification, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	notifications := []models.Notification{}
+	for _, n := range r.store.notifications {
+		if n.UserID == userID && (!unreadOnly || !n.Read) {
+			notifications = append(notifications, n)
+		}
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+	return notifications, nil
+}
+
+func (r *NotificationRepository) GetNotification(ctx context.Context, id primitive.ObjectID) (*models.Notification, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	n, ok := r.store.notifications[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return &n, nil
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, id primitive.ObjectID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	n, ok := r.store.notifications[id]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	n.Read = true
+	r.store.notifications[id] = n
+	return nil
+}
@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OrderRepository struct {
+	store *Store
+}
+
+func NewOrderRepository(store *Store) *OrderRepository {
+	return &OrderRepository{store: store}
+}
+
+func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Like InsertOne, a given id is kept and must be new
+	if order.ID.IsZero() {
+		order.ID = primitive.NewObjectID()
+	}
+	for _, o := range r.store.orders {
+		if o.ID == order.ID {
+			return duplicateKeyError("orders._id")
+		}
+	}
+	order.CreatedAt = time.Now()
+
+	r.store.orders = append(r.store.orders, *order)
+	return nil
+}
+
+// Orders returns every recorded order, for assertions in tests
+func (r *OrderRepository) Orders() []models.Order {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return append([]models.Order(nil), r.store.orders...)
+}
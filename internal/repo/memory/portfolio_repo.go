@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PortfolioRepository struct {
+	store *Store
+}
+
+func NewPortfolioRepository(store *Store) *PortfolioRepository {
+	return &PortfolioRepository{store: store}
+}
+
+func (r *PortfolioRepository) GetPortfolio(ctx context.Context, userID primitive.ObjectID, symbol string) (*models.Portfolio, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	p, ok := r.store.portfolio[portfolioKey{userID, symbol}]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return &p, nil
+}
+
+func (r *PortfolioRepository) UpsertPortfolio(ctx context.Context, userID primitive.ObjectID, symbol string, qty int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := portfolioKey{userID, symbol}
+
+	p, ok := r.store.portfolio[key]
+	if !ok {
+		p = models.Portfolio{
+			ID:     primitive.NewObjectID(),
+			UserID: userID,
+			Symbol: symbol,
+		}
+	}
+
+	p.Qty += qty
+	r.store.portfolio[key] = p
+	return nil
+}
+
+func (r *PortfolioRepository) GetUserPortfolio(ctx context.Context, userID primitive.ObjectID) ([]models.Portfolio, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var holdings []models.Portfolio
+	for key, p := range r.store.portfolio {
+		if key.userID == userID {
+			holdings = append(holdings, p)
+		}
+	}
+
+	return holdings, nil
+}
+
+func (r *PortfolioRepository) GetHoldingsBySymbol(ctx context.Context, symbol string) ([]models.Portfolio, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var holdings []models.Portfolio
+	for key, p := range r.store.portfolio {
+		if key.symbol == symbol && p.Qty > 0 {
+			holdings = append(holdings, p)
+		}
+	}
+
+	return holdings, nil
+}
+
+func (r *PortfolioRepository) StartSettlement(ctx context.Context, userID primitive.ObjectID, symbol string, qty int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := portfolioKey{userID, symbol}
+
+	p, ok := r.store.portfolio[key]
+	if !ok || p.Qty != qty {
+		return mongo.ErrNoDocuments
+	}
+
+	p.Qty -= qty
+	p.Settling += qty
+	r.store.portfolio[key] = p
+	return nil
+}
+
+func (r *PortfolioRepository) GetSettlementsBySymbol(ctx context.Context, symbol string) ([]models.Portfolio, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var holdings []models.Portfolio
+	for key, p := range r.store.portfolio {
+		if key.symbol == symbol && p.Settling > 0 {
+			holdings = append(holdings, p)
+		}
+	}
+
+	return holdings, nil
+}
+
+func (r *PortfolioRepository) FinishSettlement(ctx context.Context, userID primitive.ObjectID, symbol string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := portfolioKey{userID, symbol}
+	if p, ok := r.store.portfolio[key]; ok {
+		p.Settling = 0
+		r.store.portfolio[key] = p
+	}
+	return nil
+}
+
+func (r *PortfolioRepository) FreezeHoldings(ctx context.Context, symbol string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var modified int64
+	for key, p := range r.store.portfolio {
+		if key.symbol == symbol && p.Qty > 0 && !p.Frozen {
+			p.Frozen = true
+			r.store.portfolio[key] = p
+			modified++
+		}
+	}
+
+	return modified, nil
+}
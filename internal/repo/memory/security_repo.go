@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type LoginAttemptRepository struct {
+	store *Store
+}
+
+func NewLoginAttemptRepository(store *Store) *LoginAttemptRepository {
+	return &LoginAttemptRepository{store: store}
+}
+
+func (r *LoginAttemptRepository) GetLoginFailures(ctx context.Context, key string) (*models.LoginFailures, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	f, ok := r.store.loginFailures[key]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &f, nil
+}
+
+func (r *LoginAttemptRepository) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginFailures, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	f, ok := r.store.loginFailures[key]
+	if !ok {
+		f = models.LoginFailures{Key: key}
+	}
+
+	if f.LastFailure.After(now.Add(-window)) {
+		f.Count++
+	} else {
+		f.Count = 1
+	}
+	f.LastFailure = now
+	if expires := now.Add(window); expires.After(f.ExpiresAt) {
+		f.ExpiresAt = expires
+	}
+
+	r.store.loginFailures[key] = f
+	return &f, nil
+}
+
+func (r *LoginAttemptRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	f, ok := r.store.loginFailures[key]
+	if !ok {
+		return nil
+	}
+
+	f.LockedUntil = until
+	if until.After(f.ExpiresAt) {
+		f.ExpiresAt = until
+	}
+	r.store.loginFailures[key] = f
+	return nil
+}
+
+func (r *LoginAttemptRepository) ClearLoginFailures(ctx context.Context, key string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.loginFailures, key)
+	return nil
+}
+
+type SecurityEventRepository struct {
+	store *Store
+}
+
+func NewSecurityEventRepository(store *Store) *SecurityEventRepository {
+	return &SecurityEventRepository{store: store}
+}
+
+func (r *SecurityEventRepository) CreateSecurityEvent(ctx context.Context, e *models.SecurityEvent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	e.ID = primitive.NewObjectID()
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	r.store.securityEvents = append(r.store.securityEvents, *e)
+	return nil
+}
+
+func (r *SecurityEventRepository) ListSecurityEvents(ctx context.Context, f repo.SecurityEventFilter) ([]models.SecurityEvent, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	events := []models.SecurityEvent{}
+	for _, e := range r.store.securityEvents {
+		if f.Type != "" && e.Type != f.Type {
+			continue
+		}
+		if !f.UserID.IsZero() && (e.UserID == nil || *e.UserID != f.UserID) {
+			continue
+		}
+		if f.Email != "" && e.Email != f.Email {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.After(events[j].CreatedAt)
+	})
+	if f.Limit > 0 && int64(len(events)) > f.Limit {
+		events = events[:f.Limit]
+	}
+	return events, nil
+}
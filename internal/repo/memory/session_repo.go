@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SessionRepository struct {
+	store *Store
+}
+
+func NewSessionRepository(store *Store) *SessionRepository {
+	return &SessionRepository{store: store}
+}
+
+func (r *SessionRepository) CreateSession(ctx context.Context, s *models.Session) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if s.ID.IsZero() {
+		s.ID = primitive.NewObjectID()
+	}
+	r.store.sessions[s.ID] = *s
+	return nil
+}
+
+func (r *SessionRepository) GetSession(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	s, ok := r.store.sessions[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &s, nil
+}
+
+func (r *SessionRepository) RotateSession(ctx context.Context, id primitive.ObjectID, oldHash, newHash, ip, userAgent string, now, expiresAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	s, ok := r.store.sessions[id]
+	if !ok || s.RefreshHash != oldHash || s.RevokedAt != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	s.PreviousHashes = append(s.PreviousHashes, oldHash)
+	if len(s.PreviousHashes) > repo.RefreshHashHistory {
+		s.PreviousHashes = s.PreviousHashes[len(s.PreviousHashes)-repo.RefreshHashHistory:]
+	}
+	s.RefreshHash = newHash
+	s.IP = ip
+	s.UserAgent = userAgent
+	s.LastUsedAt = now
+	s.ExpiresAt = expiresAt
+
+	r.store.sessions[id] = s
+	return nil
+}
+
+func (r *SessionRepository) RevokeSession(ctx context.Context, id, userID primitive.ObjectID, reason string, now time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	s, ok := r.store.sessions[id]
+	if !ok || s.UserID != userID || s.RevokedAt != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	s.RevokedAt = &now
+	s.RevokeReason = reason
+	r.store.sessions[id] = s
+	return nil
+}
+
+func (r *SessionRepository) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, reason string, now time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var revoked int64
+	for id, s := range r.store.sessions {
+		if s.UserID != userID || s.RevokedAt != nil || !s.ExpiresAt.After(now) {
+			continue
+		}
+		s.RevokedAt = &now
+		s.RevokeReason = reason
+		r.store.sessions[id] = s
+		revoked++
+	}
+	return revoked, nil
+}
+
+func (r *SessionRepository) ListActiveSessions(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]models.Session, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	sessions := []models.Session{}
+	for _, s := range r.store.sessions {
+		if s.UserID != userID || s.RevokedAt != nil || !s.ExpiresAt.After(now) {
+			continue
+		}
+		s.RefreshHash = ""
+		s.PreviousHashes = nil
+		sessions = append(sessions, s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
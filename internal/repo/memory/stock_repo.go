@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type StockRepository struct {
+	store *Store
+}
+
+func NewStockRepository(store *Store) *StockRepository {
+	return &StockRepository{store: store}
+}
+
+func (r *StockRepository) CreateStock(ctx context.Context, stock *models.Stock) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, exists := r.store.stocks[stock.Symbol]; exists {
+		return duplicateKeyError("stocks.symbol")
+	}
+
+	stock.ID = primitive.NewObjectID()
+	stock.CreatedAt = time.Now()
+
+	r.store.stocks[stock.Symbol] = *stock
+	return nil
+}
+
+func (r *StockRepository) GetAllStocks(ctx context.Context, includeDelisted bool) ([]models.Stock, error) {
+	return r.find(func(s models.Stock) bool {
+		return includeDelisted || s.Status != models.StockStatusDelisted
+	}), nil
+}
+
+// SearchStocks approximates the Mongo $text search with a case-insensitive
+// word match on symbol and name
+func (r *StockRepository) SearchStocks(ctx context.Context, f repo.StockFilter) ([]models.Stock, int64, error) {
+	words := strings.Fields(strings.ToLower(f.Text))
+	prefix := strings.ToLower(f.Prefix)
+
+	matches := r.find(func(s models.Stock) bool {
+		if len(words) > 0 {
+			tokens := strings.Fields(strings.ToLower(s.Symbol + " " + s.Name))
+			found := false
+			for _, w := range words {
+				if slices.Contains(tokens, w) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		if prefix != "" &&
+			!strings.HasPrefix(strings.ToLower(s.Symbol), prefix) &&
+			!strings.HasPrefix(strings.ToLower(s.Name), prefix) {
+			return false
+		}
+		if len(f.Sectors) > 0 && !slices.Contains(f.Sectors, s.Sector) {
+			return false
+		}
+		if f.Industry != "" && s.Industry != f.Industry {
+			return false
+		}
+		if f.Exchange != "" && s.Exchange != f.Exchange {
+			return false
+		}
+		if f.MinPrice > 0 && s.Price < f.MinPrice {
+			return false
+		}
+		if f.MaxPrice > 0 && s.Price > f.MaxPrice {
+			return false
+		}
+		return f.IncludeDelisted || s.Status != models.StockStatusDelisted
+	})
+
+	if f.SortBy != "" {
+		sort.SliceStable(matches, func(i, j int) bool {
+			a, b := matches[i], matches[j]
+			var less, equal bool
+			switch f.SortBy {
+			case "name":
+				less, equal = a.Name < b.Name, a.Name == b.Name
+			case "price":
+				less, equal = a.Price < b.Price, a.Price == b.Price
+			case "marketCap":
+				less, equal = a.MarketCap < b.MarketCap, a.MarketCap == b.MarketCap
+			default:
+				return a.Symbol < b.Symbol
+			}
+			if equal {
+				return a.Symbol < b.Symbol
+			}
+			return less != f.SortDesc
+		})
+	}
+
+	total := int64(len(matches))
+
+	// A zero limit means no limit, as with options.Find().SetLimit(0)
+	if f.Limit <= 0 {
+		return matches, total, nil
+	}
+
+	start := max(f.Page-1, 0) * f.Limit
+	start = min(start, total)
+	end := min(start+f.Limit, total)
+
+	return matches[start:end], total, nil
+}
+
+func (r *StockRepository) GetStocksBySymbols(ctx context.Context, symbols []string) ([]models.Stock, error) {
+	return r.find(func(s models.Stock) bool {
+		return slices.Contains(symbols, s.Symbol)
+	}), nil
+}
+
+func (r *StockRepository) GetStockBySymbol(ctx context.Context, symbol string) (*models.Stock, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	stock, ok := r.store.stocks[symbol]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return &stock, nil
+}
+
+func (r *StockRepository) BulkUpsertStocks(ctx context.Context, stocks []models.Stock) (int64, int64, []string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var inserted, updated int64
+	var delisted []string
+	now := time.Now()
+
+	for _, s := range stocks {
+		existing, ok := r.store.stocks[s.Symbol]
+		if !ok {
+			s.ID = primitive.NewObjectID()
+			s.Status = models.StockStatusActive
+			s.CreatedAt = now
+			r.store.stocks[s.Symbol] = s
+			inserted++
+			continue
+		}
+
+		if existing.CurrentStatus() == models.StockStatusDelisted {
+			delisted = append(delisted, s.Symbol)
+			continue
+		}
+
+		existing.Name = s.Name
+		existing.Price = s.Price
+		existing.PrevClose = s.PrevClose
+		existing.PriceAt = s.PriceAt
+		if s.Sector != "" {
+			existing.Sector = s.Sector
+		}
+		if s.Industry != "" {
+			existing.Industry = s.Industry
+		}
+		if s.Exchange != "" {
+			existing.Exchange = s.Exchange
+		}
+		if s.MarketCap > 0 {
+			existing.MarketCap = s.MarketCap
+		}
+		r.store.stocks[s.Symbol] = existing
+		updated++
+	}
+
+	return inserted, updated, delisted, nil
+}
+
+func (r *StockRepository) UpdatePrice(ctx context.Context, symbol string, price, previousClose float64, at time.Time) error {
+	return r.update(symbol, func(s *models.Stock) {
+		s.Price = price
+		s.PrevClose = previousClose
+		s.PriceAt = &at
+	})
+}
+
+func (r *StockRepository) UpdateStatus(ctx context.Context, symbol, status string) error {
+	return r.update(symbol, func(s *models.Stock) {
+		s.Status = status
+	})
+}
+
+func (r *StockRepository) MarkDelisted(ctx context.Context, symbol string, finalPrice float64) error {
+	now := time.Now()
+	return r.update(symbol, func(s *models.Stock) {
+		s.Status = models.StockStatusDelisted
+		s.Price = finalPrice
+		s.DelistedAt = &now
+	})
+}
+
+func (r *StockRepository) update(symbol string, apply func(*models.Stock)) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stock, ok := r.store.stocks[symbol]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	apply(&stock)
+	r.store.stocks[symbol] = stock
+	return nil
+}
+
+// find returns the matching stocks ordered by symbol
+func (r *StockRepository) find(match func(models.Stock) bool) []models.Stock {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	stocks := []models.Stock{}
+	for _, s := range r.store.stocks {
+		if match(s) {
+			stocks = append(stocks, s)
+		}
+	}
+
+	sort.Slice(stocks, func(i, j int) bool { return stocks[i].Symbol < stocks[j].Symbol })
+	return stocks
+}
@@ -0,0 +1,84 @@
+// Package memory is a thread-safe, in-memory implementation of the repository
+// interfaces in internal/repo. It mirrors the semantics of the Mongo
+// repositories closely enough to exercise the services in tests without a
+// running database.
+package memory
+
+import (
+	"sync"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type portfolioKey struct {
+	userID primitive.ObjectID
+	symbol string
+}
+
+// Store holds every collection behind a single lock. Repositories created
+// from the same Store see each other's data, as the Mongo ones share a
+// database.
+type Store struct {
+	mu sync.RWMutex
+
+	users         map[primitive.ObjectID]models.User
+	transactions  []models.WalletTransaction
+	stocks        map[string]models.Stock
+	orders        []models.Order
+	portfolio     map[portfolioKey]models.Portfolio
+	watchlists    map[primitive.ObjectID]models.Watchlist
+	alerts        map[primitive.ObjectID]models.Alert
+	notifications map[primitive.ObjectID]models.Notification
+
+	loginFailures  map[string]models.LoginFailures
+	securityEvents []models.SecurityEvent
+	sessions       map[primitive.ObjectID]models.Session
+	userTokens     map[primitive.ObjectID]models.UserToken
+	limitUsage     map[string]models.LimitUsage
+	auditEntries   []models.AuditEntry
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:         map[primitive.ObjectID]models.User{},
+		stocks:        map[string]models.Stock{},
+		portfolio:     map[portfolioKey]models.Portfolio{},
+		watchlists:    map[primitive.ObjectID]models.Watchlist{},
+		alerts:        map[primitive.ObjectID]models.Alert{},
+		notifications: map[primitive.ObjectID]models.Notification{},
+		loginFailures: map[string]models.LoginFailures{},
+		sessions:      map[primitive.ObjectID]models.Session{},
+		userTokens:    map[primitive.ObjectID]models.UserToken{},
+		limitUsage:    map[string]models.LimitUsage{},
+	}
+}
+
+// duplicateKeyError builds the same error shape the driver returns for a
+// unique index violation, so mongo.IsDuplicateKeyError recognises it
+func duplicateKeyError(msg string) error {
+	return mongo.WriteException{
+		WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error: " + msg}},
+	}
+}
+
+// Compile-time checks that the in-memory implementations satisfy the interfaces
+var (
+	_ repo.UserRepository          = (*UserRepository)(nil)
+	_ repo.WalletRepository        = (*WalletRepository)(nil)
+	_ repo.StockRepository         = (*StockRepository)(nil)
+	_ repo.OrderRepository         = (*OrderRepository)(nil)
+	_ repo.PortfolioRepository     = (*PortfolioRepository)(nil)
+	_ repo.WatchlistRepository     = (*WatchlistRepository)(nil)
+	_ repo.AlertRepository         = (*AlertRepository)(nil)
+	_ repo.NotificationRepository  = (*NotificationRepository)(nil)
+	_ repo.LoginAttemptRepository  = (*LoginAttemptRepository)(nil)
+	_ repo.SecurityEventRepository = (*SecurityEventRepository)(nil)
+	_ repo.SessionRepository       = (*SessionRepository)(nil)
+	_ repo.UserTokenRepository     = (*UserTokenRepository)(nil)
+	_ repo.LimitUsageRepository    = (*LimitUsageRepository)(nil)
+	_ repo.AuditRepository         = (*AuditRepository)(nil)
+)
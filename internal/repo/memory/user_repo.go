@@ -0,0 +1,358 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UserRepository struct {
+	store *Store
+}
+
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{store: store}
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.store.users {
+		if existing.Email == user.Email {
+			return duplicateKeyError("users.email")
+		}
+	}
+
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	user.WalletBalance = 0
+
+	r.store.users[user.ID] = *user
+	return nil
+}
+
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, user := range r.store.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *UserRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	user, ok := r.store.users[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) UpdateWalletBalance(ctx context.Context, userID primitive.ObjectID, newBalance float64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Like UpdateOne, a missing user is not an error
+	if user, ok := r.store.users[userID]; ok {
+		user.WalletBalance = newBalance
+		r.store.users[userID] = user
+	}
+
+	return nil
+}
+
+func (r *UserRepository) CreditOnce(ctx context.Context, userID primitive.ObjectID, key string, amount float64) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok || slices.Contains(user.Settlements, key) {
+		return false, nil
+	}
+
+	user.WalletBalance += amount
+	user.Settlements = append(slices.Clone(user.Settlements), key)
+	r.store.users[userID] = user
+	return true, nil
+}
+
+// SearchUsers matches prefixes case-insensitively, like the Mongo regexes
+func (r *UserRepository) SearchUsers(ctx context.Context, f repo.UserFilter) ([]models.User, int64, error) {
+	name := strings.ToLower(f.Name)
+	email := strings.ToLower(f.Email)
+
+	r.store.mu.RLock()
+	var matches []models.User
+	for _, user := range r.store.users {
+		switch {
+		case !strings.HasPrefix(strings.ToLower(user.Name), name),
+			!strings.HasPrefix(strings.ToLower(user.Email), email),
+			!f.CreatedFrom.IsZero() && user.CreatedAt.Before(f.CreatedFrom),
+			!f.CreatedTo.IsZero() && !user.CreatedAt.Before(f.CreatedTo),
+			f.Status != "" && user.Status() != f.Status:
+			continue
+		}
+		matches = append(matches, user)
+	}
+	r.store.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID.Hex() > b.ID.Hex()
+	})
+
+	total := int64(len(matches))
+
+	// A zero limit means no limit, as with options.Find().SetLimit(0)
+	if f.Limit <= 0 {
+		return matches, total, nil
+	}
+
+	start := max(f.Page-1, 0) * f.Limit
+	start = min(start, total)
+	end := min(start+f.Limit, total)
+
+	return matches[start:end], total, nil
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, userID primitive.ObjectID, role string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	user.Role = role
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) SetTwoFactor(ctx context.Context, userID primitive.ObjectID, tf *models.TwoFactor) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if user, ok := r.store.users[userID]; ok {
+		user.TwoFactor = copyTwoFactor(tf)
+		r.store.users[userID] = user
+	}
+
+	return nil
+}
+
+func (r *UserRepository) SetBackupCodes(ctx context.Context, userID primitive.ObjectID, hashes []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if user, ok := r.store.users[userID]; ok && user.TwoFactorEnabled() {
+		user.TwoFactor = copyTwoFactor(user.TwoFactor)
+		user.TwoFactor.BackupCodes = slices.Clone(hashes)
+		r.store.users[userID] = user
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UseTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok || user.TwoFactor == nil || user.TwoFactor.LastUsedStep >= step {
+		return mongo.ErrNoDocuments
+	}
+
+	user.TwoFactor = copyTwoFactor(user.TwoFactor)
+	user.TwoFactor.LastUsedStep = step
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) UseBackupCode(ctx context.Context, userID primitive.ObjectID, hash string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok || user.TwoFactor == nil || !slices.Contains(user.TwoFactor.BackupCodes, hash) {
+		return mongo.ErrNoDocuments
+	}
+
+	user.TwoFactor = copyTwoFactor(user.TwoFactor)
+	user.TwoFactor.BackupCodes = slices.DeleteFunc(user.TwoFactor.BackupCodes, func(h string) bool { return h == hash })
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID primitive.ObjectID, email string, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok || user.Email != email {
+		return mongo.ErrNoDocuments
+	}
+
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &at
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID primitive.ObjectID, hash string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	user.Password = hash
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) UpdateName(ctx context.Context, userID primitive.ObjectID, name string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	user.Name = name
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) ChangeEmail(ctx context.Context, userID primitive.ObjectID, email string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+	for id, existing := range r.store.users {
+		if id != userID && existing.Email == email {
+			return duplicateKeyError("users.email")
+		}
+	}
+
+	user.Email = email
+	user.EmailVerified = false
+	user.EmailVerifiedAt = nil
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) CloseUser(ctx context.Context, userID primitive.ObjectID, now time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok || user.WalletBalance != 0 || user.Closed() {
+		return mongo.ErrNoDocuments
+	}
+
+	user.ClosedAt = &now
+	r.store.users[userID] = user
+	return nil
+}
+
+// copyTwoFactor keeps stored users from sharing an enrolment with the copies
+// handed out to callers
+func copyTwoFactor(tf *models.TwoFactor) *models.TwoFactor {
+	if tf == nil {
+		return nil
+	}
+	c := *tf
+	c.BackupCodes = slices.Clone(tf.BackupCodes)
+	return &c
+}
+
+func (r *UserRepository) SubmitKYC(ctx context.Context, userID primitive.ObjectID, kyc *models.KYC) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok || user.KYCStatus() == models.KYCPending || user.KYCStatus() == models.KYCVerified {
+		return mongo.ErrNoDocuments
+	}
+
+	user.KYC = copyKYC(kyc)
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) ReviewKYC(ctx context.Context, userID primitive.ObjectID, from []string, status string, tier int, reason string, at time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[userID]
+	if !ok || user.KYC == nil || !slices.Contains(from, user.KYC.Status) {
+		return mongo.ErrNoDocuments
+	}
+
+	user.KYC = copyKYC(user.KYC)
+	user.KYC.Status = status
+	user.KYC.Tier = tier
+	user.KYC.ReviewedAt = &at
+	user.KYC.RejectionReason = reason
+	r.store.users[userID] = user
+	return nil
+}
+
+func (r *UserRepository) ListUsersByKYCStatus(ctx context.Context, status string) ([]models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	users := []models.User{}
+	for _, user := range r.store.users {
+		if !user.Closed() && user.KYCStatus() == status {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		a, b := users[i].KYC, users[j].KYC
+		if a != nil && b != nil && a.SubmittedAt != nil && b.SubmittedAt != nil && !a.SubmittedAt.Equal(*b.SubmittedAt) {
+			return a.SubmittedAt.Before(*b.SubmittedAt)
+		}
+		return users[i].ID.Hex() < users[j].ID.Hex()
+	})
+	return users, nil
+}
+
+// copyKYC keeps stored users from sharing documents with the copies handed
+// out to callers
+func copyKYC(kyc *models.KYC) *models.KYC {
+	if kyc == nil {
+		return nil
+	}
+	c := *kyc
+	c.Documents = slices.Clone(kyc.Documents)
+	return &c
+}
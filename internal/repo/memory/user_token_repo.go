@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UserTokenRepository struct {
+	store *Store
+}
+
+func NewUserTokenRepository(store *Store) *UserTokenRepository {
+	return &UserTokenRepository{store: store}
+}
+
+func (r *UserTokenRepository) CreateUserToken(ctx context.Context, t *models.UserToken) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID()
+	}
+	r.store.userTokens[t.ID] = *t
+	return nil
+}
+
+func (r *UserTokenRepository) GetUserToken(ctx context.Context, id primitive.ObjectID) (*models.UserToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	t, ok := r.store.userTokens[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &t, nil
+}
+
+func (r *UserTokenRepository) UseUserToken(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	t, ok := r.store.userTokens[id]
+	if !ok || t.UsedAt != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	t.UsedAt = &now
+	r.store.userTokens[id] = t
+	return nil
+}
+
+func (r *UserTokenRepository) UseUserTokens(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, t := range r.store.userTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+			r.store.userTokens[id] = t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WalletRepository struct {
+	store *Store
+}
+
+func NewWalletRepository(store *Store) *WalletRepository {
+	return &WalletRepository{store: store}
+}
+
+func (r *WalletRepository) InsertTransaction(ctx context.Context, tx *models.WalletTransaction) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tx.ID = primitive.NewObjectID()
+	tx.CreatedAt = time.Now()
+
+	r.store.transactions = append(r.store.transactions, *tx)
+	return nil
+}
+
+func (r *WalletRepository) GetTransactionsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.WalletTransaction, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var transactions []models.WalletTransaction
+	for _, tx := range r.store.transactions {
+		if tx.UserID == userID {
+			transactions = append(transactions, tx)
+		}
+	}
+
+	return transactions, nil
+}
@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type WatchlistRepository struct {
+	store *Store
+}
+
+func NewWatchlistRepository(store *Store) *WatchlistRepository {
+	return &WatchlistRepository{store: store}
+}
+
+func (r *WatchlistRepository) CreateWatchlist(ctx context.Context, w *models.Watchlist) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.nameTaken(w.UserID, w.Name, primitive.NilObjectID) {
+		return duplicateKeyError("watchlists.userId_name")
+	}
+
+	w.ID = primitive.NewObjectID()
+	w.CreatedAt = time.Now()
+	w.UpdatedAt = w.CreatedAt
+
+	r.store.watchlists[w.ID] = cloneWatchlist(*w)
+	return nil
+}
+
+func (r *WatchlistRepository) GetWatchlist(ctx context.Context, id primitive.ObjectID) (*models.Watchlist, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	w, ok := r.store.watchlists[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	w = cloneWatchlist(w)
+	return &w, nil
+}
+
+func (r *WatchlistRepository) GetWatchlistsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Watchlist, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	watchlists := []models.Watchlist{}
+	for _, w := range r.store.watchlists {
+		if w.UserID == userID {
+			watchlists = append(watchlists, cloneWatchlist(w))
+		}
+	}
+
+	sort.Slice(watchlists, func(i, j int) bool { return watchlists[i].Name < watchlists[j].Name })
+	return watchlists, nil
+}
+
+func (r *WatchlistRepository) CountWatchlistsByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var count int64
+	for _, w := range r.store.watchlists {
+		if w.UserID == userID {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *WatchlistRepository) UpdateWatchlist(ctx context.Context, id primitive.ObjectID, name string, symbols []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	w, ok := r.store.watchlists[id]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	if r.nameTaken(w.UserID, name, id) {
+		return duplicateKeyError("watchlists.userId_name")
+	}
+
+	w.Name = name
+	w.Symbols = slices.Clone(symbols)
+	w.UpdatedAt = time.Now()
+	r.store.watchlists[id] = w
+	return nil
+}
+
+func (r *WatchlistRepository) AddSymbol(ctx context.Context, id primitive.ObjectID, symbol string) error {
+	return r.update(id, func(w *models.Watchlist) {
+		if !slices.Contains(w.Symbols, symbol) {
+			w.Symbols = append(w.Symbols, symbol)
+		}
+	})
+}
+
+func (r *WatchlistRepository) RemoveSymbol(ctx context.Context, id primitive.ObjectID, symbol string) error {
+	return r.update(id, func(w *models.Watchlist) {
+		w.Symbols = slices.DeleteFunc(w.Symbols, func(s string) bool { return s == symbol })
+	})
+}
+
+func (r *WatchlistRepository) DeleteWatchlist(ctx context.Context, id primitive.ObjectID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.watchlists[id]; !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	delete(r.store.watchlists, id)
+	return nil
+}
+
+func (r *WatchlistRepository) GetWatchlistQuotes(ctx context.Context, id primitive.ObjectID) ([]models.WatchlistQuote, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	quotes := []models.WatchlistQuote{}
+
+	w, ok := r.store.watchlists[id]
+	if !ok {
+		return quotes, nil
+	}
+
+	for _, symbol := range w.Symbols {
+		quote := models.WatchlistQuote{Symbol: symbol}
+		if stock, ok := r.store.stocks[symbol]; ok {
+			quote.Name = stock.Name
+			quote.Price = stock.Price
+			quote.PreviousClose = stock.PrevClose
+			quote.Status = stock.Status
+		}
+		quotes = append(quotes, quote)
+	}
+
+	return quotes, nil
+}
+
+func (r *WatchlistRepository) update(id primitive.ObjectID, apply func(*models.Watchlist)) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	w, ok := r.store.watchlists[id]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	w = cloneWatchlist(w)
+	apply(&w)
+	w.UpdatedAt = time.Now()
+	r.store.watchlists[id] = w
+	return nil
+}
+
+// nameTaken must be called with the store lock held
+func (r *WatchlistRepository) nameTaken(userID primitive.ObjectID, name string, except primitive.ObjectID) bool {
+	for id, w := range r.store.watchlists {
+		if id != except && w.UserID == userID && w.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneWatchlist(w models.Watchlist) models.Watchlist {
+	w.Symbols = slices.Clone(w.Symbols)
+	return w
+}
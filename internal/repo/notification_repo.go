@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoNotificationRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoNotificationRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoNotificationRepository {
+	return &MongoNotificationRepository{
+		collection: db.Collection("notifications"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+// CreateNotification inserts a notification. The unique alertId index makes a
+// second insert for the same alert fail with a duplicate key error.
+func (r *MongoNotificationRepository) CreateNotification(ctx context.Context, n *models.Notification) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+
+	result, err := r.collection.InsertOne(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	n.ID = result.InsertedID.(primitive.ObjectID)
+	r.logger.DebugContext(ctx, "notification created", "notification_id", n.ID.Hex(), "alert_id", n.AlertID.Hex())
+	return nil
+}
+
+// GetNotificationsByUser lists a user's notifications, newest first
+func (r *MongoNotificationRepository) GetNotificationsByUser(ctx context.Context, userID primitive.ObjectID, unreadOnly bool) ([]models.Notification, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"userId": userID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+
+	cursor, err := r.collection.Find(
+		ctx,
+		filter,
+		options.Find().SetSort(bson.M{"createdAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// GetNotification returns a notification by id
+func (r *MongoNotificationRepository) GetNotification(ctx context.Context, id primitive.ObjectID) (*models.Notification, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var n models.Notification
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&n); err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+// MarkRead flags a notification as read
+func (r *MongoNotificationRepository) MarkRead(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"read": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "notification read", "notification_id", id.Hex())
+	return nil
+}
@@ -2,30 +2,41 @@ package repo
 
 import (
 	"context"
+	"log/slog"
 	"time"
 
-	"concurrent-wallet-order-system/internal/config"
 	"concurrent-wallet-order-system/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type OrderRepository struct{}
+type MongoOrderRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
 
-func NewOrderRepository() *OrderRepository {
-	return &OrderRepository{}
+func NewMongoOrderRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoOrderRepository {
+	return &MongoOrderRepository{
+		collection: db.Collection("orders"),
+		timeout:    timeout,
+		logger:     logger,
+	}
 }
 
-func (r *OrderRepository) CreateOrder(order *models.Order) error {
-	collection := config.DB.Collection("orders")
+func (r *MongoOrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	order.CreatedAt = time.Now()
 
-	result, err := collection.InsertOne(context.Background(), order)
+	result, err := r.collection.InsertOne(ctx, order)
 	if err != nil {
 		return err
 	}
 
 	order.ID = result.InsertedID.(primitive.ObjectID)
+	r.logger.DebugContext(ctx, "order recorded", "order_id", order.ID.Hex(), "type", order.Type, "symbol", order.Symbol)
 	return nil
 }
@@ -2,8 +2,9 @@ package repo
 
 import (
 	"context"
+	"log/slog"
+	"time"
 
-	"concurrent-wallet-order-system/internal/config"
 	"concurrent-wallet-order-system/internal/models"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -13,18 +14,27 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type PortfolioRepository struct{}
+type MongoPortfolioRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
 
-func NewPortfolioRepository() *PortfolioRepository {
-	return &PortfolioRepository{}
+func NewMongoPortfolioRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoPortfolioRepository {
+	return &MongoPortfolioRepository{
+		collection: db.Collection("portfolio"),
+		timeout:    timeout,
+		logger:     logger,
+	}
 }
 
-func (r *PortfolioRepository) GetPortfolio(userID primitive.ObjectID, symbol string) (*models.Portfolio, error) {
-	collection := config.DB.Collection("portfolio")
+func (r *MongoPortfolioRepository) GetPortfolio(ctx context.Context, userID primitive.ObjectID, symbol string) (*models.Portfolio, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	var p models.Portfolio
-	err := collection.FindOne(
-		context.Background(),
+	err := r.collection.FindOne(
+		ctx,
 		bson.M{"userId": userID, "symbol": symbol},
 	).Decode(&p)
 
@@ -34,11 +44,12 @@ func (r *PortfolioRepository) GetPortfolio(userID primitive.ObjectID, symbol str
 
 	return &p, nil
 }
-func (r *PortfolioRepository) UpsertPortfolio(userID primitive.ObjectID, symbol string, qty int) error {
-	collection := config.DB.Collection("portfolio")
+func (r *MongoPortfolioRepository) UpsertPortfolio(ctx context.Context, userID primitive.ObjectID, symbol string, qty int) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
-	_, err := collection.UpdateOne(
-		context.Background(),
+	_, err := r.collection.UpdateOne(
+		ctx,
 		bson.M{"userId": userID, "symbol": symbol},
 		bson.M{
 			"$inc": bson.M{"quantity": qty},
@@ -49,25 +60,30 @@ func (r *PortfolioRepository) UpsertPortfolio(userID primitive.ObjectID, symbol
 		},
 		options.Update().SetUpsert(true),
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	r.logger.DebugContext(ctx, "portfolio updated", "symbol", symbol, "quantity_change", qty)
+	return nil
 }
 
-func (r *PortfolioRepository) GetUserPortfolio(userID primitive.ObjectID) ([]models.Portfolio, error) {
-	collection := config.DB.Collection("portfolio")
+func (r *MongoPortfolioRepository) GetUserPortfolio(ctx context.Context, userID primitive.ObjectID) ([]models.Portfolio, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
-	cursor, err := collection.Find(
-		context.Background(),
+	cursor, err := r.collection.Find(
+		ctx,
 		bson.M{"userId": userID},
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
 	var holdings []models.Portfolio
 
-	for cursor.Next(context.Background()) {
+	for cursor.Next(ctx) {
 		var p models.Portfolio
 		if err := cursor.Decode(&p); err != nil {
 			return nil, err
@@ -78,43 +94,149 @@ func (r *PortfolioRepository) GetUserPortfolio(userID primitive.ObjectID) ([]mod
 	return holdings, nil
 }
 
-func (r *PortfolioRepository) GetPortfolioWithAggregation(userID primitive.ObjectID) (bson.M, error) {
+// GetHoldingsBySymbol returns every non-empty position in a stock
+func (r *MongoPortfolioRepository) GetHoldingsBySymbol(ctx context.Context, symbol string) ([]models.Portfolio, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"symbol": symbol, "quantity": bson.M{"$gt": 0}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var holdings []models.Portfolio
+	if err := cursor.All(ctx, &holdings); err != nil {
+		return nil, err
+	}
+
+	return holdings, nil
+}
+
+// StartSettlement moves all qty shares of a position to settling in a
+// single atomic update, provided it still holds exactly that many; otherwise
+// it returns mongo.ErrNoDocuments
+func (r *MongoPortfolioRepository) StartSettlement(ctx context.Context, userID primitive.ObjectID, symbol string, qty int) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"userId": userID, "symbol": symbol, "quantity": qty},
+		bson.M{"$inc": bson.M{"quantity": -qty, "settling": qty}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "settlement started", "symbol", symbol, "quantity", qty)
+	return nil
+}
+
+// GetSettlementsBySymbol returns every position in a stock with shares
+// still settling
+func (r *MongoPortfolioRepository) GetSettlementsBySymbol(ctx context.Context, symbol string) ([]models.Portfolio, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"symbol": symbol, "settling": bson.M{"$gt": 0}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var holdings []models.Portfolio
+	if err := cursor.All(ctx, &holdings); err != nil {
+		return nil, err
+	}
+
+	return holdings, nil
+}
+
+// FinishSettlement clears the settling shares of a position once they are
+// paid for
+func (r *MongoPortfolioRepository) FinishSettlement(ctx context.Context, userID primitive.ObjectID, symbol string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"userId": userID, "symbol": symbol},
+		bson.M{"$unset": bson.M{"settling": ""}},
+	)
+	if err != nil {
+		return err
+	}
+
+	r.logger.DebugContext(ctx, "settlement finished", "symbol", symbol)
+	return nil
+}
+
+// FreezeHoldings marks all positions in a stock as frozen
+func (r *MongoPortfolioRepository) FreezeHoldings(ctx context.Context, symbol string) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"symbol": symbol, "quantity": bson.M{"$gt": 0}},
+		bson.M{"$set": bson.M{"frozen": true}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	r.logger.DebugContext(ctx, "holdings frozen", "symbol", symbol, "positions", result.ModifiedCount)
+	return result.ModifiedCount, nil
+}
 
-	collection := config.DB.Collection("portfolio")
+func (r *MongoPortfolioRepository) GetPortfolioWithAggregation(ctx context.Context, userID primitive.ObjectID) (bson.M, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	pipeline := mongo.Pipeline{
-		{{"$match", bson.D{{"userId", userID}}}},
-		{{"$lookup", bson.D{
-			{"from", "stocks"},
-			{"localField", "symbol"},
-			{"foreignField", "symbol"},
-			{"as", "stockInfo"},
+		{{Key: "$match", Value: bson.D{{Key: "userId", Value: userID}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "stocks"},
+			{Key: "localField", Value: "symbol"},
+			{Key: "foreignField", Value: "symbol"},
+			{Key: "as", Value: "stockInfo"},
 		}}},
-		{{"$unwind", "$stockInfo"}},
-		{{"$project", bson.D{
-			{"symbol", 1},
-			{"quantity", 1},
-			{"stockName", "$stockInfo.name"},
-			{"currentPrice", "$stockInfo.price"},
-			{"totalValue", bson.D{
-				{"$multiply", bson.A{"$quantity", "$stockInfo.price"}},
+		{{Key: "$unwind", Value: "$stockInfo"}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "symbol", Value: 1},
+			{Key: "quantity", Value: 1},
+			{Key: "stockName", Value: "$stockInfo.name"},
+			{Key: "currentPrice", Value: "$stockInfo.price"},
+			{Key: "totalValue", Value: bson.D{
+				{Key: "$multiply", Value: bson.A{"$quantity", "$stockInfo.price"}},
 			}},
 		}}},
-		{{"$group", bson.D{
-			{"_id", nil},
-			{"holdings", bson.D{{"$push", "$$ROOT"}}},
-			{"totalPortfolioValue", bson.D{{"$sum", "$totalValue"}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "holdings", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
+			{Key: "totalPortfolioValue", Value: bson.D{{Key: "$sum", Value: "$totalValue"}}},
 		}}},
 	}
 
-	cursor, err := collection.Aggregate(context.Background(), pipeline)
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
 	var results []bson.M
-	if err := cursor.All(context.Background(), &results); err != nil {
+	if err := cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The services depend on these interfaces rather than on the Mongo
+// implementations, so they can run against the in-memory backend in
+// internal/repo/memory. Implementations report a missing document with
+// mongo.ErrNoDocuments and a unique index violation with an error for which
+// mongo.IsDuplicateKeyError is true.
+//
+// Every method takes the caller's context; the Mongo implementations also
+// apply their configured per-operation timeout to it.
+
+type UserRepository interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	UpdateWalletBalance(ctx context.Context, userID primitive.ObjectID, newBalance float64) error
+	CreditOnce(ctx context.Context, userID primitive.ObjectID, key string, amount float64) (bool, error)
+	SearchUsers(ctx context.Context, f UserFilter) ([]models.User, int64, error)
+	SetRole(ctx context.Context, userID primitive.ObjectID, role string) error
+	SetTwoFactor(ctx context.Context, userID primitive.ObjectID, tf *models.TwoFactor) error
+	SetBackupCodes(ctx context.Context, userID primitive.ObjectID, hashes []string) error
+	UseTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) error
+	UseBackupCode(ctx context.Context, userID primitive.ObjectID, hash string) error
+	MarkEmailVerified(ctx context.Context, userID primitive.ObjectID, email string, at time.Time) error
+	UpdatePassword(ctx context.Context, userID primitive.ObjectID, hash string) error
+	UpdateName(ctx context.Context, userID primitive.ObjectID, name string) error
+	ChangeEmail(ctx context.Context, userID primitive.ObjectID, email string) error
+	CloseUser(ctx context.Context, userID primitive.ObjectID, now time.Time) error
+	SubmitKYC(ctx context.Context, userID primitive.ObjectID, kyc *models.KYC) error
+	ReviewKYC(ctx context.Context, userID primitive.ObjectID, from []string, status string, tier int, reason string, at time.Time) error
+	ListUsersByKYCStatus(ctx context.Context, status string) ([]models.User, error)
+}
+
+// LimitUsageRepository adds up each user's daily amounts against their limits
+type LimitUsageRepository interface {
+	GetLimitUsage(ctx context.Context, userID primitive.ObjectID, day string) (*models.LimitUsage, error)
+	AddLimitUsage(ctx context.Context, userID primitive.ObjectID, day, kind string, amount, limit float64, expiresAt time.Time) error
+}
+
+// UserTokenRepository records the tokens mailed to users, so each is used once
+type UserTokenRepository interface {
+	CreateUserToken(ctx context.Context, t *models.UserToken) error
+	GetUserToken(ctx context.Context, id primitive.ObjectID) (*models.UserToken, error)
+	UseUserToken(ctx context.Context, id primitive.ObjectID, now time.Time) error
+	UseUserTokens(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time) error
+}
+
+type WalletRepository interface {
+	InsertTransaction(ctx context.Context, tx *models.WalletTransaction) error
+	GetTransactionsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.WalletTransaction, error)
+}
+
+type StockRepository interface {
+	CreateStock(ctx context.Context, stock *models.Stock) error
+	GetAllStocks(ctx context.Context, includeDelisted bool) ([]models.Stock, error)
+	SearchStocks(ctx context.Context, f StockFilter) ([]models.Stock, int64, error)
+	GetStocksBySymbols(ctx context.Context, symbols []string) ([]models.Stock, error)
+	GetStockBySymbol(ctx context.Context, symbol string) (*models.Stock, error)
+	BulkUpsertStocks(ctx context.Context, stocks []models.Stock) (inserted int64, updated int64, delisted []string, err error)
+	UpdatePrice(ctx context.Context, symbol string, price, previousClose float64, at time.Time) error
+	UpdateStatus(ctx context.Context, symbol, status string) error
+	MarkDelisted(ctx context.Context, symbol string, finalPrice float64) error
+}
+
+type OrderRepository interface {
+	CreateOrder(ctx context.Context, order *models.Order) error
+}
+
+type PortfolioRepository interface {
+	GetPortfolio(ctx context.Context, userID primitive.ObjectID, symbol string) (*models.Portfolio, error)
+	UpsertPortfolio(ctx context.Context, userID primitive.ObjectID, symbol string, qty int) error
+	GetUserPortfolio(ctx context.Context, userID primitive.ObjectID) ([]models.Portfolio, error)
+	GetHoldingsBySymbol(ctx context.Context, symbol string) ([]models.Portfolio, error)
+	StartSettlement(ctx context.Context, userID primitive.ObjectID, symbol string, qty int) error
+	GetSettlementsBySymbol(ctx context.Context, symbol string) ([]models.Portfolio, error)
+	FinishSettlement(ctx context.Context, userID primitive.ObjectID, symbol string) error
+	FreezeHoldings(ctx context.Context, symbol string) (int64, error)
+}
+
+type WatchlistRepository interface {
+	CreateWatchlist(ctx context.Context, w *models.Watchlist) error
+	GetWatchlist(ctx context.Context, id primitive.ObjectID) (*models.Watchlist, error)
+	GetWatchlistsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Watchlist, error)
+	CountWatchlistsByUser(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	UpdateWatchlist(ctx context.Context, id primitive.ObjectID, name string, symbols []string) error
+	AddSymbol(ctx context.Context, id primitive.ObjectID, symbol string) error
+	RemoveSymbol(ctx context.Context, id primitive.ObjectID, symbol string) error
+	DeleteWatchlist(ctx context.Context, id primitive.ObjectID) error
+	GetWatchlistQuotes(ctx context.Context, id primitive.ObjectID) ([]models.WatchlistQuote, error)
+}
+
+type AlertRepository interface {
+	CreateAlert(ctx context.Context, alert *models.Alert) error
+	GetAlert(ctx context.Context, id primitive.ObjectID) (*models.Alert, error)
+	GetActiveAlertsBySymbol(ctx context.Context, symbol string) ([]models.Alert, error)
+	GetAlertsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Alert, error)
+	MarkTriggered(ctx context.Context, id primitive.ObjectID, price float64, at time.Time) (bool, error)
+	ReleaseTrigger(ctx context.Context, id primitive.ObjectID) error
+	DeleteAlert(ctx context.Context, id primitive.ObjectID) error
+}
+
+type NotificationRepository interface {
+	CreateNotification(ctx context.Context, n *models.Notification) error
+	GetNotification(ctx context.Context, id primitive.ObjectID) (*models.Notification, error)
+	GetNotificationsByUser(ctx context.Context, userID primitive.ObjectID, unreadOnly bool) ([]models.Notification, error)
+	MarkRead(ctx context.Context, id primitive.ObjectID) error
+}
+
+// LoginAttemptRepository tracks failed logins per account and client IP
+type LoginAttemptRepository interface {
+	GetLoginFailures(ctx context.Context, key string) (*models.LoginFailures, error)
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginFailures, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ClearLoginFailures(ctx context.Context, key string) error
+}
+
+type SecurityEventRepository interface {
+	CreateSecurityEvent(ctx context.Context, e *models.SecurityEvent) error
+	ListSecurityEvents(ctx context.Context, f SecurityEventFilter) ([]models.SecurityEvent, error)
+}
+
+// SessionRepository stores login sessions and their refresh token hashes
+type SessionRepository interface {
+	CreateSession(ctx context.Context, s *models.Session) error
+	GetSession(ctx context.Context, id primitive.ObjectID) (*models.Session, error)
+	RotateSession(ctx context.Context, id primitive.ObjectID, oldHash, newHash, ip, userAgent string, now, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, id, userID primitive.ObjectID, reason string, now time.Time) error
+	RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, reason string, now time.Time) (int64, error)
+	ListActiveSessions(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]models.Session, error)
+}
+
+// AuditRepository stores the append-only audit log
+type AuditRepository interface {
+	LastAuditEntry(ctx context.Context) (*models.AuditEntry, error)
+	InsertAuditEntry(ctx context.Context, e *models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, f AuditFilter) ([]models.AuditEntry, int64, error)
+	AuditEntriesFrom(ctx context.Context, seq, limit int64) ([]models.AuditEntry, error)
+}
+
+// withTimeout bounds a single Mongo operation. Cancelling the parent context,
+// e.g. when the HTTP client goes away, still aborts the operation early.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Compile-time checks that the Mongo implementations satisfy the interfaces
+var (
+	_ UserRepository          = (*MongoUserRepository)(nil)
+	_ WalletRepository        = (*MongoWalletRepository)(nil)
+	_ StockRepository         = (*MongoStockRepository)(nil)
+	_ OrderRepository         = (*MongoOrderRepository)(nil)
+	_ PortfolioRepository     = (*MongoPortfolioRepository)(nil)
+	_ WatchlistRepository     = (*MongoWatchlistRepository)(nil)
+	_ AlertRepository         = (*MongoAlertRepository)(nil)
+	_ NotificationRepository  = (*MongoNotificationRepository)(nil)
+	_ LoginAttemptRepository  = (*MongoLoginAttemptRepository)(nil)
+	_ SecurityEventRepository = (*MongoSecurityEventRepository)(nil)
+	_ SessionRepository       = (*MongoSessionRepository)(nil)
+	_ UserTokenRepository     = (*MongoUserTokenRepository)(nil)
+	_ LimitUsageRepository    = (*MongoLimitUsageRepository)(nil)
+	_ AuditRepository         = (*MongoAuditRepository)(nil)
+)
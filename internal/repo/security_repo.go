@@ -0,0 +1,188 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoLoginAttemptRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoLoginAttemptRepository {
+	return &MongoLoginAttemptRepository{
+		collection: db.Collection("login_failures"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+// GetLoginFailures returns the failures recorded under key
+func (r *MongoLoginAttemptRepository) GetLoginFailures(ctx context.Context, key string) (*models.LoginFailures, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var f models.LoginFailures
+	if err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&f); err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// RecordLoginFailure counts a failure at now in a single atomic update. The
+// count restarts when the previous failure is older than window.
+func (r *MongoLoginAttemptRepository) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginFailures, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"count": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$lastFailure", now.Add(-window)}},
+				bson.M{"$add": bson.A{"$count", 1}},
+				1,
+			}},
+			"lastFailure": now,
+			"expiresAt":   bson.M{"$max": bson.A{"$expiresAt", now.Add(window)}},
+		}}},
+	}
+
+	var f models.LoginFailures
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		pipeline,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&f)
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent failure created the record first; count on top of it
+		err = r.collection.FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": key},
+			pipeline,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// LockLogin blocks logins under key until the given time
+func (r *MongoLoginAttemptRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$set": bson.M{"lockedUntil": until},
+			"$max": bson.M{"expiresAt": until},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	r.logger.DebugContext(ctx, "login locked", "key", key, "until", until)
+	return nil
+}
+
+// ClearLoginFailures forgets the failures and any lock under key
+func (r *MongoLoginAttemptRepository) ClearLoginFailures(ctx context.Context, key string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+type MongoSecurityEventRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+// SecurityEventFilter narrows a listing of the security log. Zero values mean
+// "no constraint".
+type SecurityEventFilter struct {
+	Type   string
+	UserID primitive.ObjectID
+	Email  string
+	Limit  int64
+}
+
+func NewMongoSecurityEventRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoSecurityEventRepository {
+	return &MongoSecurityEventRepository{
+		collection: db.Collection("security_events"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+// CreateSecurityEvent appends an event to the security log
+func (r *MongoSecurityEventRepository) CreateSecurityEvent(ctx context.Context, e *models.SecurityEvent) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	result, err := r.collection.InsertOne(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	e.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// ListSecurityEvents returns matching events, newest first
+func (r *MongoSecurityEventRepository) ListSecurityEvents(ctx context.Context, f SecurityEventFilter) ([]models.SecurityEvent, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if f.Type != "" {
+		filter["type"] = f.Type
+	}
+	if !f.UserID.IsZero() {
+		filter["userId"] = f.UserID
+	}
+	if f.Email != "" {
+		filter["email"] = f.Email
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	if f.Limit > 0 {
+		opts.SetLimit(f.Limit)
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.SecurityEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RefreshHashHistory is how many exchanged refresh tokens a session
+// remembers for reuse detection
+const RefreshHashHistory = 100
+
+type MongoSessionRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoSessionRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoSessionRepository {
+	return &MongoSessionRepository{
+		collection: db.Collection("sessions"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+func (r *MongoSessionRepository) CreateSession(ctx context.Context, s *models.Session) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	s.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *MongoSessionRepository) GetSession(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var s models.Session
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// RotateSession replaces the session's refresh token hash, provided oldHash
+// is still the current one and the session is not revoked; otherwise it
+// returns mongo.ErrNoDocuments. The check and the swap are one atomic
+// update, so a token can only be exchanged once.
+func (r *MongoSessionRepository) RotateSession(ctx context.Context, id primitive.ObjectID, oldHash, newHash, ip, userAgent string, now, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "refreshHash": oldHash, "revokedAt": nil},
+		bson.M{
+			"$set": bson.M{
+				"refreshHash": newHash,
+				"ip":          ip,
+				"userAgent":   userAgent,
+				"lastUsedAt":  now,
+				"expiresAt":   expiresAt,
+			},
+			"$push": bson.M{"previousHashes": bson.M{"$each": bson.A{oldHash}, "$slice": -RefreshHashHistory}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// RevokeSession revokes one of the user's sessions; mongo.ErrNoDocuments
+// means the user has no such active session
+func (r *MongoSessionRepository) RevokeSession(ctx context.Context, id, userID primitive.ObjectID, reason string, now time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "userId": userID, "revokedAt": nil},
+		bson.M{"$set": bson.M{"revokedAt": now, "revokeReason": reason}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "session revoked", "session_id", id.Hex(), "reason", reason)
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of the user and returns
+// how many there were
+func (r *MongoSessionRepository) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, reason string, now time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"userId": userID, "revokedAt": nil, "expiresAt": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"revokedAt": now, "revokeReason": reason}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// ListActiveSessions returns the user's unrevoked, unexpired sessions, most
+// recently used first
+func (r *MongoSessionRepository) ListActiveSessions(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]models.Session, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"userId": userID, "revokedAt": nil, "expiresAt": bson.M{"$gt": now}},
+		options.Find().
+			SetSort(bson.D{{Key: "lastUsedAt", Value: -1}}).
+			SetProjection(bson.M{"refreshHash": 0, "previousHashes": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
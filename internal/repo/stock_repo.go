@@ -2,49 +2,87 @@ package repo
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
 	"time"
 
-	"concurrent-wallet-order-system/internal/config"
 	"concurrent-wallet-order-system/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type StockRepository struct{}
+type MongoStockRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+// StockFilter describes a search over the stocks collection.
+// Zero values mean "no constraint".
+type StockFilter struct {
+	Text            string // full-text search on symbol and name
+	Prefix          string // symbol or name prefix
+	Sectors         []string
+	Industry        string
+	Exchange        string
+	MinPrice        float64
+	MaxPrice        float64
+	IncludeDelisted bool
+	SortBy          string // symbol, name, price or marketCap
+	SortDesc        bool
+	Page            int64
+	Limit           int64
+}
 
-func NewStockRepository() *StockRepository {
-	return &StockRepository{}
+func NewMongoStockRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoStockRepository {
+	return &MongoStockRepository{
+		collection: db.Collection("stocks"),
+		timeout:    timeout,
+		logger:     logger,
+	}
 }
 
 // Create stock
-func (r *StockRepository) CreateStock(stock *models.Stock) error {
-	collection := config.DB.Collection("stocks")
+func (r *MongoStockRepository) CreateStock(ctx context.Context, stock *models.Stock) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	stock.CreatedAt = time.Now()
 
-	result, err := collection.InsertOne(context.Background(), stock)
+	result, err := r.collection.InsertOne(ctx, stock)
 	if err != nil {
 		return err
 	}
 
 	stock.ID = result.InsertedID.(primitive.ObjectID)
+	r.logger.DebugContext(ctx, "stock created", "symbol", stock.Symbol)
 	return nil
 }
 
-// Get all stocks
-func (r *StockRepository) GetAllStocks() ([]models.Stock, error) {
-	collection := config.DB.Collection("stocks")
+// Get all stocks, optionally including delisted ones
+func (r *MongoStockRepository) GetAllStocks(ctx context.Context, includeDelisted bool) ([]models.Stock, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
-	cursor, err := collection.Find(context.Background(), bson.M{})
+	filter := bson.M{}
+	if !includeDelisted {
+		filter["status"] = bson.M{"$ne": models.StockStatusDelisted}
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
 	var stocks []models.Stock
 
-	for cursor.Next(context.Background()) {
+	for cursor.Next(ctx) {
 		var stock models.Stock
 		if err := cursor.Decode(&stock); err != nil {
 			return nil, err
@@ -55,13 +93,223 @@ func (r *StockRepository) GetAllStocks() ([]models.Stock, error) {
 	return stocks, nil
 }
 
+// SearchStocks returns one page of stocks matching the filter and the total match count
+func (r *MongoStockRepository) SearchStocks(ctx context.Context, f StockFilter) ([]models.Stock, int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+
+	if f.Text != "" {
+		filter["$text"] = bson.M{"$search": f.Text}
+	}
+
+	if f.Prefix != "" {
+		quoted := regexp.QuoteMeta(f.Prefix)
+		filter["$or"] = bson.A{
+			bson.M{"symbol": bson.M{"$regex": "^" + regexp.QuoteMeta(strings.ToUpper(f.Prefix))}},
+			bson.M{"name": bson.M{"$regex": "^" + quoted, "$options": "i"}},
+		}
+	}
+
+	if len(f.Sectors) > 0 {
+		filter["sector"] = bson.M{"$in": f.Sectors}
+	}
+
+	if f.Industry != "" {
+		filter["industry"] = f.Industry
+	}
+
+	if f.Exchange != "" {
+		filter["exchange"] = f.Exchange
+	}
+
+	price := bson.M{}
+	if f.MinPrice > 0 {
+		price["$gte"] = f.MinPrice
+	}
+	if f.MaxPrice > 0 {
+		price["$lte"] = f.MaxPrice
+	}
+	if len(price) > 0 {
+		filter["price"] = price
+	}
+
+	if !f.IncludeDelisted {
+		filter["status"] = bson.M{"$ne": models.StockStatusDelisted}
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSkip((f.Page - 1) * f.Limit).
+		SetLimit(f.Limit)
+
+	switch {
+	case f.SortBy != "":
+		direction := 1
+		if f.SortDesc {
+			direction = -1
+		}
+		// symbol is unique, so it makes the page order stable
+		findOptions.SetSort(bson.D{{Key: f.SortBy, Value: direction}, {Key: "symbol", Value: 1}})
+	case f.Text != "":
+		findOptions.SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}})
+		findOptions.SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}})
+	default:
+		findOptions.SetSort(bson.D{{Key: "symbol", Value: 1}})
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	stocks := []models.Stock{}
+	if err := cursor.All(ctx, &stocks); err != nil {
+		return nil, 0, err
+	}
+
+	return stocks, total, nil
+}
+
+// UpdatePrice sets the current price and the reference price for the day change
+func (r *MongoStockRepository) UpdatePrice(ctx context.Context, symbol string, price, previousClose float64, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"symbol": symbol},
+		bson.M{"$set": bson.M{
+			"price":          price,
+			"previousClose":  previousClose,
+			"priceUpdatedAt": at,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "stock price updated", "symbol", symbol, "price", price)
+	return nil
+}
+
+// GetStocksBySymbols fetches the stocks that exist among the given symbols
+func (r *MongoStockRepository) GetStocksBySymbols(ctx context.Context, symbols []string) ([]models.Stock, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"symbol": bson.M{"$in": symbols}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stocks []models.Stock
+	if err := cursor.All(ctx, &stocks); err != nil {
+		return nil, err
+	}
+
+	return stocks, nil
+}
+
+// BulkUpsertStocks inserts new stocks and updates existing ones by symbol in a
+// single unordered bulk write. Empty metadata fields leave stored values intact;
+// previousClose and priceUpdatedAt are set as given. Delisted stocks are left
+// alone and their symbols returned.
+func (r *MongoStockRepository) BulkUpsertStocks(ctx context.Context, stocks []models.Stock) (inserted int64, updated int64, delisted []string, err error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if len(stocks) == 0 {
+		return 0, 0, nil, nil
+	}
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(stocks))
+
+	for _, stock := range stocks {
+		set := bson.M{
+			"name":           stock.Name,
+			"price":          stock.Price,
+			"previousClose":  stock.PrevClose,
+			"priceUpdatedAt": stock.PriceAt,
+		}
+		if stock.Sector != "" {
+			set["sector"] = stock.Sector
+		}
+		if stock.Industry != "" {
+			set["industry"] = stock.Industry
+		}
+		if stock.Exchange != "" {
+			set["exchange"] = stock.Exchange
+		}
+		if stock.MarketCap > 0 {
+			set["marketCap"] = stock.MarketCap
+		}
+
+		// A delisted stock does not match, so its upsert fails on the unique
+		// symbol index instead of reviving it
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{
+				"symbol": stock.Symbol,
+				"status": bson.M{"$ne": models.StockStatusDelisted},
+			}).
+			SetUpdate(bson.M{
+				"$set": set,
+				"$setOnInsert": bson.M{
+					"symbol":    stock.Symbol,
+					"status":    models.StockStatusActive,
+					"createdAt": now,
+				},
+			}).
+			SetUpsert(true))
+	}
+
+	result, err := r.collection.BulkWrite(
+		ctx,
+		writes,
+		options.BulkWrite().SetOrdered(false),
+	)
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, we := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(we.WriteError) {
+				return 0, 0, nil, err
+			}
+			delisted = append(delisted, stocks[we.Index].Symbol)
+		}
+		err = nil
+	}
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	r.logger.DebugContext(ctx, "stocks upserted", "inserted", result.UpsertedCount, "updated", result.MatchedCount, "delisted", len(delisted))
+	return result.UpsertedCount, result.MatchedCount, delisted, nil
+}
+
 // Get stock by symbol
-func (r *StockRepository) GetStockBySymbol(symbol string) (*models.Stock, error) {
-	collection := config.DB.Collection("stocks")
+func (r *MongoStockRepository) GetStockBySymbol(ctx context.Context, symbol string) (*models.Stock, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	var stock models.Stock
-	err := collection.FindOne(
-		context.Background(),
+	err := r.collection.FindOne(
+		ctx,
 		bson.M{"symbol": symbol},
 	).Decode(&stock)
 
@@ -71,3 +319,51 @@ func (r *StockRepository) GetStockBySymbol(symbol string) (*models.Stock, error)
 
 	return &stock, nil
 }
+
+// UpdateStatus sets the lifecycle status of a stock
+func (r *MongoStockRepository) UpdateStatus(ctx context.Context, symbol, status string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"symbol": symbol},
+		bson.M{"$set": bson.M{"status": status}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "stock status updated", "symbol", symbol, "status", status)
+	return nil
+}
+
+// MarkDelisted retires a stock and pins its price to the final settlement price
+func (r *MongoStockRepository) MarkDelisted(ctx context.Context, symbol string, finalPrice float64) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"symbol": symbol},
+		bson.M{"$set": bson.M{
+			"status":     models.StockStatusDelisted,
+			"price":      finalPrice,
+			"delistedAt": time.Now(),
+		}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "stock delisted", "symbol", symbol, "final_price", finalPrice)
+	return nil
+}
@@ -2,45 +2,71 @@ package repo
 
 import (
 	"context"
+	"log/slog"
+	"regexp"
 	"time"
 
-	"concurrent-wallet-order-system/internal/config"
 	"concurrent-wallet-order-system/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type UserRepository struct{}
+// UserFilter describes an admin search over the users collection. Zero
+// values mean "no constraint".
+type UserFilter struct {
+	Name        string    // case-insensitive name prefix
+	Email       string    // case-insensitive email prefix
+	CreatedFrom time.Time // inclusive
+	CreatedTo   time.Time // exclusive
+	Status      string    // models.UserStatusActive or models.UserStatusClosed
+	Page        int64
+	Limit       int64
+}
 
-func NewUserRepository() *UserRepository {
-	return &UserRepository{}
+type MongoUserRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoUserRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoUserRepository {
+	return &MongoUserRepository{
+		collection: db.Collection("users"),
+		timeout:    timeout,
+		logger:     logger,
+	}
 }
 
 // CreateUser inserts a new user
-func (r *UserRepository) CreateUser(user *models.User) error {
-	collection := config.DB.Collection("users")
+func (r *MongoUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	user.CreatedAt = time.Now()
 	user.WalletBalance = 0
 
-	result, err := collection.InsertOne(context.Background(), user)
+	result, err := r.collection.InsertOne(ctx, user)
 	if err != nil {
 		return err
 	}
 
 	// IMPORTANT: assign inserted ID back to user struct
 	user.ID = result.InsertedID.(primitive.ObjectID)
+	r.logger.DebugContext(ctx, "user created", "user_id", user.ID.Hex())
 
 	return nil
 }
 // GetUserByEmail finds user by email
-func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
-	collection := config.DB.Collection("users")
+func (r *MongoUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	var user models.User
-	err := collection.FindOne(
-		context.Background(),
+	err := r.collection.FindOne(
+		ctx,
 		bson.M{"email": email},
 	).Decode(&user)
 
@@ -52,12 +78,13 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 }
 
 // GetUserByID finds user by ID
-func (r *UserRepository) GetUserByID(id primitive.ObjectID) (*models.User, error) {
-	collection := config.DB.Collection("users")
+func (r *MongoUserRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	var user models.User
-	err := collection.FindOne(
-		context.Background(),
+	err := r.collection.FindOne(
+		ctx,
 		bson.M{"_id": id},
 	).Decode(&user)
 
@@ -69,35 +96,365 @@ func (r *UserRepository) GetUserByID(id primitive.ObjectID) (*models.User, error
 }
 
 // UpdateWalletBalance updates user's wallet balance
-func (r *UserRepository) UpdateWalletBalance(userID primitive.ObjectID, newBalance float64) error {
-	collection := config.DB.Collection("users")
+func (r *MongoUserRepository) UpdateWalletBalance(ctx context.Context, userID primitive.ObjectID, newBalance float64) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
-	_, err := collection.UpdateOne(
-		context.Background(),
+	_, err := r.collection.UpdateOne(
+		ctx,
 		bson.M{"_id": userID},
-		bson.M{"$set": bson.M{"walletBalance": newBalance}},
+		bson.M{"$set": bson.M{"walletbalance": newBalance}},
 	)
+	if err != nil {
+		return err
+	}
+
+	r.logger.DebugContext(ctx, "wallet balance updated", "user_id", userID.Hex(), "balance", newBalance)
+	return nil
+}
+
+// CreditOnce adds amount to the balance and records key in a single atomic
+// update, unless key was recorded before. It reports whether it paid.
+func (r *MongoUserRepository) CreditOnce(ctx context.Context, userID primitive.ObjectID, key string, amount float64) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "settlements": bson.M{"$ne": key}},
+		bson.M{
+			"$inc":  bson.M{"walletbalance": amount},
+			"$push": bson.M{"settlements": key},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	paid := result.ModifiedCount == 1
+	r.logger.DebugContext(ctx, "one-off credit", "user_id", userID.Hex(), "key", key, "amount", amount, "paid", paid)
+	return paid, nil
+}
+
+// SearchUsers returns a page of the users matching f, newest first, and
+// how many match in all
+func (r *MongoUserRepository) SearchUsers(ctx context.Context, f UserFilter) ([]models.User, int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+
+	if f.Name != "" {
+		filter["name"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.Name), "$options": "i"}
+	}
+
+	if f.Email != "" {
+		filter["email"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.Email), "$options": "i"}
+	}
+
+	created := bson.M{}
+	if !f.CreatedFrom.IsZero() {
+		created["$gte"] = f.CreatedFrom
+	}
+	if !f.CreatedTo.IsZero() {
+		created["$lt"] = f.CreatedTo
+	}
+	if len(created) > 0 {
+		filter["createdAt"] = created
+	}
+
+	switch f.Status {
+	case models.UserStatusActive:
+		filter["closedAt"] = nil
+	case models.UserStatusClosed:
+		filter["closedAt"] = bson.M{"$ne": nil}
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSkip((f.Page - 1) * f.Limit).
+		SetLimit(f.Limit).
+		// _id breaks ties between users created in the same instant
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
 
+	return users, total, nil
+}
+
+// SetRole sets the user's role
+func (r *MongoUserRepository) SetRole(ctx context.Context, userID primitive.ObjectID, role string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// SetTwoFactor replaces the user's two-factor enrolment; nil removes it
+func (r *MongoUserRepository) SetTwoFactor(ctx context.Context, userID primitive.ObjectID, tf *models.TwoFactor) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"twoFactor": tf}}
+	if tf == nil {
+		update = bson.M{"$unset": bson.M{"twoFactor": ""}}
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
+	return err
+}
+
+// SetBackupCodes replaces the hashes of the user's backup codes
+func (r *MongoUserRepository) SetBackupCodes(ctx context.Context, userID primitive.ObjectID, hashes []string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "twoFactor.enabled": true},
+		bson.M{"$set": bson.M{"twoFactor.backupCodes": hashes}},
+	)
 	return err
 }
 
-func (r *UserRepository) GetAllUsers() ([]models.User, error) {
-	collection := config.DB.Collection("users")
+// UseTOTPStep records that a TOTP code of the given time step was accepted.
+// It returns mongo.ErrNoDocuments if that step or a later one was already
+// used, so each code works once even when sent twice at the same time.
+func (r *MongoUserRepository) UseTOTPStep(ctx context.Context, userID primitive.ObjectID, step int64) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
-	cursor, err := collection.Find(context.Background(), bson.M{})
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "twoFactor.lastUsedStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"twoFactor.lastUsedStep": step}},
+	)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// MarkEmailVerified marks the user's email as verified, provided it is still
+// email; otherwise it returns mongo.ErrNoDocuments, so a link sent to an
+// address the user has since changed verifies nothing
+func (r *MongoUserRepository) MarkEmailVerified(ctx context.Context, userID primitive.ObjectID, email string, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "email": email},
+		bson.M{"$set": bson.M{"emailVerified": true, "emailVerifiedAt": at}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the user's bcrypt password hash
+func (r *MongoUserRepository) UpdatePassword(ctx context.Context, userID primitive.ObjectID, hash string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"password": hash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (r *MongoUserRepository) UpdateName(ctx context.Context, userID primitive.ObjectID, name string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"name": name}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// ChangeEmail sets a new email, which needs verifying again. A taken email
+// fails on the unique index.
+func (r *MongoUserRepository) ChangeEmail(ctx context.Context, userID primitive.ObjectID, email string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set":   bson.M{"email": email, "emailVerified": false},
+			"$unset": bson.M{"emailVerifiedAt": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// CloseUser soft-deletes an open account with an empty wallet. It returns
+// mongo.ErrNoDocuments if the balance is not zero or the account is
+// already closed, so a deposit racing the closure cannot be stranded.
+func (r *MongoUserRepository) CloseUser(ctx context.Context, userID primitive.ObjectID, now time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "walletbalance": 0, "closedAt": nil},
+		bson.M{"$set": bson.M{"closedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.InfoContext(ctx, "user closed", "user_id", userID.Hex())
+	return nil
+}
+
+// UseBackupCode removes a backup code's hash. It returns
+// mongo.ErrNoDocuments if the code was already used.
+func (r *MongoUserRepository) UseBackupCode(ctx context.Context, userID primitive.ObjectID, hash string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "twoFactor.backupCodes": hash},
+		bson.M{"$pull": bson.M{"twoFactor.backupCodes": hash}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.InfoContext(ctx, "backup code used", "user_id", userID.Hex())
+	return nil
+}
+
+// SubmitKYC records the user's documents for review. It returns
+// mongo.ErrNoDocuments if they are already pending or verified.
+func (r *MongoUserRepository) SubmitKYC(ctx context.Context, userID primitive.ObjectID, kyc *models.KYC) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "kyc.status": bson.M{"$nin": bson.A{models.KYCPending, models.KYCVerified}}},
+		bson.M{"$set": bson.M{"kyc": kyc}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.InfoContext(ctx, "kyc submitted", "user_id", userID.Hex())
+	return nil
+}
+
+// ReviewKYC records an admin's decision on the user's verification. It
+// returns mongo.ErrNoDocuments unless the current status is one of from.
+func (r *MongoUserRepository) ReviewKYC(ctx context.Context, userID primitive.ObjectID, from []string, status string, tier int, reason string, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	set := bson.M{"kyc.status": status, "kyc.tier": tier, "kyc.reviewedAt": at}
+	update := bson.M{"$set": set}
+	if reason != "" {
+		set["kyc.rejectionReason"] = reason
+	} else {
+		update["$unset"] = bson.M{"kyc.rejectionReason": ""}
 	}
-	defer cursor.Close(context.Background())
 
-	var users []models.User
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "kyc.status": bson.M{"$in": from}},
+		update,
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
 
-	for cursor.Next(context.Background()) {
-		var user models.User
-		if err := cursor.Decode(&user); err != nil {
-			return nil, err
-		}
-		users = append(users, user)
+	r.logger.InfoContext(ctx, "kyc reviewed", "user_id", userID.Hex(), "status", status, "tier", tier)
+	return nil
+}
+
+// ListUsersByKYCStatus returns the open accounts with the given KYC status,
+// earliest submission first
+func (r *MongoUserRepository) ListUsersByKYCStatus(ctx context.Context, status string) ([]models.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"kyc.status": status, "closedAt": nil}
+	if status == models.KYCUnverified {
+		// Users who never submitted have no KYC at all
+		filter["kyc.status"] = bson.M{"$in": bson.A{nil, models.KYCUnverified}}
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "kyc.submittedAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
 	}
 
 	return users, nil
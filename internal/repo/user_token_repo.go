@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoUserTokenRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoUserTokenRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoUserTokenRepository {
+	return &MongoUserTokenRepository{
+		collection: db.Collection("user_tokens"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+func (r *MongoUserTokenRepository) CreateUserToken(ctx context.Context, t *models.UserToken) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	t.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *MongoUserTokenRepository) GetUserToken(ctx context.Context, id primitive.ObjectID) (*models.UserToken, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var t models.UserToken
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&t); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// UseUserToken marks a token used. It returns mongo.ErrNoDocuments if the
+// token was already used, so of two concurrent requests only one succeeds.
+func (r *MongoUserTokenRepository) UseUserToken(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "usedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// UseUserTokens marks every unused token of the user with the purpose used
+func (r *MongoUserTokenRepository) UseUserTokens(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"userId": userID, "purpose": purpose, "usedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": now}},
+	)
+	return err
+}
@@ -2,47 +2,63 @@ package repo
 
 import (
 	"context"
+	"log/slog"
 	"time"
 
-	"concurrent-wallet-order-system/internal/config"
 	"concurrent-wallet-order-system/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type WalletRepository struct{}
+type MongoWalletRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
 
-func NewWalletRepository() *WalletRepository {
-	return &WalletRepository{}
+func NewMongoWalletRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoWalletRepository {
+	return &MongoWalletRepository{
+		collection: db.Collection("wallets"),
+		timeout:    timeout,
+		logger:     logger,
+	}
 }
 
 // InsertTransaction inserts a deposit or withdraw record
-func (r *WalletRepository) InsertTransaction(tx *models.WalletTransaction) error {
-	collection := config.DB.Collection("wallets")
+func (r *MongoWalletRepository) InsertTransaction(ctx context.Context, tx *models.WalletTransaction) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
 	tx.CreatedAt = time.Now()
 
-	_, err := collection.InsertOne(context.Background(), tx)
-	return err
+	_, err := r.collection.InsertOne(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	r.logger.DebugContext(ctx, "wallet transaction recorded", "method", tx.Method, "amount", tx.Amount)
+	return nil
 }
 
 // GetTransactionsByUser fetches wallet history
-func (r *WalletRepository) GetTransactionsByUser(userID primitive.ObjectID) ([]models.WalletTransaction, error) {
-	collection := config.DB.Collection("wallets")
+func (r *MongoWalletRepository) GetTransactionsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.WalletTransaction, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
 
-	cursor, err := collection.Find(
-		context.Background(),
+	cursor, err := r.collection.Find(
+		ctx,
 		bson.M{"userId": userID},
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
 	var transactions []models.WalletTransaction
 
-	for cursor.Next(context.Background()) {
+	for cursor.Next(ctx) {
 		var tx models.WalletTransaction
 		if err := cursor.Decode(&tx); err != nil {
 			return nil, err
@@ -0,0 +1,209 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoWatchlistRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+	logger     *slog.Logger
+}
+
+func NewMongoWatchlistRepository(db *mongo.Database, timeout time.Duration, logger *slog.Logger) *MongoWatchlistRepository {
+	return &MongoWatchlistRepository{
+		collection: db.Collection("watchlists"),
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+// CreateWatchlist inserts a new watchlist
+func (r *MongoWatchlistRepository) CreateWatchlist(ctx context.Context, w *models.Watchlist) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	w.CreatedAt = time.Now()
+	w.UpdatedAt = w.CreatedAt
+
+	result, err := r.collection.InsertOne(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	w.ID = result.InsertedID.(primitive.ObjectID)
+	r.logger.DebugContext(ctx, "watchlist created", "watchlist_id", w.ID.Hex())
+	return nil
+}
+
+// GetWatchlist finds a watchlist by ID
+func (r *MongoWatchlistRepository) GetWatchlist(ctx context.Context, id primitive.ObjectID) (*models.Watchlist, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var w models.Watchlist
+	err := r.collection.FindOne(
+		ctx,
+		bson.M{"_id": id},
+	).Decode(&w)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// GetWatchlistsByUser lists a user's watchlists ordered by name
+func (r *MongoWatchlistRepository) GetWatchlistsByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Watchlist, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"userId": userID},
+		options.Find().SetSort(bson.M{"name": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	watchlists := []models.Watchlist{}
+	if err := cursor.All(ctx, &watchlists); err != nil {
+		return nil, err
+	}
+
+	return watchlists, nil
+}
+
+// CountWatchlistsByUser counts a user's watchlists
+func (r *MongoWatchlistRepository) CountWatchlistsByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, bson.M{"userId": userID})
+}
+
+// UpdateWatchlist replaces the name and symbols of a watchlist
+func (r *MongoWatchlistRepository) UpdateWatchlist(ctx context.Context, id primitive.ObjectID, name string, symbols []string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.update(ctx, id, bson.M{"$set": bson.M{
+		"name":      name,
+		"symbols":   symbols,
+		"updatedAt": time.Now(),
+	}})
+}
+
+// AddSymbol appends a symbol unless it is already present
+func (r *MongoWatchlistRepository) AddSymbol(ctx context.Context, id primitive.ObjectID, symbol string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.update(ctx, id, bson.M{
+		"$addToSet": bson.M{"symbols": symbol},
+		"$set":      bson.M{"updatedAt": time.Now()},
+	})
+}
+
+// RemoveSymbol removes a symbol from a watchlist
+func (r *MongoWatchlistRepository) RemoveSymbol(ctx context.Context, id primitive.ObjectID, symbol string) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.update(ctx, id, bson.M{
+		"$pull": bson.M{"symbols": symbol},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	})
+}
+
+// DeleteWatchlist removes a watchlist
+func (r *MongoWatchlistRepository) DeleteWatchlist(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "watchlist deleted", "watchlist_id", id.Hex())
+	return nil
+}
+
+// GetWatchlistQuotes joins a watchlist's symbols with the stocks collection,
+// keeping the order in which the symbols were added. Symbols whose stock no
+// longer exists are returned with empty quote fields.
+func (r *MongoWatchlistRepository) GetWatchlistQuotes(ctx context.Context, id primitive.ObjectID) ([]models.WatchlistQuote, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"_id": id}},
+		bson.M{"$unwind": bson.M{
+			"path":              "$symbols",
+			"includeArrayIndex": "position",
+		}},
+		bson.M{"$lookup": bson.M{
+			"from":         "stocks",
+			"localField":   "symbols",
+			"foreignField": "symbol",
+			"as":           "stockInfo",
+		}},
+		bson.M{"$unwind": bson.M{
+			"path":                       "$stockInfo",
+			"preserveNullAndEmptyArrays": true,
+		}},
+		bson.M{"$sort": bson.M{"position": 1}},
+		bson.M{"$project": bson.M{
+			"_id":           0,
+			"symbol":        "$symbols",
+			"name":          "$stockInfo.name",
+			"price":         "$stockInfo.price",
+			"previousClose": "$stockInfo.previousClose",
+			"status":        "$stockInfo.status",
+		}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	quotes := []models.WatchlistQuote{}
+	if err := cursor.All(ctx, &quotes); err != nil {
+		return nil, err
+	}
+
+	return quotes, nil
+}
+
+func (r *MongoWatchlistRepository) update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	r.logger.DebugContext(ctx, "watchlist updated", "watchlist_id", id.Hex())
+	return nil
+}
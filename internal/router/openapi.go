@@ -0,0 +1,377 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/config"
+	"concurrent-wallet-order-system/internal/handlers"
+	"concurrent-wallet-order-system/internal/health"
+	"concurrent-wallet-order-system/internal/middleware"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/openapi"
+	"concurrent-wallet-order-system/internal/problem"
+	"concurrent-wallet-order-system/internal/services"
+)
+
+const (
+	adminKeyScheme    = "adminKey"
+	bearerTokenScheme = "bearerToken"
+)
+
+// OpenAPISpec describes every route New registers. A route added to the
+// router without an entry here fails the router tests.
+func OpenAPISpec(cfg config.APIConfig) *openapi.Document {
+	b := openapi.NewBuilder(openapi.Info{
+		Title:       "Concurrent Wallet Order System API",
+		Version:     "1.0.0",
+		Description: "Wallets, stock trading, portfolios, watchlists and price alerts. Errors are RFC 7807 problem documents; branch on their code. Rate-limited requests get 429 with a Retry-After header.",
+	})
+
+	b.ErrorSchema(b.Define("Problem", problem.Details{}))
+	b.SecurityScheme(adminKeyScheme, openapi.SecurityScheme{Type: "apiKey", In: "header", Name: middleware.AdminKeyHeader})
+	b.SecurityScheme(bearerTokenScheme, openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"})
+
+	b.Tag("Users", "Registration, login, profiles and the admin user search")
+	b.Tag("Sessions", "Access token refresh, logout and active sessions")
+	b.Tag("Two-Factor", "TOTP enrolment and backup codes")
+	b.Tag("Account", "Profile, password, closure, email verification and password resets")
+	b.Tag("KYC", "Identity verification and the daily limits of each tier")
+	b.Tag("Wallet", "Deposits, withdrawals and balances")
+	b.Tag("Stocks", "Stock catalogue, prices and lifecycle")
+	b.Tag("Orders", "Market buy and sell orders")
+	b.Tag("Portfolio", "Holdings and their valuation")
+	b.Tag("Watchlists", "Lists of symbols with live quotes")
+	b.Tag("Alerts", "Price alerts and their notifications")
+	b.Tag("Admin", "Bulk operations guarded by the admin API key")
+	b.Tag("Operations", "Health checks, metrics and documentation")
+
+	for _, r := range v1Routes {
+		// Every API route is rate limited
+		r.Errors = append(slices.Clone(r.Errors), http.StatusTooManyRequests)
+
+		v1 := r
+		v1.Path = V1Prefix + r.Path
+		b.Add(v1)
+
+		if cfg.LegacyRoutes {
+			b.Add(legacyRoute(r, cfg.SunsetDate()))
+		}
+	}
+	for _, r := range opsRoutes {
+		b.Add(r)
+	}
+
+	return b.Document()
+}
+
+var (
+	clientErrors    = []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusInternalServerError}
+	lookupErrors    = []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError}
+	changeErrors    = []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError}
+	sessionErrors   = []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError}
+	withdrawErrors  = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError}
+	twoFactorErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError}
+	accountErrors   = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError}
+	limitedErrors   = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError}
+	ownerErrors     = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError}
+	ownerChanges    = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusInternalServerError}
+	adminErrors     = []int{http.StatusUnauthorized, http.StatusUnprocessableEntity, http.StatusInternalServerError, http.StatusServiceUnavailable}
+)
+
+func query(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+// legacyRoute documents the unversioned alias of a v1 route
+func legacyRoute(r openapi.Route, sunset time.Time) openapi.Route {
+	r.Deprecated = true
+	r.Description = strings.TrimSpace(fmt.Sprintf("Deprecated alias of %s %s%s, removed after %s. %s",
+		r.Method, V1Prefix, r.Path, sunset.Format(time.DateOnly), r.Description))
+
+	headers := map[string]openapi.Header{
+		"Deprecation": {Description: "When the route was deprecated, as @<unix time>", Schema: &openapi.Schema{Type: "string"}},
+		"Sunset":      {Description: "When the route will be removed", Schema: &openapi.Schema{Type: "string"}},
+		"Link":        {Description: "The successor route, rel=\"successor-version\"", Schema: &openapi.Schema{Type: "string"}},
+	}
+	for name, h := range r.Headers {
+		headers[name] = h
+	}
+	r.Headers = headers
+
+	return r
+}
+
+// v1Routes are served under V1Prefix, and at the root while legacy routes are enabled
+var v1Routes = []openapi.Route{
+	// Users
+	{Method: http.MethodPost, Path: "/register", Tag: "Users", Summary: "Register a user",
+		Request: handlers.RegisterRequest{}, Status: http.StatusCreated, Response: handlers.UserIDResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError}},
+	{Method: http.MethodPost, Path: "/login", Tag: "Users", Summary: "Check a user's credentials",
+		Description: "Each failed login delays the next attempt on the account, and repeated failures lock the account or client IP for a while " +
+			"(429 login_throttled, account_locked or login_blocked, with Retry-After).",
+		Request: handlers.LoginRequest{}, Response: handlers.LoginResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError}},
+	{Method: http.MethodPost, Path: "/login/2fa", Tag: "Users", Summary: "Complete a login with a second factor",
+		Description: "For users with two-factor authentication, /login answers with twoFactorRequired and a challengeToken instead of tokens. " +
+			"Send it here with a TOTP code or a backup code. Wrong codes count as failed logins.",
+		Request: handlers.TwoFactorLoginRequest{}, Response: handlers.LoginResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError}},
+	{Method: http.MethodPost, Path: "/token/refresh", Tag: "Sessions", Summary: "Exchange a refresh token for new tokens",
+		Description: "Refresh tokens are single use: each call returns a replacement. Presenting one that was already exchanged revokes " +
+			"its whole session (401 refresh_token_reused).",
+		Request: handlers.RefreshRequest{}, Response: handlers.TokenResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError}},
+	{Method: http.MethodPost, Path: "/logout", Tag: "Sessions", Summary: "End the current session",
+		Description: "Revokes the refresh token of the access token's session. The access token itself stays valid until it expires.",
+		Response:    handlers.MessageResponse{}, Errors: sessionErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/logout/all", Tag: "Sessions", Summary: "End every session of the user, on all devices",
+		Response: handlers.LogoutAllResponse{}, Errors: sessionErrors, Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/sessions", Tag: "Sessions", Summary: "List the user's active sessions",
+		Response: []handlers.SessionResponse{}, Errors: sessionErrors, Security: bearerTokenScheme},
+
+	// Account management
+	{Method: http.MethodPatch, Path: "/profile", Tag: "Account", Summary: "Update the caller's name or email",
+		Description: "Only the fields that are set change. A new email needs the current password (403 incorrect_password), " +
+			"is unverified until the user follows the link mailed to it, and voids links sent to the old one.",
+		Request: handlers.UpdateProfileRequest{}, Response: models.User{}, Errors: accountErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/password/change", Tag: "Account", Summary: "Change the caller's password",
+		Description: "Needs the current password; failed attempts count towards the login throttle. Revokes every session of the user.",
+		Request:     handlers.ChangePasswordRequest{}, Response: handlers.MessageResponse{}, Errors: accountErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/account/close", Tag: "Account", Summary: "Close the caller's account",
+		Description: "Needs the current password. Refused while the wallet balance is non-zero (409 balance_not_zero) or any " +
+			"shares are held, frozen ones of delisted stocks included (409 holdings_not_empty). The account is soft-deleted: its orders and wallet history are kept.",
+		Request: handlers.CloseAccountRequest{}, Response: handlers.MessageResponse{}, Errors: accountErrors, Security: bearerTokenScheme},
+
+	// Email verification and password resets
+	{Method: http.MethodPost, Path: "/email/verify/send", Tag: "Account", Summary: "Mail the caller a new verification link",
+		Description: "Registering sends the first link. Until the email is verified, withdrawals are refused with email_not_verified.",
+		Response:    handlers.MessageResponse{}, Errors: []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/email/verify", Tag: "Account", Summary: "Verify an email address",
+		Description: "Takes the token from a verification link. Each link works once, until it expires.",
+		Request:     handlers.EmailTokenRequest{}, Response: handlers.MessageResponse{}, Errors: clientErrors},
+	{Method: http.MethodPost, Path: "/password/forgot", Tag: "Account", Summary: "Mail a password reset link",
+		Description: "Answers the same whether or not the email is registered.",
+		Request:     handlers.ForgotPasswordRequest{}, Status: http.StatusAccepted, Response: handlers.MessageResponse{}, Errors: clientErrors},
+	{Method: http.MethodPost, Path: "/password/reset", Tag: "Account", Summary: "Set a new password with a reset link",
+		Description: "Takes the token from a reset link. Revokes every session of the user and any other reset links.",
+		Request:     handlers.ResetPasswordRequest{}, Response: handlers.MessageResponse{}, Errors: clientErrors},
+
+	// Two-factor authentication
+	{Method: http.MethodGet, Path: "/2fa", Tag: "Two-Factor", Summary: "Get the caller's two-factor status",
+		Response: services.TwoFactorStatus{}, Errors: sessionErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/2fa/setup", Tag: "Two-Factor", Summary: "Start enrolment with a new TOTP secret",
+		Description: "Returns the secret and its otpauth:// URI; show the URI as a QR code for authenticator apps. Enrolment is pending until /2fa/enable.",
+		Response:    handlers.TwoFactorSetupResponse{}, Errors: twoFactorErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/2fa/enable", Tag: "Two-Factor", Summary: "Confirm enrolment with a TOTP code",
+		Description: "Returns ten single-use backup codes. Only their hashes are kept, so they are never shown again.",
+		Request:     handlers.TwoFactorCodeRequest{}, Response: handlers.BackupCodesResponse{}, Errors: twoFactorErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/2fa/disable", Tag: "Two-Factor", Summary: "Turn two-factor authentication off",
+		Request: handlers.TwoFactorCodeRequest{}, Response: handlers.MessageResponse{}, Errors: twoFactorErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/2fa/backup-codes", Tag: "Two-Factor", Summary: "Replace all backup codes",
+		Request: handlers.TwoFactorCodeRequest{}, Response: handlers.BackupCodesResponse{}, Errors: twoFactorErrors, Security: bearerTokenScheme},
+	// KYC
+	{Method: http.MethodGet, Path: "/kyc", Tag: "KYC", Summary: "Get the caller's verification, daily limits and usage today",
+		Response: services.KYCStatus{}, Errors: sessionErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/kyc", Tag: "KYC", Summary: "Submit identity documents for review",
+		Description: "Takes the metadata of up to five documents, at least one of them a passport, national_id or driving_licence; " +
+			"the scans themselves are uploaded to document storage and named by reference. Refused while documents are pending " +
+			"(409 kyc_pending) or once verified (409 kyc_already_verified); a rejected user can submit again.",
+		Request: handlers.KYCSubmitRequest{}, Status: http.StatusAccepted, Response: services.KYCStatus{}, Errors: accountErrors, Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/users", Tag: "Users", Summary: "Search users, newest first",
+		Description: "Needs a user with the admin role (403 admin_required). Lists summaries, without two-factor or identity document details.",
+		Query: []openapi.Parameter{
+			query("name", "string", "Name prefix, case-insensitive"),
+			query("email", "string", "Email prefix, case-insensitive"),
+			query("createdFrom", "string", "Registered at or after this RFC 3339 time or date"),
+			query("createdTo", "string", "Registered before this RFC 3339 time or date"),
+			query("status", "string", "active or closed"),
+			query("page", "integer", "Page number, from 1"),
+			query("limit", "integer", "Page size (default 50, max 200)"),
+		},
+		Response: []handlers.UserSummary{},
+		Headers: map[string]openapi.Header{
+			"X-Total-Count": {Description: "Number of matches across all pages", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusUnprocessableEntity, http.StatusInternalServerError}, Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/users/:userId", Tag: "Users", Summary: "Get a user",
+		Description: "Users can read their own profile; anyone else's needs the admin role (403 admin_required).",
+		Response:    models.User{}, Errors: ownerErrors, Security: bearerTokenScheme},
+
+	// Wallet
+	{Method: http.MethodPost, Path: "/wallet/deposit", Tag: "Wallet", Summary: "Deposit into a wallet",
+		Description: "Counts against the daily deposit limit of the user's KYC tier (403 daily_limit_exceeded).",
+		Request:     handlers.WalletRequest{}, Response: handlers.MessageResponse{}, Errors: limitedErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/wallet/withdraw", Tag: "Wallet", Summary: "Withdraw from a wallet",
+		Description: "Fails with insufficient_balance rather than overdrawing the wallet, and with email_not_verified for users who have not verified their email. " +
+			"Above the step-up amount a user with two-factor authentication must send a TOTP or backup code, " +
+			"and one without it is refused with two_factor_required. Counts against the daily withdrawal limit of the user's KYC tier.",
+		Request: handlers.WithdrawRequest{}, Response: handlers.MessageResponse{}, Errors: withdrawErrors, Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/wallet/balance/:userId", Tag: "Wallet", Summary: "Get a wallet balance",
+		Description: "Users can read their own balance; anyone else's needs the admin role (403 admin_required).",
+		Response:    handlers.BalanceResponse{}, Errors: ownerErrors, Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/wallet/history/:userId", Tag: "Wallet", Summary: "List wallet transactions",
+		Description: "Users can read their own history; anyone else's needs the admin role (403 admin_required).",
+		Response:    []models.WalletTransaction{}, Errors: ownerErrors, Security: bearerTokenScheme},
+
+	// Stocks
+	{Method: http.MethodGet, Path: "/stocks", Tag: "Stocks", Summary: "Search and list stocks",
+		Query: []openapi.Parameter{
+			query("q", "string", "Full-text search on symbol and name"),
+			query("prefix", "string", "Symbol or name prefix; cannot be combined with q"),
+			query("sector", "string", "One or more sectors, comma separated"),
+			query("industry", "string", "Exact industry"),
+			query("exchange", "string", "Exact exchange"),
+			query("minPrice", "number", "Lowest price"),
+			query("maxPrice", "number", "Highest price"),
+			query("sort", "string", "symbol, name, price or marketCap; prefix with - for descending"),
+			query("page", "integer", "Page number, from 1"),
+			query("limit", "integer", "Page size (default 50, max 200)"),
+			query("includeDelisted", "boolean", "Include delisted stocks"),
+		},
+		Response: []models.Stock{},
+		Headers: map[string]openapi.Header{
+			"X-Total-Count": {Description: "Number of matches across all pages", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Errors: clientErrors},
+	{Method: http.MethodGet, Path: "/stocks/:symbol", Tag: "Stocks", Summary: "Get a stock",
+		Response: models.Stock{}, Errors: []int{http.StatusNotFound, http.StatusInternalServerError}},
+
+	// Orders
+	{Method: http.MethodPost, Path: "/orders/buy", Tag: "Orders", Summary: "Buy at the current price",
+		Description: "Needs a verified identity (403 kyc_required). The notional counts against the daily order limit of the user's KYC tier.",
+		Request:     handlers.OrderRequest{}, Status: http.StatusCreated, Response: models.Order{}, Errors: limitedErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/orders/sell", Tag: "Orders", Summary: "Sell at the current price",
+		Description: "Needs a verified identity (403 kyc_required). The notional counts against the daily order limit of the user's KYC tier.",
+		Request:     handlers.OrderRequest{}, Status: http.StatusCreated, Response: models.Order{}, Errors: limitedErrors, Security: bearerTokenScheme},
+
+	// Portfolio
+	{Method: http.MethodGet, Path: "/portfolio/:userId", Tag: "Portfolio", Summary: "Get holdings with their valuation",
+		Description: "Users can read their own portfolio; anyone else's needs the admin role (403 admin_required).",
+		Response:    services.PortfolioResponse{}, Errors: ownerErrors, Security: bearerTokenScheme},
+
+	// Watchlists
+	{Method: http.MethodPost, Path: "/watchlists", Tag: "Watchlists", Summary: "Create a watchlist for the caller",
+		Request: handlers.CreateWatchlistRequest{}, Status: http.StatusCreated, Response: models.Watchlist{},
+		Errors: append([]int{http.StatusUnauthorized}, changeErrors...), Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/watchlists/user/:userId", Tag: "Watchlists", Summary: "List a user's watchlists",
+		Description: "Users can list their own watchlists; anyone else's needs the admin role (403 admin_required).",
+		Response:    []models.Watchlist{}, Errors: ownerErrors, Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/watchlists/:id", Tag: "Watchlists", Summary: "Get a watchlist with live quotes",
+		Description: "Watchlists are their owner's; anyone else needs the admin role (403 admin_required), here and below.",
+		Response:    services.WatchlistResponse{}, Errors: ownerErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPut, Path: "/watchlists/:id", Tag: "Watchlists", Summary: "Rename a watchlist and replace its symbols",
+		Request: handlers.UpdateWatchlistRequest{}, Response: models.Watchlist{}, Errors: ownerChanges, Security: bearerTokenScheme},
+	{Method: http.MethodDelete, Path: "/watchlists/:id", Tag: "Watchlists", Summary: "Delete a watchlist",
+		Response: handlers.MessageResponse{}, Errors: ownerErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/watchlists/:id/symbols", Tag: "Watchlists", Summary: "Add a symbol to a watchlist",
+		Request: handlers.WatchlistSymbolRequest{}, Response: models.Watchlist{}, Errors: ownerChanges, Security: bearerTokenScheme},
+	{Method: http.MethodDelete, Path: "/watchlists/:id/symbols/:symbol", Tag: "Watchlists", Summary: "Remove a symbol from a watchlist",
+		Response: models.Watchlist{}, Errors: ownerErrors, Security: bearerTokenScheme},
+
+	// Alerts
+	{Method: http.MethodPost, Path: "/alerts", Tag: "Alerts", Summary: "Create a price alert for the caller",
+		Request: handlers.CreateAlertRequest{}, Status: http.StatusCreated, Response: models.Alert{},
+		Errors: append([]int{http.StatusUnauthorized}, changeErrors...), Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/alerts/user/:userId", Tag: "Alerts", Summary: "List a user's alerts",
+		Description: "Alerts and notifications are their owner's; anyone else needs the admin role (403 admin_required), here and below.",
+		Response:    []models.Alert{}, Errors: ownerErrors, Security: bearerTokenScheme},
+	{Method: http.MethodDelete, Path: "/alerts/:id", Tag: "Alerts", Summary: "Delete an alert",
+		Response: handlers.MessageResponse{}, Errors: ownerErrors, Security: bearerTokenScheme},
+	{Method: http.MethodGet, Path: "/notifications/:userId", Tag: "Alerts", Summary: "List a user's notifications, newest first",
+		Query:    []openapi.Parameter{query("unread", "boolean", "Only unread notifications")},
+		Response: []models.Notification{}, Errors: ownerErrors, Security: bearerTokenScheme},
+	{Method: http.MethodPost, Path: "/notifications/:id/read", Tag: "Alerts", Summary: "Mark a notification as read",
+		Response: handlers.MessageResponse{}, Errors: ownerErrors, Security: bearerTokenScheme},
+
+	// Admin
+	{Method: http.MethodPost, Path: "/admin/stocks", Tag: "Admin", Summary: "Create a stock",
+		Request: handlers.CreateStockRequest{}, Status: http.StatusCreated, Response: models.Stock{},
+		Errors: append([]int{http.StatusBadRequest, http.StatusConflict}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodPut, Path: "/admin/stocks/:symbol/price", Tag: "Admin", Summary: "Update the market price",
+		Description: "The first update of a UTC day moves the last price into previousClose. Price alerts are evaluated against the new price.",
+		Request:     handlers.StockPriceRequest{}, Response: models.Stock{},
+		Errors: append([]int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodPut, Path: "/admin/stocks/:symbol/status", Tag: "Admin", Summary: "Halt or resume trading",
+		Request: handlers.StockStatusRequest{}, Response: models.Stock{},
+		Errors: append([]int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodPost, Path: "/admin/stocks/:symbol/delist", Tag: "Admin", Summary: "Delist a stock and settle holdings",
+		Description: "Holdings are liquidated into their owners' wallets at the final price (LIQUIDATE) or frozen (FREEZE). " +
+			"A delisting that fails part-way leaves the stock HALTED and can be repeated.",
+		Request: handlers.DelistStockRequest{}, Response: services.DelistResult{},
+		Errors: append([]int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodPost, Path: "/admin/stocks/import", Tag: "Admin", Summary: "Bulk upsert stocks from CSV or JSON",
+		Description:  "The format comes from the format query parameter, falling back to the Content-Type. Invalid rows are reported and skipped.",
+		Query:        []openapi.Parameter{query("format", "string", "csv or json")},
+		Request:      []services.StockImportRow{},
+		RequestTypes: []string{"application/json", "text/csv"},
+		Response:     services.ImportResult{}, Errors: adminErrors, Security: adminKeyScheme},
+	{Method: http.MethodGet, Path: "/admin/stocks/export", Tag: "Admin", Summary: "Export every stock as CSV or JSON",
+		Query:    []openapi.Parameter{query("format", "string", "csv or json (default json)")},
+		Response: []models.Stock{}, Errors: adminErrors, Security: adminKeyScheme},
+	{Method: http.MethodPost, Path: "/admin/users/:userId/unlock", Tag: "Admin", Summary: "Lift a login lockout",
+		Response: handlers.MessageResponse{}, Errors: append([]int{http.StatusBadRequest, http.StatusNotFound}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodPut, Path: "/admin/users/:userId/role", Tag: "Admin", Summary: "Grant or revoke the admin role",
+		Description: "Admins can search users and read any profile with their access token.",
+		Request:     handlers.SetRoleRequest{}, Response: models.User{},
+		Errors: append([]int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodGet, Path: "/admin/security-events", Tag: "Admin", Summary: "Read the security log, newest first",
+		Query: []openapi.Parameter{
+			query("type", "string", "ACCOUNT_LOCKED, ACCOUNT_UNLOCKED or IP_LOCKED"),
+			query("userId", "string", "Events of one user"),
+			query("email", "string", "Events of one email address"),
+			query("limit", "integer", "Number of events (default 100, max 500)"),
+		},
+		Response: []models.SecurityEvent{}, Errors: append([]int{http.StatusBadRequest}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodGet, Path: "/admin/kyc", Tag: "Admin", Summary: "List users by KYC status, earliest submission first",
+		Query: []openapi.Parameter{
+			query("status", "string", "unverified, pending (default, the review queue), verified or rejected"),
+		},
+		Response: []models.User{}, Errors: adminErrors, Security: adminKeyScheme},
+	{Method: http.MethodPost, Path: "/admin/kyc/:userId/approve", Tag: "Admin", Summary: "Verify a user's identity at a tier",
+		Description: "Approves pending documents, or moves a verified user to another tier.",
+		Request:     handlers.KYCApproveRequest{}, Response: services.KYCStatus{},
+		Errors: append([]int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodPost, Path: "/admin/kyc/:userId/reject", Tag: "Admin", Summary: "Reject a user's pending identity documents",
+		Request: handlers.KYCRejectRequest{}, Response: services.KYCStatus{},
+		Errors: append([]int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodGet, Path: "/admin/audit", Tag: "Admin", Summary: "Read the audit log, newest first",
+		Description: "Deposits, withdrawals, orders, stock creations and imports, and role changes, with who made them and snapshots before and after.",
+		Query: []openapi.Parameter{
+			query("actorId", "string", "Changes made by one user"),
+			query("action", "string", "WALLET_DEPOSIT, WALLET_WITHDRAWAL, ORDER_BUY, ORDER_SELL, STOCK_CREATED, STOCKS_IMPORTED or ROLE_CHANGED"),
+			query("targetType", "string", "user, order or stock"),
+			query("targetId", "string", "User ID, order ID or stock symbol"),
+			query("from", "string", "At or after this RFC 3339 time or date"),
+			query("to", "string", "Before this RFC 3339 time or date"),
+			query("page", "integer", "Page number, from 1"),
+			query("limit", "integer", "Page size (default 50, max 500)"),
+		},
+		Response: []models.AuditEntry{},
+		Headers: map[string]openapi.Header{
+			"X-Total-Count": {Description: "Number of matches across all pages", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Errors: append([]int{http.StatusBadRequest}, adminErrors...), Security: adminKeyScheme},
+	{Method: http.MethodGet, Path: "/admin/audit/verify", Tag: "Admin", Summary: "Check the audit log's hash chain",
+		Description: "Walks the whole log and reports the first entry that is missing, out of place or altered. Note the returned head hash: entries deleted from the end can only be noticed against it.",
+		Response:    services.AuditVerification{}, Errors: adminErrors, Security: adminKeyScheme},
+}
+
+// opsRoutes are unversioned
+var opsRoutes = []openapi.Route{
+	{Method: http.MethodGet, Path: "/healthz", Tag: "Operations", Summary: "Liveness",
+		Response: health.Report{}, Errors: []int{http.StatusServiceUnavailable}},
+	{Method: http.MethodGet, Path: "/readyz", Tag: "Operations", Summary: "Readiness, including MongoDB and indexes",
+		Response: health.Report{}, Errors: []int{http.StatusServiceUnavailable}},
+	{Method: http.MethodGet, Path: "/metrics", Tag: "Operations", Summary: "Prometheus metrics",
+		Response: "", ResponseType: "text/plain"},
+	{Method: http.MethodGet, Path: "/openapi.json", Tag: "Operations", Summary: "This OpenAPI document",
+		Response: map[string]any{}},
+	{Method: http.MethodGet, Path: "/docs", Tag: "Operations", Summary: "Swagger UI for this document",
+		Response: "", ResponseType: "text/html"},
+}
@@ -0,0 +1,230 @@
+// Package router builds the HTTP router: middleware, the versioned API and
+// the operational endpoints.
+package router
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"time"
+
+	"concurrent-wallet-order-system/internal/auth"
+	"concurrent-wallet-order-system/internal/config"
+	"concurrent-wallet-order-system/internal/handlers"
+	"concurrent-wallet-order-system/internal/metrics"
+	"concurrent-wallet-order-system/internal/middleware"
+	"concurrent-wallet-order-system/internal/problem"
+	"concurrent-wallet-order-system/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// V1Prefix is the base path of version 1 of the API. A breaking change goes
+// into a new version with its own prefix and registration function, next to
+// registerV1, rather than into v1.
+const V1Prefix = "/api/v1"
+
+// legacyDeprecatedSince is when the unversioned routes were deprecated in
+// favour of V1Prefix
+var legacyDeprecatedSince = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+type Handlers struct {
+	User      *handlers.UserHandler
+	Wallet    *handlers.WalletHandler
+	Stock     *handlers.StockHandler
+	Order     *handlers.OrderHandler
+	Portfolio *handlers.PortfolioHandler
+	Watchlist *handlers.WatchlistHandler
+	Alert     *handlers.AlertHandler
+	Session   *handlers.SessionHandler
+	TwoFactor *handlers.TwoFactorHandler
+	Account   *handlers.AccountHandler
+	KYC       *handlers.KYCHandler
+	Security  *handlers.SecurityHandler
+	Audit     *handlers.AuditHandler
+	Health    *handlers.HealthHandler
+	Docs      *handlers.DocsHandler
+}
+
+// New registers every route. Each one must also be described in
+// OpenAPISpec; router_test.go checks both lists match. Access tokens are
+// verified with signer. A nil store disables rate limiting.
+func New(cfg config.Config, store ratelimit.Store, signer *auth.Signer, logger *slog.Logger, h Handlers) *gin.Engine {
+	// Tracing and request IDs first so the access log and every downstream log line carry them
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	// Client IPs key the rate limits, so only configured proxies may set X-Forwarded-For
+	if err := router.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		// Validate has checked every entry
+		panic(err)
+	}
+	router.NoRoute(func(c *gin.Context) {
+		problem.Abort(c, problem.New(http.StatusNotFound, "route_not_found", "no route matches "+c.Request.URL.Path))
+	})
+	router.NoMethod(func(c *gin.Context) {
+		problem.Abort(c, problem.New(http.StatusMethodNotAllowed, "method_not_allowed", c.Request.Method+" is not allowed on "+c.Request.URL.Path))
+	})
+	router.Use(
+		gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+			logger.ErrorContext(c.Request.Context(), "handler panicked", "panic", recovered, "stack", string(debug.Stack()))
+			problem.Abort(c, problem.New(http.StatusInternalServerError, "internal_error", "the request could not be completed"))
+		}),
+		otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+			// Probes and scrapes would drown out the traces that matter
+			switch r.URL.Path {
+			case "/healthz", "/readyz", "/metrics":
+				return false
+			}
+			return true
+		})),
+		middleware.RequestID(),
+		middleware.AccessLog(logger),
+		metrics.GinMiddleware(),
+	)
+
+	// Health & Metrics Routes
+	router.GET("/healthz", h.Health.Liveness)
+	router.GET("/readyz", h.Health.Readiness)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// API Docs
+	router.GET("/openapi.json", h.Docs.Spec)
+	router.GET("/docs", h.Docs.UI)
+
+	limits := newRouteLimits(cfg.RateLimit, store, logger)
+	authenticate := middleware.Authenticate(signer)
+	registerV1(router.Group(V1Prefix), cfg, authenticate, limits, h)
+
+	// The unversioned routes predate V1Prefix and serve the same handlers,
+	// and share their rate-limit buckets, until their sunset date
+	if cfg.API.LegacyRoutes {
+		registerV1(router.Group("", middleware.Deprecated(legacyDeprecatedSince, cfg.API.SunsetDate(), V1Prefix)), cfg, authenticate, limits, h)
+	}
+
+	return router
+}
+
+// routeLimits holds the rate-limit middleware of each route group; all are
+// empty when rate limiting is disabled
+type routeLimits struct {
+	auth, trading, admin, standard []gin.HandlerFunc
+}
+
+func newRouteLimits(cfg config.RateLimitConfig, store ratelimit.Store, logger *slog.Logger) routeLimits {
+	if store == nil {
+		return routeLimits{}
+	}
+
+	limit := func(name string, p config.RateLimitPolicyConfig) []gin.HandlerFunc {
+		policy := ratelimit.Policy{Name: name}
+		// Validate has checked the rates
+		n, period, _ := config.ParseRate(p.PerIP)
+		policy.PerIP = ratelimit.Limit{Requests: n, Period: period}
+		n, period, _ = config.ParseRate(p.PerUser)
+		policy.PerUser = ratelimit.Limit{Requests: n, Period: period}
+		return []gin.HandlerFunc{middleware.RateLimit(store, policy, logger)}
+	}
+
+	return routeLimits{
+		auth:     limit("auth", cfg.Auth),
+		trading:  limit("trading", cfg.Trading),
+		admin:    limit("admin", cfg.Admin),
+		standard: limit("default", cfg.Default),
+	}
+}
+
+func registerV1(api *gin.RouterGroup, cfg config.Config, authenticate gin.HandlerFunc, limits routeLimits, h Handlers) {
+	// Credential guessing gets the tightest limits, trading the next,
+	// since every order queues on the order lock. The credential routes
+	// skip authentication so that a stale access token cannot block them;
+	// elsewhere it runs first so the per-user limits know the user. Trading
+	// needs a user, so its per-user limit always applies.
+	auth := api.Group("", limits.auth...)
+	trading := api.Group("", slices.Concat([]gin.HandlerFunc{authenticate, middleware.RequireAuth()}, limits.trading)...)
+	admin := api.Group("/admin", slices.Concat(limits.admin, []gin.HandlerFunc{middleware.AdminAuth(cfg.Admin.APIKey)})...)
+	api = api.Group("", slices.Concat([]gin.HandlerFunc{authenticate}, limits.standard)...)
+	// Sessions, two-factor settings and email verification belong to the
+	// caller, so need a user; so do profiles, balances, wallet history,
+	// portfolios, watchlists and alerts, which the services limit to the
+	// caller's own unless they are an admin
+	account := api.Group("", middleware.RequireAuth())
+
+	// User Routes
+	auth.POST("/register", h.User.Register)
+	auth.POST("/login", h.User.Login)
+	auth.POST("/login/2fa", h.User.LoginTwoFactor)
+	auth.POST("/token/refresh", h.Session.Refresh)
+	auth.POST("/email/verify", h.Account.VerifyEmail)
+	auth.POST("/password/forgot", h.Account.ForgotPassword)
+	auth.POST("/password/reset", h.Account.ResetPassword)
+	account.POST("/email/verify/send", h.Account.SendVerification)
+	account.PATCH("/profile", h.Account.UpdateProfile)
+	account.POST("/password/change", h.Account.ChangePassword)
+	account.POST("/account/close", h.Account.Close)
+	account.POST("/logout", h.Session.Logout)
+	account.POST("/logout/all", h.Session.LogoutAll)
+	account.GET("/sessions", h.Session.List)
+
+	// Two-Factor Routes
+	account.GET("/2fa", h.TwoFactor.Status)
+	account.POST("/2fa/setup", h.TwoFactor.Setup)
+	account.POST("/2fa/enable", h.TwoFactor.Enable)
+	account.POST("/2fa/disable", h.TwoFactor.Disable)
+	account.POST("/2fa/backup-codes", h.TwoFactor.RegenerateBackupCodes)
+
+	// KYC Routes
+	account.GET("/kyc", h.KYC.Status)
+	account.POST("/kyc", h.KYC.Submit)
+	account.GET("/users", h.User.GetAllUsers)
+	account.GET("/users/:userId", h.User.GetUser)
+
+	// Wallet Routes
+	trading.POST("/wallet/deposit", h.Wallet.Deposit)
+	trading.POST("/wallet/withdraw", h.Wallet.Withdraw)
+	account.GET("/wallet/balance/:userId", h.Wallet.GetBalance)
+	account.GET("/wallet/history/:userId", h.Wallet.GetHistory)
+
+	// Stock Routes
+	api.GET("/stocks", h.Stock.GetAllStocks)
+	api.GET("/stocks/:symbol", h.Stock.GetStock)
+
+	// Order & Portfolio Routes
+	trading.POST("/orders/buy", h.Order.Buy)
+	trading.POST("/orders/sell", h.Order.Sell)
+	account.GET("/portfolio/:userId", h.Portfolio.GetPortfolio)
+
+	// Watchlist Routes
+	account.POST("/watchlists", h.Watchlist.Create)
+	account.GET("/watchlists/user/:userId", h.Watchlist.GetUserWatchlists)
+	account.GET("/watchlists/:id", h.Watchlist.Get)
+	account.PUT("/watchlists/:id", h.Watchlist.Update)
+	account.DELETE("/watchlists/:id", h.Watchlist.Delete)
+	account.POST("/watchlists/:id/symbols", h.Watchlist.AddSymbol)
+	account.DELETE("/watchlists/:id/symbols/:symbol", h.Watchlist.RemoveSymbol)
+
+	// Alert Routes
+	account.POST("/alerts", h.Alert.Create)
+	account.GET("/alerts/user/:userId", h.Alert.GetUserAlerts)
+	account.DELETE("/alerts/:id", h.Alert.Delete)
+	account.GET("/notifications/:userId", h.Alert.GetNotifications)
+	account.POST("/notifications/:id/read", h.Alert.MarkNotificationRead)
+
+	// Admin Routes
+	admin.POST("/stocks", h.Stock.CreateStock)
+	admin.PUT("/stocks/:symbol/price", h.Stock.UpdatePrice)
+	admin.PUT("/stocks/:symbol/status", h.Stock.SetStatus)
+	admin.POST("/stocks/:symbol/delist", h.Stock.Delist)
+	admin.POST("/stocks/import", h.Stock.Import)
+	admin.GET("/stocks/export", h.Stock.Export)
+	admin.POST("/users/:userId/unlock", h.User.Unlock)
+	admin.PUT("/users/:userId/role", h.User.SetRole)
+	admin.GET("/security-events", h.Security.ListEvents)
+	admin.GET("/kyc", h.KYC.List)
+	admin.POST("/kyc/:userId/approve", h.KYC.Approve)
+	admin.POST("/kyc/:userId/reject", h.KYC.Reject)
+	admin.GET("/audit", h.Audit.List)
+	admin.GET("/audit/verify", h.Audit.Verify)
+}
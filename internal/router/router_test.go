@@ -0,0 +1,225 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/auth"
+	"concurrent-wallet-order-system/internal/config"
+	"concurrent-wallet-order-system/internal/handlers"
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testRouter(cfg config.Config) *gin.Engine {
+	return testRouterWithStore(cfg, nil)
+}
+
+// testSigner issues the access tokens the test routers accept
+var testSigner = auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "test", time.Minute)
+
+func testRouterWithStore(cfg config.Config, store ratelimit.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return New(cfg, store, testSigner, logging.Discard(), Handlers{
+		Docs: handlers.NewDocsHandler(OpenAPISpec(cfg.API)),
+	})
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	for _, legacy := range []bool{true, false} {
+		cfg := config.Default()
+		cfg.API.LegacyRoutes = legacy
+
+		router := testRouter(cfg)
+		documented := OpenAPISpec(cfg.API).Operations()
+
+		var registered []string
+		for _, r := range router.Routes() {
+			op := r.Method + " " + r.Path
+			registered = append(registered, op)
+			if !slices.Contains(documented, op) {
+				t.Errorf("legacy=%v: %s is registered but missing from the OpenAPI spec", legacy, op)
+			}
+		}
+
+		for _, op := range documented {
+			if !slices.Contains(registered, op) {
+				t.Errorf("legacy=%v: %s is in the OpenAPI spec but not registered", legacy, op)
+			}
+		}
+	}
+}
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	cfg := config.Default()
+	cfg.Admin.APIKey = ""
+	router := testRouter(cfg)
+
+	// The admin routes answer without handlers while the admin API is disabled
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/stocks/export", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if w.Header().Get("Deprecation") == "" || w.Header().Get("Sunset") != "Fri, 30 Apr 2027 00:00:00 GMT" {
+		t.Errorf("headers = %v", w.Header())
+	}
+	if got, want := w.Header().Get("Link"), `</api/v1/admin/stocks/export>; rel="successor-version"`; got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, V1Prefix+"/admin/stocks/export", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Deprecation") != "" {
+		t.Errorf("v1 route: status = %d, headers = %v", w.Code, w.Header())
+	}
+
+	cfg.API.LegacyRoutes = false
+	w = httptest.NewRecorder()
+	testRouter(cfg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/stocks/export", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("legacy route disabled: status = %d, want 404", w.Code)
+	}
+}
+
+func TestServesSpec(t *testing.T) {
+	router := testRouter(config.Default())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Deprecated bool `json:"deprecated"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.OpenAPI == "" {
+		t.Errorf("spec = %s", w.Body.String())
+	}
+	if op, ok := doc.Paths["/api/v1/portfolio/{userId}"]["get"]; !ok || op.Deprecated {
+		t.Errorf("v1 portfolio route missing or deprecated")
+	}
+	if op, ok := doc.Paths["/portfolio/{userId}"]["get"]; !ok || !op.Deprecated {
+		t.Errorf("legacy portfolio route missing or not deprecated")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("docs status = %d", w.Code)
+	}
+}
+
+func TestRateLimitGroups(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimit.Auth.PerIP = "2/1m"
+	router := testRouterWithStore(cfg, ratelimit.NewMemoryStore())
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// Legacy and v1 routes draw from the same bucket. Without handlers the
+	// requests that get through fail, which is all this test needs.
+	send(http.MethodPost, "/login")
+	send(http.MethodPost, V1Prefix+"/register")
+
+	w := send(http.MethodPost, V1Prefix+"/login")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("third auth request: status = %d, Retry-After = %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// Other groups have their own buckets, and the docs are not limited
+	if w := send(http.MethodPost, V1Prefix+"/orders/buy"); w.Code == http.StatusTooManyRequests {
+		t.Errorf("trading route limited by the auth policy")
+	}
+	if w := send(http.MethodGet, "/openapi.json"); w.Code != http.StatusOK {
+		t.Errorf("docs status = %d", w.Code)
+	}
+}
+
+func TestAccountAndTradingRoutesRequireAuth(t *testing.T) {
+	router := testRouter(config.Default())
+
+	// Withdrawals take the user from the token, so a wrong step-up code can
+	// only count against the caller's own login lockout
+	paths := []string{V1Prefix + "/logout", V1Prefix + "/logout/all", "/logout", V1Prefix + "/wallet/withdraw", "/wallet/withdraw", V1Prefix + "/wallet/deposit"}
+	for _, path := range paths {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: status = %d, WWW-Authenticate = %q", path, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// Balances, wallet history, portfolios, watchlists and alerts are only the
+	// owner's or an admin's
+	userID := "64b7f0c2a1b2c3d4e5f60718"
+	reads := []string{V1Prefix + "/wallet/balance/" + userID, "/wallet/history/" + userID, V1Prefix + "/portfolio/" + userID,
+		V1Prefix + "/watchlists/user/" + userID, V1Prefix + "/watchlists/" + userID, V1Prefix + "/alerts/user/" + userID, "/notifications/" + userID}
+	for _, path := range reads {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", path, w.Code)
+		}
+	}
+
+	// A bad token is rejected even where none is required
+	req := httptest.NewRequest(http.MethodGet, V1Prefix+"/stocks", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("bad token: status = %d, want 401", w.Code)
+	}
+}
+
+func TestTradingRoutesLimitPerUser(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimit.Trading.PerUser = "1/1m"
+	router := testRouterWithStore(cfg, ratelimit.NewMemoryStore())
+
+	send := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, V1Prefix+"/orders/buy", nil)
+		if userID != "" {
+			token, _, err := testSigner.Issue(userID, "session-"+userID, time.Now())
+			if err != nil {
+				t.Fatalf("issue token: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without a token nothing gets as far as the limits
+	if w := send(""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("no token: status = %d, want 401", w.Code)
+	}
+
+	// Without handlers the first request fails, which is all this test needs
+	send("user-1")
+	if w := send("user-1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second order of user-1: status = %d, want 429", w.Code)
+	}
+	if w := send("user-2"); w.Code == http.StatusTooManyRequests {
+		t.Errorf("user-2 limited by user-1's bucket")
+	}
+}
@@ -0,0 +1,471 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/auth"
+	"concurrent-wallet-order-system/internal/mail"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountService lets users manage their own account: profile, password
+// and closure, and the flows that go through their inbox, verifying their
+// email address and resetting a forgotten password. Those mail a link with a
+// signed token that expires and works only once.
+type AccountService struct {
+	userRepo      repo.UserRepository
+	tokens        repo.UserTokenRepository
+	portfolioRepo repo.PortfolioRepository
+	sessions      *SessionService
+	security      *SecurityService
+	wallet        *WalletService
+	orders        *OrderService
+	sender        mail.Sender
+	verify        *auth.Signer
+	reset         *auth.Signer
+	linkBaseURL   string
+	now           func() time.Time
+	logger        *slog.Logger
+}
+
+// NewAccountService takes a signer for each kind of token, with issuers of
+// their own so that neither passes for the other or for an access token.
+// linkBaseURL is where the frontend serves the pages the links open.
+func NewAccountService(
+	userRepo repo.UserRepository,
+	tokens repo.UserTokenRepository,
+	portfolioRepo repo.PortfolioRepository,
+	sessions *SessionService,
+	security *SecurityService,
+	wallet *WalletService,
+	orders *OrderService,
+	sender mail.Sender,
+	verify, reset *auth.Signer,
+	linkBaseURL string,
+	logger *slog.Logger,
+) *AccountService {
+	return &AccountService{
+		userRepo:      userRepo,
+		tokens:        tokens,
+		portfolioRepo: portfolioRepo,
+		sessions:      sessions,
+		security:      security,
+		wallet:        wallet,
+		orders:        orders,
+		sender:        sender,
+		verify:        verify,
+		reset:         reset,
+		linkBaseURL:   strings.TrimSuffix(linkBaseURL, "/"),
+		now:           time.Now,
+		logger:        logger,
+	}
+}
+
+// SendVerification mails the user a link to verify their email address
+func (s *AccountService) SendVerification(ctx context.Context, userID primitive.ObjectID) (err error) {
+	ctx, span := startSpan(ctx, "AccountService.SendVerification")
+	defer endSpan(span, &err)
+
+	user, err := s.openUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	return s.sendVerification(ctx, user)
+}
+
+func (s *AccountService) sendVerification(ctx context.Context, user *models.User) error {
+	link, expires, err := s.link(ctx, user, models.UserTokenVerifyEmail, s.verify, "/verify-email")
+	if err != nil {
+		return err
+	}
+
+	return s.send(ctx, user, "Verify your email address", fmt.Sprintf(
+		"Hi %s,\n\nplease confirm that this is your email address by opening this link:\n\n%s\n\nThe link expires at %s.",
+		user.Name, link, expires.UTC().Format("2006-01-02 15:04 MST"),
+	))
+}
+
+// VerifyEmail marks the address a verification link was sent to as verified
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) (err error) {
+	ctx, span := startSpan(ctx, "AccountService.VerifyEmail")
+	defer endSpan(span, &err)
+
+	record, err := s.consume(ctx, token, models.UserTokenVerifyEmail, s.verify)
+	if errors.Is(err, errUnusableToken) {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	// The user may have changed their address since the link was sent
+	err = s.userRepo.MarkEmailVerified(ctx, record.UserID, record.Email, s.now())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "email verified", "user_id", record.UserID.Hex())
+	return nil
+}
+
+// RequestPasswordReset mails a password reset link to the user with the
+// email, if there is one. Whether there is is not revealed to the caller.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) (err error) {
+	ctx, span := startSpan(ctx, "AccountService.RequestPasswordReset")
+	defer endSpan(span, &err)
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && user.Closed()) {
+		s.logger.InfoContext(ctx, "password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link, expires, err := s.link(ctx, user, models.UserTokenResetPassword, s.reset, "/reset-password")
+	if err != nil {
+		return err
+	}
+
+	err = s.send(ctx, user, "Reset your password", fmt.Sprintf(
+		"Hi %s,\n\nsomeone asked to reset the password of your account. To choose a new one, open this link:\n\n%s\n\n"+
+			"The link expires at %s. If it was not you, ignore this email; your password stays the same.",
+		user.Name, link, expires.UTC().Format("2006-01-02 15:04 MST"),
+	))
+	if err != nil {
+		// Failing the request would tell the caller the account exists
+		s.logger.ErrorContext(ctx, "sending password reset email failed", "user_id", user.ID.Hex(), "error", err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a token from a reset link. Every
+// session of the user is revoked, and any other reset links stop working.
+func (s *AccountService) ResetPassword(ctx context.Context, token, password string) (err error) {
+	ctx, span := startSpan(ctx, "AccountService.ResetPassword")
+	defer endSpan(span, &err)
+
+	record, err := s.consume(ctx, token, models.UserTokenResetPassword, s.reset)
+	if errors.Is(err, errUnusableToken) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, record.UserID)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && (user.Email != record.Email || user.Closed())) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, string(hash)); err != nil {
+		return err
+	}
+
+	// The password has changed, so finish the job even if the client goes away
+	ctx = context.WithoutCancel(ctx)
+
+	now := s.now()
+	if err := s.tokens.UseUserTokens(ctx, user.ID, models.UserTokenResetPassword, now); err != nil {
+		return err
+	}
+	if _, err := s.sessions.revokeAll(ctx, user.ID, models.SessionRevokedPassword); err != nil {
+		return err
+	}
+	// Whoever reset the password controls the inbox, so the lockout of
+	// someone guessing the old one should not keep them out
+	if err := s.security.LoginSucceeded(ctx, user.Email); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "password reset", "user_id", user.ID.Hex())
+	s.security.record(ctx, &models.SecurityEvent{Type: models.SecurityEventPasswordReset, UserID: &user.ID, Email: strings.ToLower(user.Email)})
+	return nil
+}
+
+// UpdateProfile changes the user's name and email; nil leaves one as it is.
+// A new email needs the current password, is unverified until the user
+// follows the link mailed to it, and voids links sent to the old one.
+func (s *AccountService) UpdateProfile(ctx context.Context, userID primitive.ObjectID, name, email *string, password, ip string) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "AccountService.UpdateProfile")
+	defer endSpan(span, &err)
+
+	user, err := s.openUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != nil {
+		if strings.TrimSpace(*name) == "" {
+			return nil, invalid("name", "name cannot be empty")
+		}
+		if err := s.userRepo.UpdateName(ctx, userID, strings.TrimSpace(*name)); err != nil {
+			return nil, err
+		}
+	}
+
+	if email != nil && strings.TrimSpace(*email) != user.Email {
+		newEmail := strings.TrimSpace(*email)
+		if newEmail == "" {
+			return nil, invalid("email", "email cannot be empty")
+		}
+		if err := s.checkPassword(ctx, user, password, ip); err != nil {
+			return nil, err
+		}
+
+		err := s.userRepo.ChangeEmail(ctx, userID, newEmail)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrEmailTaken
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.logger.InfoContext(ctx, "email changed", "user_id", userID.Hex())
+		s.security.record(ctx, &models.SecurityEvent{
+			Type:   models.SecurityEventEmailChanged,
+			UserID: &user.ID,
+			Email:  strings.ToLower(newEmail),
+			IP:     ip,
+			Detail: "changed from " + user.Email,
+		})
+
+		user.Email = newEmail
+		if err := s.sendVerification(ctx, user); err != nil {
+			s.logger.WarnContext(ctx, "sending verification email failed", "user_id", userID.Hex(), "error", err)
+		}
+	}
+
+	return s.openUser(ctx, userID)
+}
+
+// ChangePassword sets a new password after checking the current one, and
+// revokes every session of the user, so they log in again
+func (s *AccountService) ChangePassword(ctx context.Context, userID primitive.ObjectID, current, password, ip string) (err error) {
+	ctx, span := startSpan(ctx, "AccountService.ChangePassword")
+	defer endSpan(span, &err)
+
+	user, err := s.openUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPassword(ctx, user, current, ip); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hash)); err != nil {
+		return err
+	}
+
+	// The password has changed, so finish the job even if the client goes away
+	ctx = context.WithoutCancel(ctx)
+
+	if _, err := s.sessions.revokeAll(ctx, userID, models.SessionRevokedChanged); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "password changed", "user_id", userID.Hex())
+	s.security.record(ctx, &models.SecurityEvent{Type: models.SecurityEventPasswordChanged, UserID: &user.ID, Email: strings.ToLower(user.Email), IP: ip})
+	return nil
+}
+
+// Close soft-deletes the user's account once they confirm their password.
+// The wallet must be empty and hold no shares, frozen ones included; the
+// user, their orders and their wallet history are kept. Every session is
+// revoked.
+func (s *AccountService) Close(ctx context.Context, userID primitive.ObjectID, password, ip string) (err error) {
+	ctx, span := startSpan(ctx, "AccountService.Close")
+	defer endSpan(span, &err)
+
+	user, err := s.openUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPassword(ctx, user, password, ip); err != nil {
+		return err
+	}
+
+	// Orders take these locks in this order; holding both keeps a trade or
+	// deposit from landing between the checks and the closure
+	s.orders.lock(ctx)
+	defer s.orders.mu.Unlock()
+	s.wallet.lock(ctx)
+	defer s.wallet.mu.Unlock()
+
+	user, err = s.openUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.WalletBalance != 0 {
+		return ErrBalanceNotZero
+	}
+
+	holdings, err := s.portfolioRepo.GetUserPortfolio(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, h := range holdings {
+		// Frozen holdings of delisted stocks cannot be sold, but the shares
+		// are still the user's
+		if h.Qty > 0 && h.Frozen {
+			return withDetail(ErrHoldingsNotEmpty, "frozen holding of "+h.Symbol)
+		}
+		// Settling shares of a delisting are still to be paid for
+		if h.Qty > 0 || h.Settling > 0 {
+			return ErrHoldingsNotEmpty
+		}
+	}
+
+	err = s.userRepo.CloseUser(ctx, userID, s.now())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrBalanceNotZero
+	}
+	if err != nil {
+		return err
+	}
+
+	// The account is closed, so finish the job even if the client goes away
+	ctx = context.WithoutCancel(ctx)
+
+	if _, err := s.sessions.revokeAll(ctx, userID, models.SessionRevokedClosed); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "account closed", "user_id", userID.Hex())
+	s.security.record(ctx, &models.SecurityEvent{Type: models.SecurityEventAccountClosed, UserID: &user.ID, Email: strings.ToLower(user.Email), IP: ip})
+	return nil
+}
+
+// openUser returns the user, unless their account is closed
+func (s *AccountService) openUser(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	if user.Closed() {
+		return nil, ErrAccountClosed
+	}
+	return user, nil
+}
+
+// checkPassword confirms the user's current password before a sensitive
+// change. Attempts go through the login throttle of the account and failures
+// count towards it, so a stolen access token cannot be used to guess it.
+func (s *AccountService) checkPassword(ctx context.Context, user *models.User, password, ip string) error {
+	if err := s.security.CheckLogin(ctx, user.Email, ip); err != nil {
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		s.logger.WarnContext(ctx, "incorrect current password", "user_id", user.ID.Hex())
+		if err := s.security.LoginFailed(ctx, user.Email, ip, &user.ID); err != nil {
+			return err
+		}
+		return ErrIncorrectPassword
+	}
+
+	return nil
+}
+
+// errUnusableToken is returned by consume for any token that cannot be
+// used; callers turn it into the error of their flow
+var errUnusableToken = errors.New("unusable user token")
+
+// link records a new token for the user's current email and returns a link
+// to the frontend page at path carrying it, and when it expires
+func (s *AccountService) link(ctx context.Context, user *models.User, purpose string, signer *auth.Signer, path string) (string, time.Time, error) {
+	now := s.now()
+	record := &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(signer.TTL()),
+	}
+	if err := s.tokens.CreateUserToken(ctx, record); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, expires, err := signer.IssueWithID(user.ID.Hex(), record.ID.Hex(), now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return s.linkBaseURL + path + "?token=" + url.QueryEscape(token), expires, nil
+}
+
+// consume checks a token's signature and expiry against signer and marks
+// its record used
+func (s *AccountService) consume(ctx context.Context, token, purpose string, signer *auth.Signer) (*models.UserToken, error) {
+	now := s.now()
+
+	claims, err := signer.Verify(token, now)
+	if err != nil {
+		return nil, errUnusableToken
+	}
+	id, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return nil, errUnusableToken
+	}
+
+	record, err := s.tokens.GetUserToken(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errUnusableToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Purpose != purpose || record.UserID.Hex() != claims.Subject {
+		return nil, errUnusableToken
+	}
+
+	err = s.tokens.UseUserToken(ctx, id, now)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errUnusableToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (s *AccountService) send(ctx context.Context, user *models.User, subject, body string) error {
+	err := s.sender.Send(ctx, &mail.Message{To: user.Email, Subject: subject, Body: body})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "account email sent", "user_id", user.ID.Hex(), "subject", subject)
+	return nil
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+)
+
+func TestEmailVerification(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	env.accountService.now = clk.now
+
+	if err := env.walletService.Deposit(t.Context(), user.ID, 100); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if err := env.walletService.Withdraw(t.Context(), user.ID, 10, ""); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("withdraw before verifying: err = %v, want ErrEmailNotVerified", err)
+	}
+
+	if err := env.accountService.SendVerification(t.Context(), user.ID); err != nil {
+		t.Fatalf("send verification: %v", err)
+	}
+	token := env.outbox.lastToken(t, user.Email)
+
+	if err := env.accountService.VerifyEmail(t.Context(), token+"x"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("tampered token: err = %v, want ErrInvalidVerificationToken", err)
+	}
+	if err := env.accountService.VerifyEmail(t.Context(), token); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := env.accountService.VerifyEmail(t.Context(), token); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("reused token: err = %v, want ErrInvalidVerificationToken", err)
+	}
+
+	if err := env.walletService.Withdraw(t.Context(), user.ID, 10, ""); err != nil {
+		t.Errorf("withdraw after verifying: %v", err)
+	}
+	if err := env.accountService.SendVerification(t.Context(), user.ID); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Errorf("send again: err = %v, want ErrEmailAlreadyVerified", err)
+	}
+}
+
+func TestVerificationLinkExpires(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	env.accountService.now = clk.now
+
+	if err := env.accountService.SendVerification(t.Context(), user.ID); err != nil {
+		t.Fatalf("send verification: %v", err)
+	}
+	token := env.outbox.lastToken(t, user.Email)
+
+	clk.advance(49 * time.Hour)
+	if err := env.accountService.VerifyEmail(t.Context(), token); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("expired token: err = %v, want ErrInvalidVerificationToken", err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	env.accountService.now = clk.now
+	session, err := env.sessionService.Start(t.Context(), user.ID, "test", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	if err := env.accountService.RequestPasswordReset(t.Context(), "nobody@example.com"); err != nil {
+		t.Fatalf("reset for unknown email: %v", err)
+	}
+	if len(env.outbox.messages) != 0 {
+		t.Fatalf("mail sent for an unknown email")
+	}
+
+	env.accountService.RequestPasswordReset(t.Context(), user.Email)
+	first := env.outbox.lastToken(t, user.Email)
+	env.accountService.RequestPasswordReset(t.Context(), user.Email)
+	second := env.outbox.lastToken(t, user.Email)
+
+	// A verification link is not a reset link
+	env.accountService.SendVerification(t.Context(), user.ID)
+	verify := env.outbox.lastToken(t, user.Email)
+	if err := env.accountService.ResetPassword(t.Context(), verify, "new-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("verification token: err = %v, want ErrInvalidResetToken", err)
+	}
+
+	if err := env.accountService.ResetPassword(t.Context(), first, "new-password"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := env.accountService.ResetPassword(t.Context(), second, "other-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("other link after reset: err = %v, want ErrInvalidResetToken", err)
+	}
+
+	if _, err := env.sessionService.Refresh(t.Context(), session.RefreshToken, "test", "10.0.0.1"); err == nil {
+		t.Error("session survived the password reset")
+	}
+	if _, err := env.userService.Login(t.Context(), user.Email, "new-password", "10.0.0.1"); err != nil {
+		t.Errorf("login with new password: %v", err)
+	}
+	if _, err := env.userService.Login(t.Context(), user.Email, "correct-horse", "10.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("login with old password: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	env.accountService.now = clk.now
+	if err := env.users.MarkEmailVerified(t.Context(), user.ID, user.Email, clk.now()); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	taken := env.createUser(t, 0)
+	other, _ := env.users.GetUserByID(t.Context(), taken)
+
+	name, blank := "Alice Smith", " "
+	got, err := env.accountService.UpdateProfile(t.Context(), user.ID, &name, nil, "", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("update name: %v", err)
+	}
+	if got.Name != name || !got.EmailVerified {
+		t.Errorf("got name %q, verified %v; want %q, true", got.Name, got.EmailVerified, name)
+	}
+	if _, err := env.accountService.UpdateProfile(t.Context(), user.ID, &blank, nil, "", "10.0.0.1"); !errors.As(err, new(*ValidationError)) {
+		t.Errorf("blank name: err = %v, want a ValidationError", err)
+	}
+
+	email := "alice@example.org"
+	if _, err := env.accountService.UpdateProfile(t.Context(), user.ID, nil, &email, "wrong", "10.0.0.1"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("email without password: err = %v, want ErrIncorrectPassword", err)
+	}
+	clk.advance(testLoginPolicy.BaseDelay)
+	if _, err := env.accountService.UpdateProfile(t.Context(), user.ID, nil, &other.Email, "correct-horse", "10.0.0.1"); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("taken email: err = %v, want ErrEmailTaken", err)
+	}
+
+	got, err = env.accountService.UpdateProfile(t.Context(), user.ID, nil, &email, "correct-horse", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("update email: %v", err)
+	}
+	if got.Email != email || got.EmailVerified {
+		t.Errorf("got email %q, verified %v; want %q, false", got.Email, got.EmailVerified, email)
+	}
+	if err := env.accountService.VerifyEmail(t.Context(), env.outbox.lastToken(t, email)); err != nil {
+		t.Errorf("verify new email: %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	session, err := env.sessionService.Start(t.Context(), user.ID, "test", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	if err := env.accountService.ChangePassword(t.Context(), user.ID, "wrong", "new-password", "10.0.0.1"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("wrong current password: err = %v, want ErrIncorrectPassword", err)
+	}
+	clk.advance(testLoginPolicy.BaseDelay)
+
+	if err := env.accountService.ChangePassword(t.Context(), user.ID, "correct-horse", "new-password", "10.0.0.1"); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	if _, err := env.sessionService.Refresh(t.Context(), session.RefreshToken, "test", "10.0.0.1"); err == nil {
+		t.Error("session survived the password change")
+	}
+	if _, err := env.userService.Login(t.Context(), user.Email, "new-password", "10.0.0.1"); err != nil {
+		t.Errorf("login with new password: %v", err)
+	}
+}
+
+func TestCloseAccount(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	if err := env.users.MarkEmailVerified(t.Context(), user.ID, user.Email, clk.now()); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	env.verifyIdentity(t, user.ID, models.KYCTierBasic)
+	env.createStock(t, "ACME", 100)
+	if err := env.walletService.Deposit(t.Context(), user.ID, 100); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if _, err := env.orderService.Buy(t.Context(), user.ID, "ACME", 1); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	if err := env.accountService.Close(t.Context(), user.ID, "correct-horse", "10.0.0.1"); !errors.Is(err, ErrHoldingsNotEmpty) {
+		t.Fatalf("close with holdings: err = %v, want ErrHoldingsNotEmpty", err)
+	}
+	if _, err := env.orderService.Sell(t.Context(), user.ID, "ACME", 1); err != nil {
+		t.Fatalf("sell: %v", err)
+	}
+	if err := env.accountService.Close(t.Context(), user.ID, "correct-horse", "10.0.0.1"); !errors.Is(err, ErrBalanceNotZero) {
+		t.Fatalf("close with balance: err = %v, want ErrBalanceNotZero", err)
+	}
+	if err := env.walletService.Withdraw(t.Context(), user.ID, 100, ""); err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+
+	if err := env.accountService.Close(t.Context(), user.ID, "correct-horse", "10.0.0.1"); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if _, err := env.userService.Login(t.Context(), user.Email, "correct-horse", "10.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("login after closing: err = %v, want ErrInvalidCredentials", err)
+	}
+	if err := env.walletService.Deposit(t.Context(), user.ID, 10); !errors.Is(err, ErrAccountClosed) {
+		t.Errorf("deposit after closing: err = %v, want ErrAccountClosed", err)
+	}
+	users, _, err := env.userService.SearchUsers(t.Context(), env.newAdmin(t), repo.UserFilter{Status: models.UserStatusActive})
+	if err != nil {
+		t.Fatalf("get users: %v", err)
+	}
+	for _, u := range users {
+		if u.ID == user.ID {
+			t.Error("closed account is listed")
+		}
+	}
+
+	// The history is kept
+	history, err := env.walletService.GetHistory(t.Context(), user.ID, user.ID)
+	if err != nil || len(history) == 0 {
+		t.Errorf("history after closing: %d transactions, err = %v", len(history), err)
+	}
+}
+
+func TestCloseAccountRefusedWithFrozenHoldings(t *testing.T) {
+	env, _, user := newLoginEnv(t)
+	env.createStock(t, "OLD", 10)
+	if err := env.portfolio.UpsertPortfolio(t.Context(), user.ID, "OLD", 3); err != nil {
+		t.Fatalf("add holding: %v", err)
+	}
+	if _, err := env.orderService.DelistStock(t.Context(), "OLD", 5, DelistModeFreeze); err != nil {
+		t.Fatalf("delist: %v", err)
+	}
+
+	if err := env.accountService.Close(t.Context(), user.ID, "correct-horse", "10.0.0.1"); !errors.Is(err, ErrHoldingsNotEmpty) {
+		t.Fatalf("close with a frozen holding: err = %v, want ErrHoldingsNotEmpty", err)
+	}
+	if u, err := env.users.GetUserByID(t.Context(), user.ID); err != nil || u.Closed() {
+		t.Errorf("account closed despite the frozen holding (err = %v)", err)
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"concurrent-wallet-order-system/internal/metrics"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/notify"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AlertEvaluator checks price alerts in the background. StockService feeds it
+// every price change through Enqueue. A matching alert is claimed by moving it
+// to TRIGGERED, so only one evaluation notifies; if the notification fails the
+// claim is released, and the next price change that hits the alert tries
+// again. Notifiers may therefore see an alert more than once and keep only
+// one notification per alert.
+type AlertEvaluator struct {
+	alertRepo repo.AlertRepository
+	userRepo  repo.UserRepository
+	notifier  notify.Notifier
+	logger    *slog.Logger
+
+	events chan models.Stock
+	done   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	running      atomic.Bool
+	processed    atomic.Int64
+	failures     atomic.Int64
+	lastActivity atomic.Int64 // unix nanoseconds, 0 before the first evaluation
+}
+
+func NewAlertEvaluator(
+	alertRepo repo.AlertRepository,
+	userRepo repo.UserRepository,
+	notifier notify.Notifier,
+	logger *slog.Logger,
+) *AlertEvaluator {
+	return &AlertEvaluator{
+		alertRepo: alertRepo,
+		userRepo:  userRepo,
+		notifier:  notifier,
+		logger:    logger,
+		events:    make(chan models.Stock, 256),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start launches the evaluation loop
+func (e *AlertEvaluator) Start() {
+	e.running.Store(true)
+	e.wg.Add(1)
+	go e.run()
+}
+
+// Stop finishes the price changes already queued and waits for the loop to exit
+func (e *AlertEvaluator) Stop() {
+	e.once.Do(func() { close(e.done) })
+	e.wg.Wait()
+}
+
+// Enqueue queues a price change for evaluation without holding up the request
+// that made it. A change that finds the queue full or the evaluator stopped is
+// dropped; its alerts are checked again on the symbol's next price change.
+func (e *AlertEvaluator) Enqueue(stock models.Stock) {
+	select {
+	case <-e.done:
+		e.logger.Warn("alert evaluator stopped, dropping price change", "symbol", stock.Symbol)
+		return
+	default:
+	}
+
+	select {
+	case e.events <- stock:
+	default:
+		metrics.AlertPriceChangesDropped.Inc()
+		e.logger.Warn("alert queue full, dropping price change", "symbol", stock.Symbol, "price", stock.Price)
+	}
+}
+
+// Status reports whether the loop is running and how far it has got
+func (e *AlertEvaluator) Status() WorkerStatus {
+	status := WorkerStatus{
+		Running:   e.running.Load(),
+		Queued:    len(e.events),
+		Processed: e.processed.Load(),
+		Failures:  e.failures.Load(),
+	}
+
+	if last := e.lastActivity.Load(); last != 0 {
+		at := time.Unix(0, last)
+		status.LastActivityAt = &at
+	}
+
+	return status
+}
+
+func (e *AlertEvaluator) run() {
+	defer e.wg.Done()
+	defer e.running.Store(false)
+
+	// Evaluation is detached from the requests that changed the prices; the
+	// repositories still bound each operation with their own timeout.
+	ctx := context.Background()
+
+	for {
+		select {
+		case stock := <-e.events:
+			e.evaluate(ctx, stock)
+		case <-e.done:
+			// Drain what was queued before the stop
+			for {
+				select {
+				case stock := <-e.events:
+					e.evaluate(ctx, stock)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *AlertEvaluator) evaluate(ctx context.Context, stock models.Stock) {
+	ctx, span := startSpan(ctx, "AlertEvaluator.evaluate",
+		attribute.String("stock.symbol", stock.Symbol),
+		attribute.Float64("stock.price", stock.Price),
+	)
+	defer span.End()
+
+	defer func() {
+		e.processed.Add(1)
+		e.lastActivity.Store(time.Now().UnixNano())
+	}()
+
+	alerts, err := e.alertRepo.GetActiveAlertsBySymbol(ctx, stock.Symbol)
+	if err != nil {
+		e.logger.ErrorContext(ctx, "failed to load alerts", "symbol", stock.Symbol, "error", err)
+		span.RecordError(err)
+		e.failures.Add(1)
+		return
+	}
+
+	for _, alert := range alerts {
+		message, hit := alertHit(alert, stock)
+		if !hit {
+			continue
+		}
+
+		now := time.Now()
+
+		// Only the caller that flips ACTIVE -> TRIGGERED delivers the notification
+		claimed, err := e.alertRepo.MarkTriggered(ctx, alert.ID, stock.Price, now)
+		if err != nil {
+			e.logger.ErrorContext(ctx, "failed to trigger alert", "alert_id", alert.ID.Hex(), "error", err)
+			span.RecordError(err)
+			e.failures.Add(1)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		user, err := e.userRepo.GetUserByID(ctx, alert.UserID)
+		if err != nil {
+			e.logger.ErrorContext(ctx, "failed to load user for alert", "alert_id", alert.ID.Hex(), "error", err)
+			span.RecordError(err)
+			e.failures.Add(1)
+			e.release(ctx, alert)
+			continue
+		}
+
+		notification := &models.Notification{
+			UserID:    alert.UserID,
+			AlertID:   alert.ID,
+			Symbol:    stock.Symbol,
+			Price:     stock.Price,
+			Message:   message,
+			CreatedAt: now,
+		}
+
+		e.logger.InfoContext(ctx, "alert triggered",
+			"alert_id", alert.ID.Hex(), "user_id", alert.UserID.Hex(), "symbol", stock.Symbol, "price", stock.Price)
+
+		if err := e.notifier.Notify(ctx, user, notification); err != nil {
+			e.logger.ErrorContext(ctx, "failed to deliver notification", "alert_id", alert.ID.Hex(), "error", err)
+			span.RecordError(err)
+			e.failures.Add(1)
+			e.release(ctx, alert)
+		}
+	}
+}
+
+// release puts back an alert claimed for a notification that failed
+func (e *AlertEvaluator) release(ctx context.Context, alert models.Alert) {
+	if err := e.alertRepo.ReleaseTrigger(ctx, alert.ID); err != nil {
+		e.logger.ErrorContext(ctx, "failed to release alert, notification lost", "alert_id", alert.ID.Hex(), "error", err)
+	}
+}
+
+// alertHit reports whether the stock's price satisfies the alert and, if so,
+// the message to send
+func alertHit(alert models.Alert, stock models.Stock) (string, bool) {
+	switch alert.Condition {
+	case models.AlertConditionAbove:
+		if stock.Price > alert.Threshold {
+			return fmt.Sprintf("%s rose above %.2f and is now %.2f", stock.Symbol, alert.Threshold, stock.Price), true
+		}
+	case models.AlertConditionBelow:
+		if stock.Price < alert.Threshold {
+			return fmt.Sprintf("%s fell below %.2f and is now %.2f", stock.Symbol, alert.Threshold, stock.Price), true
+		}
+	case models.AlertConditionPctRise, models.AlertConditionPctDrop:
+		if stock.PrevClose <= 0 {
+			return "", false
+		}
+		change := (stock.Price - stock.PrevClose) / stock.PrevClose * 100
+		if alert.Condition == models.AlertConditionPctRise && change >= alert.Threshold {
+			return fmt.Sprintf("%s is up %.2f%% today at %.2f", stock.Symbol, change, stock.Price), true
+		}
+		if alert.Condition == models.AlertConditionPctDrop && -change >= alert.Threshold {
+			return fmt.Sprintf("%s is down %.2f%% today at %.2f", stock.Symbol, -change, stock.Price), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/notify"
+	"concurrent-wallet-order-system/internal/repo/memory"
+)
+
+// flakyNotifier fails its first delivery
+type flakyNotifier struct {
+	calls     int
+	delivered []models.Notification
+}
+
+func (n *flakyNotifier) Notify(ctx context.Context, user *models.User, notification *models.Notification) error {
+	if n.calls++; n.calls == 1 {
+		return errors.New("mail server unavailable")
+	}
+	n.delivered = append(n.delivered, *notification)
+	return nil
+}
+
+func TestAlertRetriedAfterFailedNotification(t *testing.T) {
+	store := memory.NewStore()
+	alerts := memory.NewAlertRepository(store)
+	users := memory.NewUserRepository(store)
+	notifier := &flakyNotifier{}
+	evaluator := NewAlertEvaluator(alerts, users, notifier, logging.Discard())
+
+	user := &models.User{Name: "Test", Email: "alerts@example.com"}
+	if err := users.CreateUser(t.Context(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	alert := &models.Alert{UserID: user.ID, Symbol: "ACME", Condition: models.AlertConditionAbove, Threshold: 10, Status: models.AlertStatusActive}
+	if err := alerts.CreateAlert(t.Context(), alert); err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+
+	// The failed delivery leaves the alert active for the next price change
+	evaluator.evaluate(t.Context(), models.Stock{Symbol: "ACME", Price: 11})
+	active, err := alerts.GetActiveAlertsBySymbol(t.Context(), "ACME")
+	if err != nil || len(active) != 1 {
+		t.Fatalf("active alerts after the failure: %d, err = %v; want 1", len(active), err)
+	}
+
+	evaluator.evaluate(t.Context(), models.Stock{Symbol: "ACME", Price: 12})
+	evaluator.evaluate(t.Context(), models.Stock{Symbol: "ACME", Price: 13})
+	if len(notifier.delivered) != 1 || notifier.delivered[0].Price != 12 {
+		t.Fatalf("delivered %+v, want one notification at 12", notifier.delivered)
+	}
+
+	active, err = alerts.GetActiveAlertsBySymbol(t.Context(), "ACME")
+	if err != nil || len(active) != 0 {
+		t.Errorf("active alerts after delivery: %d, err = %v; want 0", len(active), err)
+	}
+	if status := evaluator.Status(); status.Failures != 1 || status.Processed != 3 {
+		t.Errorf("status = %+v, want 3 processed with 1 failure", status)
+	}
+}
+
+func TestRetriedAlertDeliveredOncePerChannel(t *testing.T) {
+	store := memory.NewStore()
+	alerts := memory.NewAlertRepository(store)
+	users := memory.NewUserRepository(store)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notifications.jsonl")
+	mailDir := filepath.Join(dir, "mail")
+	flaky := &flakyNotifier{}
+	notifiers := notify.Multi{notify.NewFileNotifier(file), notify.NewSMTPStubNotifier("alerts@example.com", mailDir), flaky}
+	evaluator := NewAlertEvaluator(alerts, users, notifiers, logging.Discard())
+
+	user := &models.User{Name: "Test", Email: "alerts@example.com"}
+	if err := users.CreateUser(t.Context(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	alert := &models.Alert{UserID: user.ID, Symbol: "ACME", Condition: models.AlertConditionAbove, Threshold: 10, Status: models.AlertStatusActive}
+	if err := alerts.CreateAlert(t.Context(), alert); err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+
+	// The file and email go out with the failed attempt and not again with
+	// the retry, nor from a notifier reopening the file after a restart
+	evaluator.evaluate(t.Context(), models.Stock{Symbol: "ACME", Price: 11})
+	evaluator.evaluate(t.Context(), models.Stock{Symbol: "ACME", Price: 12})
+	notification := &models.Notification{UserID: user.ID, AlertID: alert.ID, Symbol: "ACME", Price: 13}
+	if err := notify.NewFileNotifier(file).Notify(t.Context(), user, notification); err != nil {
+		t.Fatalf("notify after restart: %v", err)
+	}
+
+	if len(flaky.delivered) != 1 {
+		t.Errorf("flaky channel delivered %d, want 1", len(flaky.delivered))
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("file has %d lines, want 1", lines)
+	}
+	mails, err := os.ReadDir(mailDir)
+	if err != nil {
+		t.Fatalf("read mail dir: %v", err)
+	}
+	if len(mails) != 1 || mails[0].Name() != alert.ID.Hex()+".eml" {
+		t.Errorf("mail dir has %v, want one email for the alert", mails)
+	}
+}
+
+func TestEnqueueDropsWhenQueueFull(t *testing.T) {
+	store := memory.NewStore()
+	evaluator := NewAlertEvaluator(memory.NewAlertRepository(store), memory.NewUserRepository(store), &flakyNotifier{}, logging.Discard())
+
+	// Not started, so nothing drains the queue
+	queued := make(chan struct{})
+	go func() {
+		for range cap(evaluator.events) + 10 {
+			evaluator.Enqueue(models.Stock{Symbol: "ACME", Price: 11})
+		}
+		close(queued)
+	}()
+
+	select {
+	case <-queued:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Enqueue blocked on a full queue")
+	}
+	if status := evaluator.Status(); status.Queued != cap(evaluator.events) {
+		t.Errorf("queued = %d, want %d", status.Queued, cap(evaluator.events))
+	}
+}
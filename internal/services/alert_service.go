@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AlertService struct {
+	userRepo         repo.UserRepository
+	alertRepo        repo.AlertRepository
+	notificationRepo repo.NotificationRepository
+	stockService     *StockService
+	logger           *slog.Logger
+}
+
+// NewAlertService takes the user repository, since alerts and notifications
+// are only their owner's unless the caller is an admin.
+func NewAlertService(
+	userRepo repo.UserRepository,
+	alertRepo repo.AlertRepository,
+	notificationRepo repo.NotificationRepository,
+	stockService *StockService,
+	logger *slog.Logger,
+) *AlertService {
+	return &AlertService{
+		userRepo:         userRepo,
+		alertRepo:        alertRepo,
+		notificationRepo: notificationRepo,
+		stockService:     stockService,
+		logger:           logger,
+	}
+}
+
+func (s *AlertService) CreateAlert(ctx context.Context, userID primitive.ObjectID, symbol, condition string, threshold float64) (_ *models.Alert, err error) {
+	ctx, span := startSpan(ctx, "AlertService.CreateAlert")
+	defer endSpan(span, &err)
+
+	condition = strings.ToUpper(condition)
+
+	switch condition {
+	case models.AlertConditionAbove, models.AlertConditionBelow, models.AlertConditionPctRise:
+	case models.AlertConditionPctDrop:
+		if threshold > 100 {
+			return nil, invalid("threshold", "a price cannot drop more than 100%")
+		}
+	default:
+		return nil, invalid("condition", "condition must be ABOVE, BELOW, PCT_RISE or PCT_DROP")
+	}
+
+	if threshold <= 0 {
+		return nil, invalid("threshold", "threshold must be greater than zero")
+	}
+
+	stock, err := s.stockService.GetStockBySymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if stock.CurrentStatus() == models.StockStatusDelisted {
+		return nil, ErrStockDelisted
+	}
+
+	alert := &models.Alert{
+		UserID:    userID,
+		Symbol:    stock.Symbol,
+		Condition: condition,
+		Threshold: threshold,
+		Status:    models.AlertStatusActive,
+	}
+
+	err = s.alertRepo.CreateAlert(ctx, alert)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "alert created",
+		"alert_id", alert.ID.Hex(), "user_id", userID.Hex(), "symbol", alert.Symbol,
+		"condition", alert.Condition, "threshold", alert.Threshold)
+	return alert, nil
+}
+
+func (s *AlertService) GetUserAlerts(ctx context.Context, callerID, userID primitive.ObjectID) (_ []models.Alert, err error) {
+	ctx, span := startSpan(ctx, "AlertService.GetUserAlerts")
+	defer endSpan(span, &err)
+
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.alertRepo.GetAlertsByUser(ctx, userID)
+}
+
+// DeleteAlert removes an alert of the caller's; anyone else's needs the
+// admin role
+func (s *AlertService) DeleteAlert(ctx context.Context, callerID, id primitive.ObjectID) (err error) {
+	ctx, span := startSpan(ctx, "AlertService.DeleteAlert")
+	defer endSpan(span, &err)
+
+	alert, err := s.alertRepo.GetAlert(ctx, id)
+	if err != nil {
+		return notFound(err, ErrAlertNotFound)
+	}
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, alert.UserID); err != nil {
+		return err
+	}
+
+	return notFound(s.alertRepo.DeleteAlert(ctx, id), ErrAlertNotFound)
+}
+
+func (s *AlertService) GetNotifications(ctx context.Context, callerID, userID primitive.ObjectID, unreadOnly bool) (_ []models.Notification, err error) {
+	ctx, span := startSpan(ctx, "AlertService.GetNotifications")
+	defer endSpan(span, &err)
+
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.notificationRepo.GetNotificationsByUser(ctx, userID, unreadOnly)
+}
+
+// MarkNotificationRead flags a notification of the caller's as read; anyone
+// else's needs the admin role
+func (s *AlertService) MarkNotificationRead(ctx context.Context, callerID, id primitive.ObjectID) (err error) {
+	ctx, span := startSpan(ctx, "AlertService.MarkNotificationRead")
+	defer endSpan(span, &err)
+
+	notification, err := s.notificationRepo.GetNotification(ctx, id)
+	if err != nil {
+		return notFound(err, ErrNotificationNotFound)
+	}
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, notification.UserID); err != nil {
+		return err
+	}
+
+	return notFound(s.notificationRepo.MarkRead(ctx, id), ErrNotificationNotFound)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"concurrent-wallet-order-system/internal/models"
+)
+
+func TestAlertsOnlyOwnUnlessAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 100)
+	alice := env.createUser(t, 0)
+	bob := env.createUser(t, 0)
+	admin := env.newAdmin(t)
+
+	alert, err := env.alerts.CreateAlert(t.Context(), alice, "AAPL", models.AlertConditionAbove, 120)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	notification := &models.Notification{UserID: alice, AlertID: alert.ID, Symbol: "AAPL"}
+	if err := env.notifications.CreateNotification(t.Context(), notification); err != nil {
+		t.Fatalf("create notification: %v", err)
+	}
+
+	if _, err := env.alerts.GetUserAlerts(t.Context(), bob, alice); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("list alerts: err = %v, want ErrAdminRequired", err)
+	}
+	if err := env.alerts.DeleteAlert(t.Context(), bob, alert.ID); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("delete alert: err = %v, want ErrAdminRequired", err)
+	}
+	if _, err := env.alerts.GetNotifications(t.Context(), bob, alice, false); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("list notifications: err = %v, want ErrAdminRequired", err)
+	}
+	if err := env.alerts.MarkNotificationRead(t.Context(), bob, notification.ID); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("mark read: err = %v, want ErrAdminRequired", err)
+	}
+
+	// Nothing changed for the owner
+	unread, err := env.alerts.GetNotifications(t.Context(), alice, alice, true)
+	if err != nil || len(unread) != 1 {
+		t.Fatalf("own unread notifications: %d, err = %v; want 1", len(unread), err)
+	}
+	if err := env.alerts.MarkNotificationRead(t.Context(), alice, notification.ID); err != nil {
+		t.Errorf("mark own read: %v", err)
+	}
+	if err := env.alerts.DeleteAlert(t.Context(), admin, alert.ID); err != nil {
+		t.Errorf("admin delete: %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"concurrent-wallet-order-system/internal/audit"
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// maxAuditAttempts bounds the retries of an append that keeps losing its
+	// place in the chain to other instances
+	maxAuditAttempts = 10
+	// auditVerifyBatch is how many entries Verify reads at a time
+	auditVerifyBatch = 500
+
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// AuditVerification is the result of checking the audit log's hash chain
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`            // entries checked, up to the first broken one
+	Head     string `json:"head,omitempty"`     // hash of the last valid entry
+	BrokenAt int64  `json:"brokenAt,omitempty"` // seq of the first broken entry
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AuditService keeps the audit log of state changes. Each entry is chained to
+// the one before it by hash, so an edited, inserted or deleted entry shows up
+// when the chain is verified.
+type AuditService struct {
+	auditRepo repo.AuditRepository
+	now       func() time.Time
+	logger    *slog.Logger
+	mu        sync.Mutex
+}
+
+func NewAuditService(auditRepo repo.AuditRepository, logger *slog.Logger) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+		now:       time.Now,
+		logger:    logger,
+	}
+}
+
+// Record appends an entry for action on the target, with JSON snapshots of
+// it before and after; nil means there is none. The actor, client IP and
+// request ID come from ctx. Failures are logged: callers record changes that
+// have already happened, and failing those would only get them retried.
+func (s *AuditService) Record(ctx context.Context, action, targetType, targetID string, before, after any) (err error) {
+	ctx, span := startSpan(ctx, "AuditService.Record", attribute.String("audit.action", action))
+	defer endSpan(span, &err)
+
+	// The change has happened; record it even if the client goes away
+	ctx = context.WithoutCancel(ctx)
+
+	defer func() {
+		if err != nil {
+			s.logger.ErrorContext(ctx, "audit entry not recorded", "action", action, "target_type", targetType, "target_id", targetID, "error", err)
+		}
+	}()
+
+	actor, ip := audit.From(ctx)
+	entry := &models.AuditEntry{
+		ActorType:  actor.Kind,
+		ActorID:    actor.ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         ip,
+		RequestID:  logging.RequestID(ctx),
+	}
+	if entry.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	// Appends from this instance queue here; other instances can still take
+	// the next seq first, and then this one chains onto theirs
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		last, err := s.auditRepo.LastAuditEntry(ctx)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			entry.Seq, entry.PrevHash = 1, ""
+		case err != nil:
+			return err
+		default:
+			entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+		}
+
+		// Mongo keeps milliseconds; hash what will be read back
+		entry.At = s.now().UTC().Truncate(time.Millisecond)
+		entry.Hash = auditHash(entry)
+
+		err = s.auditRepo.InsertAuditEntry(ctx, entry)
+		if !mongo.IsDuplicateKeyError(err) || attempt == maxAuditAttempts {
+			return err
+		}
+	}
+}
+
+// List returns a page of the audit log, newest first, and how many entries
+// match in all; admin only
+func (s *AuditService) List(ctx context.Context, filter repo.AuditFilter) (_ []models.AuditEntry, _ int64, err error) {
+	ctx, span := startSpan(ctx, "AuditService.List")
+	defer endSpan(span, &err)
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, 0, invalid("from", "from must be before to")
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.Limit < 1 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+
+	return s.auditRepo.ListAuditEntries(ctx, filter)
+}
+
+// Verify walks the whole chain from the first entry and reports the first
+// one that is missing, out of place or altered; admin only. Deleting entries
+// from the end leaves a valid chain, so compare Head with one noted earlier.
+func (s *AuditService) Verify(ctx context.Context) (_ *AuditVerification, err error) {
+	ctx, span := startSpan(ctx, "AuditService.Verify")
+	defer endSpan(span, &err)
+
+	result := &AuditVerification{Valid: true}
+	next := int64(1)
+
+	for {
+		entries, err := s.auditRepo.AuditEntriesFrom(ctx, next, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range entries {
+			e := &entries[i]
+			switch {
+			case e.Seq != next:
+				result.Reason = fmt.Sprintf("entry %d is missing", next)
+			case e.PrevHash != result.Head:
+				result.Reason = "entry does not follow the one before it"
+			case auditHash(e) != e.Hash:
+				result.Reason = "entry does not match its hash"
+			}
+			if result.Reason != "" {
+				result.Valid = false
+				result.BrokenAt = next
+				s.logger.ErrorContext(ctx, "audit log chain broken", "seq", next, "reason", result.Reason)
+				return result, nil
+			}
+
+			result.Checked++
+			result.Head = e.Hash
+			next++
+		}
+
+		if len(entries) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+// snapshot encodes a before or after state; nil stays empty
+func snapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// auditHash is the SHA-256 of everything in the entry but its ID and hash,
+// in a fixed JSON encoding
+func auditHash(e *models.AuditEntry) string {
+	content, err := json.Marshal(struct {
+		Seq        int64           `json:"seq"`
+		At         string          `json:"at"`
+		ActorType  string          `json:"actorType"`
+		ActorID    string          `json:"actorId"`
+		Action     string          `json:"action"`
+		TargetType string          `json:"targetType"`
+		TargetID   string          `json:"targetId"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		IP         string          `json:"ip"`
+		RequestID  string          `json:"requestId"`
+		PrevHash   string          `json:"prevHash"`
+	}{
+		Seq:        e.Seq,
+		At:         e.At.UTC().Format(time.RFC3339Nano),
+		ActorType:  e.ActorType,
+		ActorID:    e.ActorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Before:     e.Before,
+		After:      e.After,
+		IP:         e.IP,
+		RequestID:  e.RequestID,
+		PrevHash:   e.PrevHash,
+	})
+	if err != nil {
+		// Only a snapshot that is no longer valid JSON, i.e. tampered with
+		return ""
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+
+	"concurrent-wallet-order-system/internal/audit"
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+	"concurrent-wallet-order-system/internal/repo/memory"
+)
+
+func TestAuditLogRecordsChanges(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "ACME", 10)
+	userID := env.createUser(t, 0)
+
+	// As the middleware would for an authenticated request
+	ctx := logging.WithRequest(audit.WithClient(t.Context(), "10.0.0.1"), "req-1", "/api/v1/wallet/deposit")
+	audit.SetActor(ctx, audit.Actor{Kind: audit.ActorUser, ID: userID.Hex()})
+
+	if err := env.walletService.Deposit(ctx, userID, 100); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	order, err := env.orderService.Buy(ctx, userID, "ACME", 2)
+	if err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+	if _, err := env.userService.SetRole(t.Context(), userID, models.RoleAdmin); err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+
+	entries, total, err := env.auditService.List(t.Context(), repo.AuditFilter{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	// Newest first
+	want := []string{models.AuditRoleChanged, models.AuditOrderBuy, models.AuditWalletDeposit, models.AuditStockCreated}
+	if !slices.Equal(actions, want) || total != int64(len(want)) {
+		t.Fatalf("actions = %v of %d, want %v", actions, total, want)
+	}
+
+	deposit := entries[2]
+	if deposit.ActorType != audit.ActorUser || deposit.ActorID != userID.Hex() || deposit.IP != "10.0.0.1" || deposit.RequestID != "req-1" {
+		t.Errorf("deposit attributed to %s %s from %s in %s", deposit.ActorType, deposit.ActorID, deposit.IP, deposit.RequestID)
+	}
+	if string(deposit.Before) != `{"walletbalance":0}` || string(deposit.After) != `{"walletbalance":100}` {
+		t.Errorf("deposit snapshots = %s -> %s", deposit.Before, deposit.After)
+	}
+	if entries[1].TargetID != order.ID.Hex() {
+		t.Errorf("order entry targets %q, want %q", entries[1].TargetID, order.ID.Hex())
+	}
+	if role := entries[0]; role.ActorType != audit.ActorSystem || string(role.Before) != `{"role":"user"}` || string(role.After) != `{"role":"admin"}` {
+		t.Errorf("role change = %+v", role)
+	}
+
+	byTarget, _, err := env.auditService.List(t.Context(), repo.AuditFilter{TargetType: models.AuditTargetUser, TargetID: userID.Hex()})
+	if err != nil || len(byTarget) != 2 {
+		t.Errorf("entries for the user: %d, err = %v; want 2", len(byTarget), err)
+	}
+
+	result, err := env.auditService.Verify(t.Context())
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !result.Valid || result.Checked != int64(len(want)) || result.Head != entries[0].Hash {
+		t.Errorf("verify = %+v", result)
+	}
+}
+
+func TestTradesAreAuditedAsOrders(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "ACME", 10)
+	userID := env.createUser(t, 0)
+
+	if err := env.walletService.Deposit(t.Context(), userID, 100); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if _, err := env.orderService.Buy(t.Context(), userID, "ACME", 2); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+	if _, err := env.orderService.Sell(t.Context(), userID, "ACME", 1); err != nil {
+		t.Fatalf("sell: %v", err)
+	}
+
+	entries, _, err := env.auditService.List(t.Context(), repo.AuditFilter{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	// The trades' wallet legs are not deposits or withdrawals
+	want := []string{models.AuditOrderSell, models.AuditOrderBuy, models.AuditWalletDeposit, models.AuditStockCreated}
+	if !slices.Equal(actions, want) {
+		t.Errorf("actions = %v, want %v", actions, want)
+	}
+
+	history, err := env.walletService.GetHistory(t.Context(), userID, userID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	var methods []string
+	for _, tx := range history {
+		methods = append(methods, tx.Method)
+	}
+	slices.Sort(methods)
+	if want := []string{"deposit", "trade", "trade"}; !slices.Equal(methods, want) {
+		t.Errorf("wallet history methods = %v, want %v", methods, want)
+	}
+}
+
+// tamperedAuditRepo changes the entries Verify reads, as someone with write
+// access to the database might
+type tamperedAuditRepo struct {
+	repo.AuditRepository
+	tamper func([]models.AuditEntry) []models.AuditEntry
+}
+
+func (r tamperedAuditRepo) AuditEntriesFrom(ctx context.Context, seq, limit int64) ([]models.AuditEntry, error) {
+	entries, err := r.AuditRepository.AuditEntriesFrom(ctx, seq, limit)
+	return r.tamper(entries), err
+}
+
+func TestAuditVerifyDetectsTampering(t *testing.T) {
+	auditRepo := memory.NewAuditRepository(memory.NewStore())
+	service := NewAuditService(auditRepo, logging.Discard())
+	for i := range 4 {
+		if err := service.Record(t.Context(), models.AuditWalletDeposit, models.AuditTargetUser, "u1",
+			balanceSnapshot{float64(i * 10)}, balanceSnapshot{float64(i*10 + 10)}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		tamper   func([]models.AuditEntry) []models.AuditEntry
+		brokenAt int64
+	}{
+		{"snapshot edited", func(e []models.AuditEntry) []models.AuditEntry {
+			e[1].After = json.RawMessage(`{"walletbalance":1000}`)
+			return e
+		}, 2},
+		{"actor edited", func(e []models.AuditEntry) []models.AuditEntry {
+			e[2].ActorType = audit.ActorAdmin
+			return e
+		}, 3},
+		{"entry deleted", func(e []models.AuditEntry) []models.AuditEntry {
+			return slices.Delete(e, 1, 2)
+		}, 2},
+		{"entry rehashed", func(e []models.AuditEntry) []models.AuditEntry {
+			e[1].TargetID = "u2"
+			e[1].Hash = auditHash(&e[1])
+			return e
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewAuditService(tamperedAuditRepo{auditRepo, tt.tamper}, logging.Discard())
+			result, err := verifier.Verify(t.Context())
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if result.Valid || result.BrokenAt != tt.brokenAt || result.Checked != tt.brokenAt-1 {
+				t.Errorf("verify = %+v, want broken at %d", result, tt.brokenAt)
+			}
+		})
+	}
+}
+
+func TestAuditConcurrentWritersKeepOneChain(t *testing.T) {
+	auditRepo := memory.NewAuditRepository(memory.NewStore())
+
+	// Separate services stand in for replicas sharing the collection
+	const replicas, perReplica = 3, 10
+	var wg sync.WaitGroup
+	for range replicas {
+		service := NewAuditService(auditRepo, logging.Discard())
+		wg.Go(func() {
+			for range perReplica {
+				if err := service.Record(t.Context(), models.AuditStockCreated, models.AuditTargetStock, "ACME", nil, nil); err != nil {
+					t.Errorf("record: %v", err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	result, err := NewAuditService(auditRepo, logging.Discard()).Verify(t.Context())
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !result.Valid || result.Checked != replicas*perReplica {
+		t.Errorf("verify = %+v, want %d valid entries", result, replicas*perReplica)
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"concurrent-wallet-order-system/internal/metrics"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Kind groups domain errors by how a client should react to them. The HTTP
+// layer maps each kind to a status code.
+type Kind int
+
+const (
+	KindInvalid      Kind = iota + 1 // the request breaks a validation rule
+	KindNotFound                     // a referenced resource does not exist
+	KindConflict                     // the current state does not allow the operation
+	KindUnauthorized                 // the caller could not be authenticated
+	KindThrottled                    // too many attempts; retry later
+	KindForbidden                    // the caller must do more before the operation is allowed
+)
+
+// Error is a domain error with a stable, machine-readable code. The exported
+// Err* values are sentinels: compare with errors.Is, which matches on Code,
+// so a sentinel wrapped with more detail (see withDetail) still matches.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrInvalidCredentials = &Error{KindUnauthorized, "invalid_credentials", "invalid email or password"}
+	ErrUserNotFound       = &Error{KindNotFound, "user_not_found", "user not found"}
+	ErrEmailTaken         = &Error{KindConflict, "email_taken", "email already registered"}
+	ErrAdminRequired      = &Error{KindForbidden, "admin_required", "this needs the admin role"}
+	ErrUserMismatch       = &Error{KindForbidden, "user_mismatch", "userId does not match the access token"}
+
+	ErrLoginThrottled = &Error{KindThrottled, "login_throttled", "too many failed logins, wait before trying again"}
+	ErrAccountLocked  = &Error{KindThrottled, "account_locked", "account is temporarily locked after too many failed logins"}
+	ErrLoginBlocked   = &Error{KindThrottled, "login_blocked", "too many failed logins from this address"}
+
+	ErrInvalidRefreshToken = &Error{KindUnauthorized, "invalid_refresh_token", "refresh token is invalid or expired"}
+	ErrRefreshTokenReused  = &Error{KindUnauthorized, "refresh_token_reused", "refresh token was already used; the session has been revoked"}
+	ErrSessionNotFound     = &Error{KindNotFound, "session_not_found", "session not found"}
+
+	ErrInvalidChallenge    = &Error{KindUnauthorized, "invalid_challenge", "login challenge is invalid or expired; log in again"}
+	ErrInvalidSecondFactor = &Error{KindUnauthorized, "invalid_second_factor", "invalid authentication code"}
+	ErrTwoFactorEnabled    = &Error{KindConflict, "two_factor_enabled", "two-factor authentication is already enabled"}
+	ErrTwoFactorNotSetUp   = &Error{KindConflict, "two_factor_not_set_up", "set up two-factor authentication first"}
+	ErrTwoFactorNotEnabled = &Error{KindConflict, "two_factor_not_enabled", "two-factor authentication is not enabled"}
+	ErrTwoFactorRequired   = &Error{KindForbidden, "two_factor_required", "enable two-factor authentication to make this withdrawal"}
+	ErrStepUpRequired      = &Error{KindForbidden, "step_up_required", "this withdrawal needs an authentication code"}
+
+	ErrInvalidVerificationToken = &Error{KindInvalid, "invalid_verification_token", "verification link is invalid, expired or already used"}
+	ErrInvalidResetToken        = &Error{KindInvalid, "invalid_reset_token", "password reset link is invalid, expired or already used"}
+	ErrEmailAlreadyVerified     = &Error{KindConflict, "email_already_verified", "email address is already verified"}
+	ErrEmailNotVerified         = &Error{KindForbidden, "email_not_verified", "verify your email address to make withdrawals"}
+
+	ErrIncorrectPassword = &Error{KindForbidden, "incorrect_password", "current password is incorrect"}
+	ErrAccountClosed     = &Error{KindConflict, "account_closed", "account is closed"}
+	ErrBalanceNotZero    = &Error{KindConflict, "balance_not_zero", "withdraw the remaining balance before closing the account"}
+	ErrHoldingsNotEmpty  = &Error{KindConflict, "holdings_not_empty", "sell all holdings before closing the account"}
+
+	ErrKYCRequired        = &Error{KindForbidden, "kyc_required", "verify your identity to trade"}
+	ErrDailyLimitExceeded = &Error{KindForbidden, "daily_limit_exceeded", "daily limit of your verification tier exceeded"}
+	ErrKYCPending         = &Error{KindConflict, "kyc_pending", "identity documents are already under review"}
+	ErrKYCVerified        = &Error{KindConflict, "kyc_already_verified", "identity is already verified"}
+	ErrKYCNotPending      = &Error{KindConflict, "kyc_not_pending", "no identity documents are awaiting review"}
+
+	ErrInsufficientBalance = &Error{KindConflict, "insufficient_balance", "insufficient balance"}
+
+	ErrStockNotFound = &Error{KindNotFound, "stock_not_found", "stock not found"}
+	ErrStockExists   = &Error{KindConflict, "stock_exists", "stock already exists"}
+	ErrStockHalted   = &Error{KindConflict, "stock_halted", "trading is halted for this stock"}
+	ErrStockDelisted = &Error{KindConflict, "stock_delisted", "stock is delisted"}
+
+	ErrStockNotOwned        = &Error{KindConflict, "stock_not_owned", "stock not owned"}
+	ErrInsufficientQuantity = &Error{KindConflict, "insufficient_quantity", "insufficient stock quantity"}
+
+	ErrWatchlistNotFound  = &Error{KindNotFound, "watchlist_not_found", "watchlist not found"}
+	ErrWatchlistNameTaken = &Error{KindConflict, "watchlist_name_taken", "watchlist name already exists"}
+	ErrWatchlistLimit     = &Error{KindConflict, "watchlist_limit_reached", "watchlist limit reached"}
+	ErrWatchlistFull      = &Error{KindConflict, "watchlist_full", "too many symbols in watchlist"}
+
+	ErrAlertNotFound        = &Error{KindNotFound, "alert_not_found", "alert not found"}
+	ErrNotificationNotFound = &Error{KindNotFound, "notification_not_found", "notification not found"}
+)
+
+// withDetail returns a copy of a sentinel with extra context in its message
+func withDetail(sentinel *Error, detail string) error {
+	return &Error{
+		Kind:    sentinel.Kind,
+		Code:    sentinel.Code,
+		Message: fmt.Sprintf("%s: %s", sentinel.Message, detail),
+	}
+}
+
+// RetryError is a domain error that clears after RetryAfter, e.g. a lockout
+type RetryError struct {
+	Err        *Error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func retryAfter(sentinel *Error, d time.Duration) error {
+	return &RetryError{Err: sentinel, RetryAfter: d}
+}
+
+// ValidationError reports an invalid input value
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func invalid(field, message string) error {
+	return &ValidationError{Field: field, Message: message}
+}
+
+// notFound turns the repositories' missing document error into the given
+// sentinel and passes any other error through
+func notFound(err error, sentinel *Error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return sentinel
+	}
+	return err
+}
+
+// outcome classifies the result of an operation for the metrics. Domain
+// errors are rejections; anything else is a failure of the backing store.
+func outcome(err error) string {
+	var domainErr *Error
+	var validationErr *ValidationError
+
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.As(err, &domainErr), errors.As(err, &validationErr):
+		return metrics.OutcomeRejected
+	default:
+		return metrics.OutcomeError
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxKYCDocuments bounds a single submission
+const maxKYCDocuments = 5
+
+// TierLimits are the most a user may deposit, withdraw and trade in order
+// notional per UTC day
+type TierLimits struct {
+	Deposit    float64 `json:"deposit"`
+	Withdrawal float64 `json:"withdrawal"`
+	Order      float64 `json:"order"`
+}
+
+func (l TierLimits) of(kind string) float64 {
+	switch kind {
+	case models.LimitDeposit:
+		return l.Deposit
+	case models.LimitWithdrawal:
+		return l.Withdrawal
+	case models.LimitOrder:
+		return l.Order
+	}
+	return 0
+}
+
+// KYCStatus describes a user's identity verification and what their tier
+// allows them today
+type KYCStatus struct {
+	KYC       models.KYC `json:"kyc"`
+	Limits    TierLimits `json:"limits"`
+	UsedToday TierLimits `json:"usedToday"`
+}
+
+// KYCService runs identity verification: users submit document metadata,
+// admins approve it at a tier or reject it. Orders need a verified
+// identity, and the tier sets the daily limits that WalletService and
+// OrderService reserve against.
+type KYCService struct {
+	userRepo repo.UserRepository
+	usage    repo.LimitUsageRepository
+	limits   []TierLimits
+	now      func() time.Time
+	logger   *slog.Logger
+}
+
+// NewKYCService takes the daily limits of each tier, indexed by tier:
+// models.KYCTierNone, models.KYCTierBasic and models.KYCTierFull
+func NewKYCService(userRepo repo.UserRepository, usage repo.LimitUsageRepository, limits []TierLimits, logger *slog.Logger) *KYCService {
+	return &KYCService{
+		userRepo: userRepo,
+		usage:    usage,
+		limits:   limits,
+		now:      time.Now,
+		logger:   logger,
+	}
+}
+
+// Status returns the user's verification, limits and usage today
+func (s *KYCService) Status(ctx context.Context, userID primitive.ObjectID) (_ *KYCStatus, err error) {
+	ctx, span := startSpan(ctx, "KYCService.Status")
+	defer endSpan(span, &err)
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	status := &KYCStatus{Limits: s.limits[user.KYCTier()]}
+	if user.KYC != nil {
+		status.KYC = *user.KYC
+	}
+	status.KYC.Status = user.KYCStatus()
+	status.KYC.Tier = user.KYCTier()
+
+	usage, err := s.usage.GetLimitUsage(ctx, userID, s.now().UTC().Format(time.DateOnly))
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	if usage != nil {
+		status.UsedToday = TierLimits{Deposit: usage.Deposit, Withdrawal: usage.Withdrawal, Order: usage.Order}
+	}
+
+	return status, nil
+}
+
+// Submit puts the user's documents up for review. It is refused while
+// earlier ones are pending or once the user is verified; a rejected user
+// can submit again.
+func (s *KYCService) Submit(ctx context.Context, userID primitive.ObjectID, documents []models.KYCDocument) (_ *KYCStatus, err error) {
+	ctx, span := startSpan(ctx, "KYCService.Submit", attribute.String("user.id", userID.Hex()))
+	defer endSpan(span, &err)
+
+	documents, err = s.checkDocuments(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	if user.Closed() {
+		return nil, ErrAccountClosed
+	}
+
+	now := s.now()
+	err = s.userRepo.SubmitKYC(ctx, userID, &models.KYC{
+		Status:      models.KYCPending,
+		Tier:        models.KYCTierNone,
+		Documents:   documents,
+		SubmittedAt: &now,
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Submitted or approved meanwhile, if not already
+		if user, err := s.userRepo.GetUserByID(ctx, userID); err == nil && user.KYCStatus() == models.KYCVerified {
+			return nil, ErrKYCVerified
+		}
+		return nil, ErrKYCPending
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "kyc documents submitted", "user_id", userID.Hex(), "documents", len(documents))
+	return s.Status(ctx, userID)
+}
+
+// checkDocuments validates a submission and normalises it. At least one
+// document must prove identity; a proof of address alone does not.
+func (s *KYCService) checkDocuments(documents []models.KYCDocument) ([]models.KYCDocument, error) {
+	if len(documents) == 0 || len(documents) > maxKYCDocuments {
+		return nil, invalid("documents", fmt.Sprintf("submit between 1 and %d documents", maxKYCDocuments))
+	}
+
+	identity := false
+	today := s.now().UTC().Format(time.DateOnly)
+	checked := make([]models.KYCDocument, len(documents))
+	for i, d := range documents {
+		d.Type = strings.ToLower(strings.TrimSpace(d.Type))
+		d.Country = strings.ToUpper(strings.TrimSpace(d.Country))
+		d.Number = strings.TrimSpace(d.Number)
+		d.Reference = strings.TrimSpace(d.Reference)
+		field := fmt.Sprintf("documents[%d]", i)
+
+		switch d.Type {
+		case models.KYCDocumentPassport, models.KYCDocumentNationalID, models.KYCDocumentDrivingLicence:
+			identity = true
+		case models.KYCDocumentProofOfAddress:
+		default:
+			return nil, invalid(field+".type", "type must be passport, national_id, driving_licence or proof_of_address")
+		}
+		if len(d.Country) != 2 || strings.Trim(d.Country, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return nil, invalid(field+".country", "country must be a two-letter ISO 3166-1 code")
+		}
+		if d.Number == "" || len(d.Number) > 64 {
+			return nil, invalid(field+".number", "number is required and at most 64 characters")
+		}
+		if d.Reference == "" {
+			return nil, invalid(field+".reference", "reference to the stored document is required")
+		}
+		if d.ExpiresOn != "" {
+			if _, err := time.Parse(time.DateOnly, d.ExpiresOn); err != nil {
+				return nil, invalid(field+".expiresOn", "expiresOn must be a date such as 2030-12-31")
+			}
+			// Dates in this format sort as strings
+			if d.ExpiresOn <= today {
+				return nil, invalid(field+".expiresOn", "document has expired")
+			}
+		}
+
+		checked[i] = d
+	}
+
+	if !identity {
+		return nil, invalid("documents", "include a passport, national ID or driving licence")
+	}
+	return checked, nil
+}
+
+// List returns the open accounts with the given KYC status, earliest
+// submission first; admin only. The pending ones are the review queue.
+func (s *KYCService) List(ctx context.Context, status string) (_ []models.User, err error) {
+	ctx, span := startSpan(ctx, "KYCService.List")
+	defer endSpan(span, &err)
+
+	switch status {
+	case models.KYCUnverified, models.KYCPending, models.KYCVerified, models.KYCRejected:
+	default:
+		return nil, invalid("status", "status must be unverified, pending, verified or rejected")
+	}
+
+	return s.userRepo.ListUsersByKYCStatus(ctx, status)
+}
+
+// Approve verifies the user's identity at tier; admin only. A verified user
+// can be moved to another tier the same way.
+func (s *KYCService) Approve(ctx context.Context, userID primitive.ObjectID, tier int) (_ *KYCStatus, err error) {
+	ctx, span := startSpan(ctx, "KYCService.Approve", attribute.String("user.id", userID.Hex()))
+	defer endSpan(span, &err)
+
+	if tier != models.KYCTierBasic && tier != models.KYCTierFull {
+		return nil, invalid("tier", fmt.Sprintf("tier must be %d (basic) or %d (full)", models.KYCTierBasic, models.KYCTierFull))
+	}
+
+	err = s.review(ctx, userID, []string{models.KYCPending, models.KYCVerified}, models.KYCVerified, tier, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Status(ctx, userID)
+}
+
+// Reject turns down the user's pending documents with a reason they are
+// shown; admin only
+func (s *KYCService) Reject(ctx context.Context, userID primitive.ObjectID, reason string) (_ *KYCStatus, err error) {
+	ctx, span := startSpan(ctx, "KYCService.Reject", attribute.String("user.id", userID.Hex()))
+	defer endSpan(span, &err)
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, invalid("reason", "reason is required")
+	}
+
+	err = s.review(ctx, userID, []string{models.KYCPending}, models.KYCRejected, models.KYCTierNone, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Status(ctx, userID)
+}
+
+func (s *KYCService) review(ctx context.Context, userID primitive.ObjectID, from []string, status string, tier int, reason string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if user.Closed() {
+		return ErrAccountClosed
+	}
+
+	err = s.userRepo.ReviewKYC(ctx, userID, from, status, tier, reason, s.now())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrKYCNotPending
+	}
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "kyc reviewed", "user_id", userID.Hex(), "status", status, "tier", tier)
+	return nil
+}
+
+// reserveOrder counts an order's notional against the user's daily limit.
+// Only users with a verified identity can trade.
+func (s *KYCService) reserveOrder(ctx context.Context, userID primitive.ObjectID, notional float64) (func(), error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	if user.KYCStatus() != models.KYCVerified {
+		return nil, ErrKYCRequired
+	}
+
+	return s.reserve(ctx, user, models.LimitOrder, notional)
+}
+
+// reserve counts amount against the user's daily limit of kind, refusing it
+// if that would exceed the limit of their tier. The returned func gives the
+// amount back, for when the operation it was reserved for fails.
+func (s *KYCService) reserve(ctx context.Context, user *models.User, kind string, amount float64) (func(), error) {
+	limit := s.limits[user.KYCTier()].of(kind)
+
+	// Days are UTC; the record outlives its day by one, for support queries
+	now := s.now().UTC()
+	day := now.Format(time.DateOnly)
+	expires := now.Truncate(24 * time.Hour).Add(48 * time.Hour)
+
+	err := s.usage.AddLimitUsage(ctx, user.ID, day, kind, amount, limit, expires)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		s.logger.InfoContext(ctx, "daily limit exceeded", "user_id", user.ID.Hex(), "kind", kind, "amount", amount, "limit", limit)
+		return nil, withDetail(ErrDailyLimitExceeded, fmt.Sprintf("%s limit is %.2f", kind, limit))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	release := func() {
+		// The operation has failed either way; this only undoes the count
+		ctx := context.WithoutCancel(ctx)
+		if err := s.usage.AddLimitUsage(ctx, user.ID, day, kind, -amount, limit, expires); err != nil {
+			s.logger.ErrorContext(ctx, "releasing daily limit failed", "user_id", user.ID.Hex(), "kind", kind, "amount", amount, "error", err)
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testPassport = models.KYCDocument{Type: "passport", Country: "gb", Number: "123456789", ExpiresOn: "2030-01-01", Reference: "uploads/passport.png"}
+
+// verifyIdentity submits documents for the user and approves them at tier
+func (e *testEnv) verifyIdentity(t *testing.T, userID primitive.ObjectID, tier int) {
+	t.Helper()
+
+	if _, err := e.kycService.Submit(t.Context(), userID, []models.KYCDocument{testPassport}); err != nil {
+		t.Fatalf("submit kyc: %v", err)
+	}
+	if _, err := e.kycService.Approve(t.Context(), userID, tier); err != nil {
+		t.Fatalf("approve kyc: %v", err)
+	}
+}
+
+func TestKYCReview(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	env.kycService.now = clk.now
+
+	if _, err := env.kycService.Submit(t.Context(), user.ID, []models.KYCDocument{{Type: "proof_of_address", Country: "GB", Number: "1", Reference: "bill.pdf"}}); !errors.As(err, new(*ValidationError)) {
+		t.Fatalf("proof of address only: err = %v, want a ValidationError", err)
+	}
+	expired := testPassport
+	expired.ExpiresOn = "2025-12-31"
+	if _, err := env.kycService.Submit(t.Context(), user.ID, []models.KYCDocument{expired}); !errors.As(err, new(*ValidationError)) {
+		t.Fatalf("expired passport: err = %v, want a ValidationError", err)
+	}
+
+	status, err := env.kycService.Submit(t.Context(), user.ID, []models.KYCDocument{testPassport})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if status.KYC.Status != models.KYCPending || status.KYC.Documents[0].Country != "GB" {
+		t.Errorf("status %s, country %s; want pending, GB", status.KYC.Status, status.KYC.Documents[0].Country)
+	}
+	if _, err := env.kycService.Submit(t.Context(), user.ID, []models.KYCDocument{testPassport}); !errors.Is(err, ErrKYCPending) {
+		t.Errorf("submit twice: err = %v, want ErrKYCPending", err)
+	}
+
+	queue, err := env.kycService.List(t.Context(), models.KYCPending)
+	if err != nil || len(queue) != 1 || queue[0].ID != user.ID {
+		t.Fatalf("review queue = %v, err = %v; want the user", queue, err)
+	}
+
+	if _, err := env.kycService.Reject(t.Context(), user.ID, "photo is blurred"); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if _, err := env.kycService.Reject(t.Context(), user.ID, "again"); !errors.Is(err, ErrKYCNotPending) {
+		t.Errorf("reject twice: err = %v, want ErrKYCNotPending", err)
+	}
+
+	// A rejected user can try again
+	if _, err := env.kycService.Submit(t.Context(), user.ID, []models.KYCDocument{testPassport}); err != nil {
+		t.Fatalf("resubmit: %v", err)
+	}
+	status, err = env.kycService.Approve(t.Context(), user.ID, models.KYCTierBasic)
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if status.KYC.Status != models.KYCVerified || status.KYC.Tier != models.KYCTierBasic || status.KYC.RejectionReason != "" {
+		t.Errorf("got %+v, want verified at the basic tier", status.KYC)
+	}
+	if status.Limits != testTierLimits[models.KYCTierBasic] {
+		t.Errorf("limits = %+v, want %+v", status.Limits, testTierLimits[models.KYCTierBasic])
+	}
+	if _, err := env.kycService.Submit(t.Context(), user.ID, []models.KYCDocument{testPassport}); !errors.Is(err, ErrKYCVerified) {
+		t.Errorf("submit when verified: err = %v, want ErrKYCVerified", err)
+	}
+}
+
+func TestTradingNeedsKYC(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	env.kycService.now = clk.now
+	env.createStock(t, "ACME", 100)
+	if err := env.walletService.Deposit(t.Context(), user.ID, 1000); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+
+	if _, err := env.orderService.Buy(t.Context(), user.ID, "ACME", 1); !errors.Is(err, ErrKYCRequired) {
+		t.Fatalf("buy before kyc: err = %v, want ErrKYCRequired", err)
+	}
+
+	env.verifyIdentity(t, user.ID, models.KYCTierBasic)
+	if _, err := env.orderService.Buy(t.Context(), user.ID, "ACME", 1); err != nil {
+		t.Fatalf("buy after kyc: %v", err)
+	}
+}
+
+func TestDailyLimits(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	env.kycService.now = clk.now
+	if err := env.users.MarkEmailVerified(t.Context(), user.ID, user.Email, clk.now()); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	env.verifyIdentity(t, user.ID, models.KYCTierBasic)
+	env.createStock(t, "ACME", 100)
+
+	// Basic tier: deposit 1000, withdrawal 500, orders 1000 a day
+	if err := env.walletService.Deposit(t.Context(), user.ID, 800); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if err := env.walletService.Deposit(t.Context(), user.ID, 300); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Errorf("deposit over the limit: err = %v, want ErrDailyLimitExceeded", err)
+	}
+	if err := env.walletService.Deposit(t.Context(), user.ID, 200); err != nil {
+		t.Errorf("deposit up to the limit: %v", err)
+	}
+
+	if err := env.walletService.Withdraw(t.Context(), user.ID, 900, ""); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Errorf("withdraw over the limit: err = %v, want ErrDailyLimitExceeded", err)
+	}
+	if err := env.walletService.Withdraw(t.Context(), user.ID, 500, ""); err != nil {
+		t.Fatalf("withdraw up to the limit: %v", err)
+	}
+
+	// A failed order gives its notional back
+	if _, err := env.orderService.Buy(t.Context(), user.ID, "ACME", 6); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("buy without the balance: err = %v, want ErrInsufficientBalance", err)
+	}
+
+	// Buys and sells both count towards the order notional
+	if _, err := env.orderService.Buy(t.Context(), user.ID, "ACME", 4); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+	if _, err := env.orderService.Sell(t.Context(), user.ID, "ACME", 4); err != nil {
+		t.Fatalf("sell: %v", err)
+	}
+	if _, err := env.orderService.Buy(t.Context(), user.ID, "ACME", 3); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Errorf("buy over the limit: err = %v, want ErrDailyLimitExceeded", err)
+	}
+
+	status, err := env.kycService.Status(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if want := (TierLimits{Deposit: 1000, Withdrawal: 500, Order: 800}); status.UsedToday != want {
+		t.Errorf("used today = %+v, want %+v", status.UsedToday, want)
+	}
+
+	// The limits reset at midnight UTC
+	clk.advance(24 * time.Hour)
+	if err := env.walletService.Deposit(t.Context(), user.ID, 1000); err != nil {
+		t.Errorf("deposit the next day: %v", err)
+	}
+}
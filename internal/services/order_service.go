@@ -1,70 +1,138 @@
 package services
 
 import (
+	"concurrent-wallet-order-system/internal/metrics"
 	"concurrent-wallet-order-system/internal/models"
 	"concurrent-wallet-order-system/internal/repo"
-	"errors"
-	"fmt"
+	"context"
+	"log/slog"
 	"strings"
 	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Delisting modes for open holdings
+const (
+	DelistModeLiquidate = "LIQUIDATE"
+	DelistModeFreeze    = "FREEZE"
 )
 
 type OrderService struct {
-	orderRepo      *repo.OrderRepository
-	portfolioRepo  *repo.PortfolioRepository
+	orderRepo      repo.OrderRepository
+	portfolioRepo  repo.PortfolioRepository
 	walletService  *WalletService
 	stockService   *StockService
+	kyc            *KYCService
+	audit          *AuditService
+	logger         *slog.Logger
 	mu             sync.Mutex
 }
 
+// NewOrderService takes the KYC service, since only verified users can trade
+// and every order counts against the daily limit of their tier. Every order
+// is written to the audit log.
 func NewOrderService(
-	orderRepo *repo.OrderRepository,
-	portfolioRepo *repo.PortfolioRepository,
+	orderRepo repo.OrderRepository,
+	portfolioRepo repo.PortfolioRepository,
 	walletService *WalletService,
 	stockService *StockService,
+	kyc *KYCService,
+	audit *AuditService,
+	logger *slog.Logger,
 ) *OrderService {
 	return &OrderService{
 		orderRepo:     orderRepo,
 		portfolioRepo: portfolioRepo,
 		walletService: walletService,
 		stockService:  stockService,
+		kyc:           kyc,
+		audit:         audit,
+		logger:        logger,
 	}
 }
 
 
-func (s *OrderService) Buy(userID primitive.ObjectID, symbol string, quantity int) (*models.Order, error) {
+func (s *OrderService) Buy(ctx context.Context, userID primitive.ObjectID, symbol string, quantity int) (_ *models.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderService.Buy",
+		attribute.String("user.id", userID.Hex()),
+		attribute.String("stock.symbol", symbol),
+		attribute.Int("order.quantity", quantity),
+	)
+	defer endSpan(span, &err)
+
+	metrics.OrdersInFlight.Inc()
+	defer metrics.OrdersInFlight.Dec()
+
+	order, err := s.buy(ctx, userID, symbol, quantity)
+	s.record(ctx, "buy", symbol, quantity, order, err)
+	return order, err
+}
+
+func (s *OrderService) Sell(ctx context.Context, userID primitive.ObjectID, symbol string, quantity int) (_ *models.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderService.Sell",
+		attribute.String("user.id", userID.Hex()),
+		attribute.String("stock.symbol", symbol),
+		attribute.Int("order.quantity", quantity),
+	)
+	defer endSpan(span, &err)
+
+	metrics.OrdersInFlight.Inc()
+	defer metrics.OrdersInFlight.Dec()
+
+	order, err := s.sell(ctx, userID, symbol, quantity)
+	s.record(ctx, "sell", symbol, quantity, order, err)
+	return order, err
+}
+
+func (s *OrderService) buy(ctx context.Context, userID primitive.ObjectID, symbol string, quantity int) (*models.Order, error) {
 
 	if quantity <= 0 {
-		return nil, errors.New("quantity must be greater than zero")
+		return nil, invalid("quantity", "quantity must be greater than zero")
 	}
 
 	symbol = strings.ToUpper(symbol)
 
 	// Lock to prevent race conditions during buy
-	s.mu.Lock()
+	s.lock(ctx)
 	defer s.mu.Unlock()
 
 	//  Check stock exists
-	stock, err := s.stockService.GetStockBySymbol(symbol)
+	stock, err := s.stockService.GetStockBySymbol(ctx, symbol)
 	if err != nil {
-		return nil, errors.New("stock not found")
+		return nil, err
+	}
+
+	if err := checkTradeable(stock); err != nil {
+		return nil, err
 	}
 
 	totalCost := float64(quantity) * stock.Price
 
+	release, err := s.kyc.reserveOrder(ctx, userID, totalCost)
+	if err != nil {
+		return nil, err
+	}
+
 	//  Deduct wallet balance
-	err = s.walletService.Withdraw(userID, totalCost)
+	err = s.walletService.debit(ctx, userID, totalCost, tradeChange)
 	if err != nil {
+		release()
 		return nil, err
 	}
 
-	//  Update portfolio
-	fmt.Println("Calling UpsertPortfolio")
+	// The wallet is debited: finish the order even if the client goes away.
+	// An order that fails from here on is undone and gives its notional back.
+	ctx = context.WithoutCancel(ctx)
 
-	err = s.portfolioRepo.UpsertPortfolio(userID, symbol, quantity)
+	//  Update portfolio
+	err = s.portfolioRepo.UpsertPortfolio(ctx, userID, symbol, quantity)
 	if err != nil {
+		s.undo(ctx, userID, symbol, -totalCost, 0)
+		release()
 		return nil, err
 	}
 
@@ -77,52 +145,73 @@ func (s *OrderService) Buy(userID primitive.ObjectID, symbol string, quantity in
 		Price:    stock.Price,
 	}
 
-	err = s.orderRepo.CreateOrder(order)
+	err = s.orderRepo.CreateOrder(ctx, order)
 	if err != nil {
+		s.undo(ctx, userID, symbol, -totalCost, quantity)
+		release()
 		return nil, err
 	}
 
+	// The order is filled, so it stands even without its audit entry
+	s.audit.Record(ctx, models.AuditOrderBuy, models.AuditTargetOrder, order.ID.Hex(), nil, order)
+
 	return order, nil
 }
 
-func (s *OrderService) Sell(userID primitive.ObjectID, symbol string, quantity int) (*models.Order, error) {
+func (s *OrderService) sell(ctx context.Context, userID primitive.ObjectID, symbol string, quantity int) (*models.Order, error) {
 
 	if quantity <= 0 {
-		return nil, errors.New("quantity must be greater than zero")
+		return nil, invalid("quantity", "quantity must be greater than zero")
 	}
 
 	symbol = strings.ToUpper(symbol)
 
-	s.mu.Lock()
+	s.lock(ctx)
 	defer s.mu.Unlock()
 
 	// Check stock exists
-	stock, err := s.stockService.GetStockBySymbol(symbol)
+	stock, err := s.stockService.GetStockBySymbol(ctx, symbol)
 	if err != nil {
-		return nil, errors.New("stock not found")
+		return nil, err
+	}
+
+	if err := checkTradeable(stock); err != nil {
+		return nil, err
 	}
 
 	//  Check portfolio
-	portfolio, err := s.portfolioRepo.GetPortfolio(userID, symbol)
+	portfolio, err := s.portfolioRepo.GetPortfolio(ctx, userID, symbol)
 	if err != nil {
-		return nil, errors.New("stock not owned")
+		return nil, notFound(err, ErrStockNotOwned)
 	}
 
 	if portfolio.Qty < quantity {
-		return nil, errors.New("insufficient stock quantity")
+		return nil, ErrInsufficientQuantity
 	}
 
 	totalAmount := float64(quantity) * stock.Price
 
+	release, err := s.kyc.reserveOrder(ctx, userID, totalAmount)
+	if err != nil {
+		return nil, err
+	}
+
 	//  Add money to wallet
-	err = s.walletService.Deposit(userID, totalAmount)
+	err = s.walletService.credit(ctx, userID, totalAmount, tradeChange)
 	if err != nil {
+		release()
 		return nil, err
 	}
 
+	// The wallet is credited: finish the order even if the client goes away.
+	// An order that fails from here on is undone and gives its notional back.
+	ctx = context.WithoutCancel(ctx)
+
 	//  Reduce portfolio quantity
-	err = s.portfolioRepo.UpsertPortfolio(userID, symbol, -quantity)
+	err = s.portfolioRepo.UpsertPortfolio(ctx, userID, symbol, -quantity)
 	if err != nil {
+		s.undo(ctx, userID, symbol, totalAmount, 0)
+		release()
 		return nil, err
 	}
 
@@ -135,11 +224,216 @@ func (s *OrderService) Sell(userID primitive.ObjectID, symbol string, quantity i
 		Price:    stock.Price,
 	}
 
-	err = s.orderRepo.CreateOrder(order)
+	err = s.orderRepo.CreateOrder(ctx, order)
 	if err != nil {
+		s.undo(ctx, userID, symbol, totalAmount, -quantity)
+		release()
 		return nil, err
 	}
 
+	// The order is filled, so it stands even without its audit entry
+	s.audit.Record(ctx, models.AuditOrderSell, models.AuditTargetOrder, order.ID.Hex(), nil, order)
+
 	return order, nil
 }
 
+// undo reverses what an order did before it failed: the wallet change of cash
+// and, unless it is zero, the portfolio change of shares. A step that cannot
+// be reversed is logged for an operator to correct by hand.
+func (s *OrderService) undo(ctx context.Context, userID primitive.ObjectID, symbol string, cash float64, shares int) {
+	if shares != 0 {
+		err := s.portfolioRepo.UpsertPortfolio(ctx, userID, symbol, -shares)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed order left its holding changed",
+				"user_id", userID.Hex(), "symbol", symbol, "shares", shares, "error", err)
+		}
+	}
+
+	var err error
+	if cash < 0 {
+		err = s.walletService.credit(ctx, userID, -cash, reversalChange)
+	} else {
+		err = s.walletService.debit(ctx, userID, cash, reversalChange)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed order left its wallet changed",
+			"user_id", userID.Hex(), "symbol", symbol, "amount", cash, "error", err)
+	}
+}
+
+type DelistResult struct {
+	Symbol        string  `json:"symbol"`
+	Mode          string  `json:"mode"`
+	FinalPrice    float64 `json:"finalPrice"`
+	Holders       int     `json:"holders"`
+	SettledAmount float64 `json:"settledAmount"`
+}
+
+// DelistStock retires a stock at a final price. Remaining holdings are either
+// sold back to their owners' wallets at that price or frozen in place.
+func (s *OrderService) DelistStock(ctx context.Context, symbol string, finalPrice float64, mode string) (_ *DelistResult, err error) {
+	ctx, span := startSpan(ctx, "OrderService.DelistStock",
+		attribute.String("stock.symbol", symbol),
+		attribute.String("delist.mode", mode),
+	)
+	defer endSpan(span, &err)
+
+	if finalPrice <= 0 {
+		return nil, invalid("finalPrice", "final price must be greater than zero")
+	}
+
+	mode = strings.ToUpper(mode)
+	if mode != DelistModeLiquidate && mode != DelistModeFreeze {
+		return nil, invalid("mode", "mode must be LIQUIDATE or FREEZE")
+	}
+
+	symbol = strings.ToUpper(symbol)
+
+	s.lock(ctx)
+	defer s.mu.Unlock()
+
+	stock, err := s.stockService.GetStockBySymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if stock.CurrentStatus() == models.StockStatusDelisted {
+		return nil, ErrStockDelisted
+	}
+
+	// Halt first so a failed settlement can be retried without new trades
+	_, err = s.stockService.SetStatus(ctx, symbol, models.StockStatusHalted)
+	if err != nil {
+		return nil, err
+	}
+
+	// Settle every holder even if the client goes away mid-way
+	ctx = context.WithoutCancel(ctx)
+
+	holdings, err := s.portfolioRepo.GetHoldingsBySymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DelistResult{
+		Symbol:     symbol,
+		Mode:       mode,
+		FinalPrice: finalPrice,
+		Holders:    len(holdings),
+	}
+
+	if mode == DelistModeFreeze {
+		_, err = s.portfolioRepo.FreezeHoldings(ctx, symbol)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Move every holding to settling first. A settling holding is paid
+		// for by this call or, after a failure, by the retry.
+		for _, h := range holdings {
+			err = s.portfolioRepo.StartSettlement(ctx, h.UserID, symbol, h.Qty)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		settling, err := s.portfolioRepo.GetSettlementsBySymbol(ctx, symbol)
+		if err != nil {
+			return nil, err
+		}
+		result.Holders = len(settling)
+
+		for _, h := range settling {
+			paid, err := s.settle(ctx, h, finalPrice)
+			if err != nil {
+				return nil, err
+			}
+			result.SettledAmount += paid
+		}
+	}
+
+	err = s.stockService.markDelisted(ctx, symbol, finalPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "stock delisted",
+		"symbol", symbol, "mode", mode, "final_price", finalPrice,
+		"holders", result.Holders, "settled_amount", result.SettledAmount)
+
+	return result, nil
+}
+
+// settle pays for a holding's settling shares and records the sale. Each
+// step is idempotent, so a retry after a failure picks up where it stopped;
+// it returns what this call paid.
+func (s *OrderService) settle(ctx context.Context, h models.Portfolio, price float64) (float64, error) {
+	amount := float64(h.Settling) * price
+
+	paid, err := s.walletService.settle(ctx, h.UserID, "delist:"+h.Symbol, amount)
+	if err != nil {
+		return 0, err
+	}
+	if !paid {
+		amount = 0
+	}
+
+	// The holding's id is the order's, so a retry cannot record it twice
+	order := &models.Order{
+		ID:       h.ID,
+		UserID:   h.UserID,
+		Symbol:   h.Symbol,
+		Type:     "SELL",
+		Quantity: h.Settling,
+		Price:    price,
+	}
+
+	err = s.orderRepo.CreateOrder(ctx, order)
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+	case err != nil:
+		return amount, err
+	default:
+		s.audit.Record(ctx, models.AuditOrderSell, models.AuditTargetOrder, order.ID.Hex(), nil, order)
+	}
+
+	return amount, s.portfolioRepo.FinishSettlement(ctx, h.UserID, h.Symbol)
+}
+
+// record counts and logs the result of an order
+func (s *OrderService) record(ctx context.Context, side, symbol string, quantity int, order *models.Order, err error) {
+	result := outcome(err)
+	metrics.Trades.WithLabelValues(side, result).Inc()
+
+	switch result {
+	case metrics.OutcomeSuccess:
+		s.logger.InfoContext(ctx, "order filled",
+			"order_id", order.ID.Hex(), "side", side, "symbol", order.Symbol,
+			"quantity", order.Quantity, "price", order.Price)
+	case metrics.OutcomeRejected:
+		s.logger.InfoContext(ctx, "order rejected",
+			"side", side, "symbol", symbol, "quantity", quantity, "reason", err.Error())
+	default:
+		s.logger.ErrorContext(ctx, "order failed",
+			"side", side, "symbol", symbol, "quantity", quantity, "error", err)
+	}
+}
+
+// lock acquires mu, recording how long it waited
+func (s *OrderService) lock(ctx context.Context) {
+	_, span := startSpan(ctx, "OrderService.mu wait")
+	start := time.Now()
+	s.mu.Lock()
+	span.End()
+	metrics.ObserveLockWait("OrderService.mu", start)
+}
+
+func checkTradeable(stock *models.Stock) error {
+	switch stock.CurrentStatus() {
+	case models.StockStatusHalted:
+		return ErrStockHalted
+	case models.StockStatusDelisted:
+		return ErrStockDelisted
+	}
+	return nil
+}
@@ -0,0 +1,607 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"concurrent-wallet-order-system/internal/metrics"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBuyDebitsWalletAndCreditsPortfolio(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 150)
+	userID := env.createUser(t, 1000)
+
+	order, err := env.orderService.Buy(t.Context(), userID, "aapl", 4)
+	if err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	if order.Symbol != "AAPL" || order.Type != "BUY" || order.Quantity != 4 || order.Price != 150 {
+		t.Errorf("order = %+v", order)
+	}
+
+	if got := env.balance(t, userID); got != 400 {
+		t.Errorf("balance = %v, want 400", got)
+	}
+
+	if got := env.quantity(t, userID, "AAPL"); got != 4 {
+		t.Errorf("quantity = %d, want 4", got)
+	}
+
+	if got := len(env.orders.Orders()); got != 1 {
+		t.Errorf("%d orders recorded, want 1", got)
+	}
+}
+
+func TestBuyFailures(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 150)
+	env.createStock(t, "HALT", 10)
+	if _, err := env.stockService.SetStatus(t.Context(), "HALT", models.StockStatusHalted); err != nil {
+		t.Fatalf("halt: %v", err)
+	}
+	userID := env.createUser(t, 100)
+
+	tests := []struct {
+		name     string
+		symbol   string
+		quantity int
+		want     string
+	}{
+		{"zero quantity", "AAPL", 0, "quantity must be greater than zero"},
+		{"unknown stock", "NOPE", 1, "stock not found"},
+		{"insufficient balance", "AAPL", 1, "insufficient balance"},
+		{"halted stock", "HALT", 1, "trading is halted for this stock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.orderService.Buy(t.Context(), userID, tt.symbol, tt.quantity)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if got := env.balance(t, userID); got != 100 {
+		t.Errorf("balance = %v, want 100 after failed buys", got)
+	}
+
+	if got := len(env.orders.Orders()); got != 0 {
+		t.Errorf("%d orders recorded, want 0", got)
+	}
+}
+
+func TestSellCreditsWalletAndReducesPortfolio(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 100)
+	userID := env.createUser(t, 500)
+
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 5); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	order, err := env.orderService.Sell(t.Context(), userID, "AAPL", 2)
+	if err != nil {
+		t.Fatalf("sell: %v", err)
+	}
+
+	if order.Type != "SELL" || order.Quantity != 2 {
+		t.Errorf("order = %+v", order)
+	}
+
+	if got := env.balance(t, userID); got != 200 {
+		t.Errorf("balance = %v, want 200", got)
+	}
+
+	if got := env.quantity(t, userID, "AAPL"); got != 3 {
+		t.Errorf("quantity = %d, want 3", got)
+	}
+}
+
+func TestSellFailures(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 100)
+	env.createStock(t, "MSFT", 100)
+	userID := env.createUser(t, 200)
+
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 2); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		symbol   string
+		quantity int
+		want     string
+	}{
+		{"negative quantity", "AAPL", -1, "quantity must be greater than zero"},
+		{"unknown stock", "NOPE", 1, "stock not found"},
+		{"not owned", "MSFT", 1, "stock not owned"},
+		{"more than owned", "AAPL", 3, "insufficient stock quantity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.orderService.Sell(t.Context(), userID, tt.symbol, tt.quantity)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if got := env.quantity(t, userID, "AAPL"); got != 2 {
+		t.Errorf("quantity = %d, want 2 after failed sells", got)
+	}
+}
+
+func TestConcurrentBuysNeverOverspend(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 10)
+	userID := env.createUser(t, 1000)
+
+	const workers = 200
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			env.orderService.Buy(t.Context(), userID, "AAPL", 1)
+		}()
+	}
+	wg.Wait()
+
+	if got := env.balance(t, userID); got != 0 {
+		t.Errorf("balance = %v, want 0", got)
+	}
+
+	if got := env.quantity(t, userID, "AAPL"); got != 100 {
+		t.Errorf("quantity = %d, want 100", got)
+	}
+
+	if got := len(env.orders.Orders()); got != 100 {
+		t.Errorf("%d orders recorded, want 100", got)
+	}
+}
+
+func TestConcurrentBuysAndSellsStayConsistent(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 10)
+	userID := env.createUser(t, 500)
+
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 25); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	// At a constant price, cash plus position value never changes
+	const total = 500.0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			env.orderService.Buy(t.Context(), userID, "AAPL", 3)
+		}()
+		go func() {
+			defer wg.Done()
+			env.orderService.Sell(t.Context(), userID, "AAPL", 2)
+		}()
+	}
+	wg.Wait()
+
+	balance := env.balance(t, userID)
+	quantity := env.quantity(t, userID, "AAPL")
+
+	if balance < 0 || quantity < 0 {
+		t.Fatalf("balance = %v, quantity = %d, want both non-negative", balance, quantity)
+	}
+
+	if got := balance + float64(quantity)*10; got != total {
+		t.Errorf("balance + holdings = %v, want %v", got, total)
+	}
+}
+
+func TestDelistLiquidatesHoldings(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "OLD", 10)
+	userID := env.createUser(t, 100)
+
+	if _, err := env.orderService.Buy(t.Context(), userID, "OLD", 10); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	result, err := env.orderService.DelistStock(t.Context(), "OLD", 4, DelistModeLiquidate)
+	if err != nil {
+		t.Fatalf("delist: %v", err)
+	}
+
+	if result.Holders != 1 || result.SettledAmount != 40 {
+		t.Errorf("result = %+v, want 1 holder settled for 40", result)
+	}
+
+	if got := env.balance(t, userID); got != 40 {
+		t.Errorf("balance = %v, want 40", got)
+	}
+
+	if got := env.quantity(t, userID, "OLD"); got != 0 {
+		t.Errorf("quantity = %d, want 0", got)
+	}
+
+	if _, err := env.orderService.Buy(t.Context(), userID, "OLD", 1); err == nil || err.Error() != "stock is delisted" {
+		t.Errorf("buy after delisting: err = %v, want stock is delisted", err)
+	}
+}
+
+// failAt returns a check that fails its nth call
+func failAt(n int) func() error {
+	return func() error {
+		if n--; n == 0 {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+}
+
+// never is a check that always passes
+func never() error { return nil }
+
+type failingPortfolioRepo struct {
+	repo.PortfolioRepository
+	start, finish func() error
+}
+
+func (r failingPortfolioRepo) StartSettlement(ctx context.Context, userID primitive.ObjectID, symbol string, qty int) error {
+	if err := r.start(); err != nil {
+		return err
+	}
+	return r.PortfolioRepository.StartSettlement(ctx, userID, symbol, qty)
+}
+
+func (r failingPortfolioRepo) FinishSettlement(ctx context.Context, userID primitive.ObjectID, symbol string) error {
+	if err := r.finish(); err != nil {
+		return err
+	}
+	return r.PortfolioRepository.FinishSettlement(ctx, userID, symbol)
+}
+
+type failingUserRepo struct {
+	repo.UserRepository
+	fail func() error
+}
+
+func (r failingUserRepo) CreditOnce(ctx context.Context, userID primitive.ObjectID, key string, amount float64) (bool, error) {
+	if err := r.fail(); err != nil {
+		return false, err
+	}
+	return r.UserRepository.CreditOnce(ctx, userID, key, amount)
+}
+
+// failingUpsertRepo fails the order's own UpsertPortfolio call when fail
+// says so; the undo that follows goes through
+type failingUpsertRepo struct {
+	repo.PortfolioRepository
+	fail func() error
+}
+
+func (r failingUpsertRepo) UpsertPortfolio(ctx context.Context, userID primitive.ObjectID, symbol string, qty int) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.PortfolioRepository.UpsertPortfolio(ctx, userID, symbol, qty)
+}
+
+type failingOrderRepo struct {
+	repo.OrderRepository
+	fail func() error
+}
+
+func (r failingOrderRepo) CreateOrder(ctx context.Context, order *models.Order) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.OrderRepository.CreateOrder(ctx, order)
+}
+
+type failingAuditRepo struct {
+	repo.AuditRepository
+}
+
+func (failingAuditRepo) InsertAuditEntry(ctx context.Context, e *models.AuditEntry) error {
+	return errors.New("connection reset")
+}
+
+func TestDelistRetryAfterFailureSettlesEachHolderOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		inject func(env *testEnv)
+	}{
+		{"holding not taken", func(env *testEnv) {
+			env.orderService.portfolioRepo = failingPortfolioRepo{env.portfolio, failAt(2), never}
+		}},
+		{"holder not paid", func(env *testEnv) {
+			env.walletService.userRepo = failingUserRepo{env.users, failAt(2)}
+		}},
+		{"order not recorded", func(env *testEnv) {
+			env.orderService.orderRepo = failingOrderRepo{env.orders, failAt(2)}
+		}},
+		{"settlement not finished", func(env *testEnv) {
+			env.orderService.portfolioRepo = failingPortfolioRepo{env.portfolio, never, failAt(2)}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.createStock(t, "OLD", 10)
+
+			quantities := []int{1, 2, 3}
+			var holders []primitive.ObjectID
+			for _, qty := range quantities {
+				userID := env.createUser(t, 100)
+				if _, err := env.orderService.Buy(t.Context(), userID, "OLD", qty); err != nil {
+					t.Fatalf("buy: %v", err)
+				}
+				holders = append(holders, userID)
+			}
+
+			// The second holder's settlement fails
+			tt.inject(env)
+			if _, err := env.orderService.DelistStock(t.Context(), "OLD", 4, DelistModeLiquidate); err == nil {
+				t.Fatal("delist succeeded despite the failure")
+			}
+
+			stock, err := env.stockService.GetStockBySymbol(t.Context(), "OLD")
+			if err != nil {
+				t.Fatalf("get stock: %v", err)
+			}
+			if stock.CurrentStatus() != models.StockStatusHalted {
+				t.Fatalf("status after the failure = %s, want HALTED", stock.CurrentStatus())
+			}
+
+			if _, err := env.orderService.DelistStock(t.Context(), "OLD", 4, DelistModeLiquidate); err != nil {
+				t.Fatalf("retry: %v", err)
+			}
+
+			for i, userID := range holders {
+				want := 100 - float64(quantities[i])*10 + float64(quantities[i])*4
+				if got := env.balance(t, userID); got != want {
+					t.Errorf("holder %d balance = %v, want %v", i, got, want)
+				}
+				if got := env.quantity(t, userID, "OLD"); got != 0 {
+					t.Errorf("holder %d quantity = %d, want 0", i, got)
+				}
+
+				var sales []models.Order
+				for _, o := range env.orders.Orders() {
+					if o.UserID == userID && o.Type == "SELL" {
+						sales = append(sales, o)
+					}
+				}
+				if len(sales) != 1 || sales[0].Quantity != quantities[i] || sales[0].Price != 4 {
+					t.Errorf("holder %d sales = %+v, want one of %d at 4", i, sales, quantities[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFailedOrderGivesItsNotionalBack(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 100)
+	userID := env.createUser(t, 1000)
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 5); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	// Both orders fail after the wallet has moved
+	env.orderService.orderRepo = failingOrderRepo{env.orders, func() error { return errors.New("connection reset") }}
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 1); err == nil {
+		t.Fatal("buy succeeded despite the failure")
+	}
+	if _, err := env.orderService.Sell(t.Context(), userID, "AAPL", 2); err == nil {
+		t.Fatal("sell succeeded despite the failure")
+	}
+
+	status, err := env.kycService.Status(t.Context(), userID)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if got := status.UsedToday.Order; got != 500 {
+		t.Errorf("order notional used = %v, want 500", got)
+	}
+}
+
+func TestFailedOrderIsUndone(t *testing.T) {
+	// Each order gets fresh checks
+	tests := []struct {
+		name      string
+		portfolio func() func() error
+		order     func() func() error
+	}{
+		{"holding not updated", func() func() error { return failAt(1) }, func() func() error { return never }},
+		{"order not recorded", func() func() error { return never }, func() func() error { return failAt(1) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.createStock(t, "AAPL", 100)
+			userID := env.createUser(t, 1000)
+			if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 5); err != nil {
+				t.Fatalf("buy: %v", err)
+			}
+
+			env.orderService.portfolioRepo = failingUpsertRepo{env.portfolio, tt.portfolio()}
+			env.orderService.orderRepo = failingOrderRepo{env.orders, tt.order()}
+			if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 1); err == nil {
+				t.Fatal("buy succeeded despite the failure")
+			}
+
+			env.orderService.portfolioRepo = failingUpsertRepo{env.portfolio, tt.portfolio()}
+			env.orderService.orderRepo = failingOrderRepo{env.orders, tt.order()}
+			if _, err := env.orderService.Sell(t.Context(), userID, "AAPL", 2); err == nil {
+				t.Fatal("sell succeeded despite the failure")
+			}
+
+			if got := env.balance(t, userID); got != 500 {
+				t.Errorf("balance = %v, want 500", got)
+			}
+			if got := env.quantity(t, userID, "AAPL"); got != 5 {
+				t.Errorf("quantity = %d, want 5", got)
+			}
+		})
+	}
+}
+
+func TestCommittedChangesStandWithoutTheirAuditEntry(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 100)
+	env.createStock(t, "OLD", 10)
+	userID := env.createUser(t, 1000)
+	if _, err := env.orderService.Buy(t.Context(), userID, "OLD", 2); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	// A client that saw an error would retry, trading twice
+	env.auditService.auditRepo = failingAuditRepo{env.auditService.auditRepo}
+
+	if err := env.walletService.Deposit(t.Context(), userID, 100); err != nil {
+		t.Errorf("deposit: %v", err)
+	}
+	if err := env.walletService.Withdraw(t.Context(), userID, 50, ""); err != nil {
+		t.Errorf("withdraw: %v", err)
+	}
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 3); err != nil {
+		t.Errorf("buy: %v", err)
+	}
+	if _, err := env.orderService.Sell(t.Context(), userID, "AAPL", 1); err != nil {
+		t.Errorf("sell: %v", err)
+	}
+	if _, err := env.orderService.DelistStock(t.Context(), "OLD", 5, DelistModeLiquidate); err != nil {
+		t.Errorf("delist: %v", err)
+	}
+
+	if got, want := env.balance(t, userID), 1000.0-20+100-50-300+100+10; got != want {
+		t.Errorf("balance = %v, want %v", got, want)
+	}
+	if got := env.quantity(t, userID, "AAPL"); got != 2 {
+		t.Errorf("quantity = %d, want 2", got)
+	}
+}
+
+func TestDelistFreezesHoldings(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "OLD", 10)
+	userID := env.createUser(t, 100)
+
+	if _, err := env.orderService.Buy(t.Context(), userID, "OLD", 10); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	if _, err := env.orderService.DelistStock(t.Context(), "OLD", 4, DelistModeFreeze); err != nil {
+		t.Fatalf("delist: %v", err)
+	}
+
+	p, err := env.portfolio.GetPortfolio(t.Context(), userID, "OLD")
+	if err != nil {
+		t.Fatalf("get portfolio: %v", err)
+	}
+
+	if !p.Frozen || p.Qty != 10 {
+		t.Errorf("holding = %+v, want 10 frozen shares", p)
+	}
+
+	if got := env.balance(t, userID); got != 0 {
+		t.Errorf("balance = %v, want 0", got)
+	}
+}
+
+func TestTradeMetrics(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 10)
+	userID := env.createUser(t, 50)
+
+	buys := func(outcome string) float64 {
+		return testutil.ToFloat64(metrics.Trades.WithLabelValues("buy", outcome))
+	}
+	deposits := func() float64 {
+		return testutil.ToFloat64(metrics.WalletOperations.WithLabelValues("deposit", metrics.OutcomeSuccess))
+	}
+
+	success, rejectedBuys, depositsBefore := buys(metrics.OutcomeSuccess), buys(metrics.OutcomeRejected), deposits()
+
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 2); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 100); err == nil {
+		t.Fatal("buy beyond balance succeeded")
+	}
+	if _, err := env.orderService.Sell(t.Context(), userID, "AAPL", 1); err != nil {
+		t.Fatalf("sell: %v", err)
+	}
+
+	if got := buys(metrics.OutcomeSuccess) - success; got != 1 {
+		t.Errorf("successful buys = %v, want 1", got)
+	}
+	if got := buys(metrics.OutcomeRejected) - rejectedBuys; got != 1 {
+		t.Errorf("rejected buys = %v, want 1", got)
+	}
+	if got := deposits() - depositsBefore; got != 0 {
+		t.Errorf("sale proceeds counted as %v deposits", got)
+	}
+	if got := testutil.ToFloat64(metrics.OrdersInFlight); got != 0 {
+		t.Errorf("orders in flight = %v after all orders finished", got)
+	}
+}
+
+func TestBuySpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 10)
+	userID := env.createUser(t, 50)
+
+	if _, err := env.orderService.Buy(t.Context(), userID, "AAPL", 2); err != nil {
+		t.Fatalf("buy: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+
+	var buy tracetest.SpanStub
+	for _, s := range spans {
+		if s.Name == "OrderService.Buy" {
+			buy = s
+		}
+	}
+	if !buy.SpanContext.IsValid() {
+		t.Fatalf("no OrderService.Buy span in %d spans", len(spans))
+	}
+
+	children := map[string]bool{}
+	for _, s := range spans {
+		if s.Parent.SpanID() == buy.SpanContext.SpanID() {
+			children[s.Name] = true
+		}
+	}
+	for _, want := range []string{"OrderService.mu wait", "StockService.GetStockBySymbol", "WalletService.debit"} {
+		if !children[want] {
+			t.Errorf("OrderService.Buy has no %q child span; children: %v", want, children)
+		}
+	}
+}
@@ -1,23 +1,32 @@
 package services
 
 import (
+	"context"
+	"log/slog"
+
 	"concurrent-wallet-order-system/internal/repo"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type PortfolioService struct {
-	portfolioRepo *repo.PortfolioRepository
+	userRepo      repo.UserRepository
+	portfolioRepo repo.PortfolioRepository
 	stockService  *StockService
+	logger        *slog.Logger
 }
 
 func NewPortfolioService(
-	portfolioRepo *repo.PortfolioRepository,
+	userRepo repo.UserRepository,
+	portfolioRepo repo.PortfolioRepository,
 	stockService *StockService,
+	logger *slog.Logger,
 ) *PortfolioService {
 	return &PortfolioService{
+		userRepo:      userRepo,
 		portfolioRepo: portfolioRepo,
 		stockService:  stockService,
+		logger:        logger,
 	}
 }
 
@@ -27,6 +36,8 @@ type HoldingResponse struct {
 	Quantity     int     `json:"quantity"`
 	CurrentPrice float64 `json:"currentPrice"`
 	TotalValue   float64 `json:"totalValue"`
+	Status       string  `json:"status"`
+	Frozen       bool    `json:"frozen,omitempty"`
 }
 
 type PortfolioResponse struct {
@@ -35,9 +46,17 @@ type PortfolioResponse struct {
 	TotalPortfolioValue float64            `json:"totalPortfolioValue"`
 }
 
-func (s *PortfolioService) GetPortfolio(userID primitive.ObjectID) (*PortfolioResponse, error) {
+// GetPortfolio values the user's holdings at current prices. Anyone but the
+// user needs the admin role.
+func (s *PortfolioService) GetPortfolio(ctx context.Context, callerID, userID primitive.ObjectID) (_ *PortfolioResponse, err error) {
+	ctx, span := startSpan(ctx, "PortfolioService.GetPortfolio")
+	defer endSpan(span, &err)
+
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, userID); err != nil {
+		return nil, err
+	}
 
-	holdings, err := s.portfolioRepo.GetUserPortfolio(userID)
+	holdings, err := s.portfolioRepo.GetUserPortfolio(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -49,8 +68,9 @@ func (s *PortfolioService) GetPortfolio(userID primitive.ObjectID) (*PortfolioRe
 
 	for _, h := range holdings {
 
-		stock, err := s.stockService.GetStockBySymbol(h.Symbol)
+		stock, err := s.stockService.GetStockBySymbol(ctx, h.Symbol)
 		if err != nil {
+			s.logger.WarnContext(ctx, "skipping holding without a stock", "symbol", h.Symbol, "error", err)
 			continue
 		}
 
@@ -62,6 +82,8 @@ func (s *PortfolioService) GetPortfolio(userID primitive.ObjectID) (*PortfolioRe
 			Quantity:     h.Qty,
 			CurrentPrice: stock.Price,
 			TotalValue:   value,
+			Status:       stock.CurrentStatus(),
+			Frozen:       h.Frozen,
 		})
 
 		totalValue += value
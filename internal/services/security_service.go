@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/metrics"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LoginPolicy decides how failed logins are throttled. Failures count
+// within Window of each other; each one delays the next attempt on the
+// account, doubling from BaseDelay up to MaxDelay, and MaxFailures of them
+// lock the account for Lockout. Client IPs are locked after MaxIPFailures
+// across all accounts, without delays, since many users may share an IP.
+type LoginPolicy struct {
+	MaxFailures   int
+	MaxIPFailures int
+	Window        time.Duration
+	Lockout       time.Duration
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+}
+
+// SecurityService protects logins against brute force and keeps the
+// security log
+type SecurityService struct {
+	attempts repo.LoginAttemptRepository
+	events   repo.SecurityEventRepository
+	policy   LoginPolicy
+	now      func() time.Time
+	logger   *slog.Logger
+}
+
+func NewSecurityService(attempts repo.LoginAttemptRepository, events repo.SecurityEventRepository, policy LoginPolicy, logger *slog.Logger) *SecurityService {
+	return &SecurityService{
+		attempts: attempts,
+		events:   events,
+		policy:   policy,
+		now:      time.Now,
+		logger:   logger,
+	}
+}
+
+// Failures are tracked by email rather than user ID so that unknown emails
+// are throttled like real accounts and do not reveal which ones exist
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// delay is how long to wait after the given number of consecutive failures
+func (p LoginPolicy) delay(failures int) time.Duration {
+	if failures <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+// CheckLogin refuses a login attempt while the account or IP is locked, or
+// before the delay after the last failure has passed
+func (s *SecurityService) CheckLogin(ctx context.Context, email, ip string) (err error) {
+	ctx, span := startSpan(ctx, "SecurityService.CheckLogin")
+	defer endSpan(span, &err)
+
+	now := s.now()
+
+	if ip != "" {
+		f, err := s.failures(ctx, ipKey(ip))
+		if err != nil {
+			return err
+		}
+		if f != nil && now.Before(f.LockedUntil) {
+			return retryAfter(ErrLoginBlocked, f.LockedUntil.Sub(now))
+		}
+	}
+
+	f, err := s.failures(ctx, accountKey(email))
+	if err != nil || f == nil {
+		return err
+	}
+	if now.Before(f.LockedUntil) {
+		return retryAfter(ErrAccountLocked, f.LockedUntil.Sub(now))
+	}
+	if next := f.LastFailure.Add(s.policy.delay(f.Count)); f.LastFailure.After(now.Add(-s.policy.Window)) && now.Before(next) {
+		return retryAfter(ErrLoginThrottled, next.Sub(now))
+	}
+
+	return nil
+}
+
+// failures returns the failures recorded under key, or nil if there are none
+func (s *SecurityService) failures(ctx context.Context, key string) (*models.LoginFailures, error) {
+	f, err := s.attempts.GetLoginFailures(ctx, key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return f, err
+}
+
+// LoginFailed records a failed login and locks the account or IP once it
+// reaches its limit. It returns the error to give the client: an
+// account_locked error for the failure that locked it, nil otherwise.
+// userID is nil when no account has the email.
+func (s *SecurityService) LoginFailed(ctx context.Context, email, ip string, userID *primitive.ObjectID) (err error) {
+	ctx, span := startSpan(ctx, "SecurityService.LoginFailed")
+	defer endSpan(span, &err)
+
+	now := s.now()
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if ip != "" {
+		f, err := s.attempts.RecordLoginFailure(ctx, ipKey(ip), now, s.policy.Window)
+		if err != nil {
+			return err
+		}
+		if f.Count >= s.policy.MaxIPFailures && !now.Before(f.LockedUntil) {
+			until := now.Add(s.policy.Lockout)
+			if err := s.attempts.LockLogin(ctx, ipKey(ip), until); err != nil {
+				return err
+			}
+			s.logger.WarnContext(ctx, "client IP locked out of login", "client_ip", ip, "failures", f.Count, "until", until)
+			s.record(ctx, &models.SecurityEvent{Type: models.SecurityEventIPLocked, IP: ip, Detail: lockDetail(f.Count, until)})
+		}
+	}
+
+	f, err := s.attempts.RecordLoginFailure(ctx, accountKey(email), now, s.policy.Window)
+	if err != nil {
+		return err
+	}
+	if f.Count < s.policy.MaxFailures || now.Before(f.LockedUntil) {
+		return nil
+	}
+
+	until := now.Add(s.policy.Lockout)
+	if err := s.attempts.LockLogin(ctx, accountKey(email), until); err != nil {
+		return err
+	}
+	s.logger.WarnContext(ctx, "account locked after failed logins", "email", email, "client_ip", ip, "failures", f.Count, "until", until)
+	s.record(ctx, &models.SecurityEvent{
+		Type:   models.SecurityEventAccountLocked,
+		UserID: userID,
+		Email:  email,
+		IP:     ip,
+		Detail: lockDetail(f.Count, until),
+	})
+
+	return retryAfter(ErrAccountLocked, s.policy.Lockout)
+}
+
+func lockDetail(failures int, until time.Time) string {
+	return fmt.Sprintf("locked until %s after %d failed logins", until.UTC().Format(time.RFC3339), failures)
+}
+
+// LoginSucceeded forgets the account's failures. The IP's stay, so that an
+// attacker cannot reset them with a login of their own.
+func (s *SecurityService) LoginSucceeded(ctx context.Context, email string) (err error) {
+	ctx, span := startSpan(ctx, "SecurityService.LoginSucceeded")
+	defer endSpan(span, &err)
+
+	return s.attempts.ClearLoginFailures(ctx, accountKey(email))
+}
+
+// UnlockAccount lifts a lockout and the failure count behind it
+func (s *SecurityService) UnlockAccount(ctx context.Context, user *models.User) (err error) {
+	ctx, span := startSpan(ctx, "SecurityService.UnlockAccount")
+	defer endSpan(span, &err)
+
+	if err := s.attempts.ClearLoginFailures(ctx, accountKey(user.Email)); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "account unlocked", "user_id", user.ID.Hex())
+	s.record(ctx, &models.SecurityEvent{
+		Type:   models.SecurityEventAccountUnlocked,
+		UserID: &user.ID,
+		Email:  strings.ToLower(user.Email),
+		Detail: "unlocked by an administrator",
+	})
+	return nil
+}
+
+// record writes to the security log. A failed write is logged rather than
+// failing the operation that caused the event.
+func (s *SecurityService) record(ctx context.Context, e *models.SecurityEvent) {
+	e.CreatedAt = s.now()
+	if err := s.events.CreateSecurityEvent(ctx, e); err != nil {
+		s.logger.ErrorContext(ctx, "writing security event failed", "type", e.Type, "error", err)
+	}
+}
+
+// ListEvents returns the security log, newest first
+func (s *SecurityService) ListEvents(ctx context.Context, f repo.SecurityEventFilter) (_ []models.SecurityEvent, err error) {
+	ctx, span := startSpan(ctx, "SecurityService.ListEvents")
+	defer endSpan(span, &err)
+
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 100
+	}
+	f.Email = strings.ToLower(strings.TrimSpace(f.Email))
+
+	return s.events.ListSecurityEvents(ctx, f)
+}
+
+// loginResult labels a login attempt for the login_attempts_total metric
+func loginResult(err error) string {
+	var domainErr *Error
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &domainErr):
+		return domainErr.Code
+	default:
+		return metrics.OutcomeError
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/auth"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxUserAgent bounds the user agent stored with a session
+const maxUserAgent = 256
+
+// Tokens are issued on login and on every refresh
+type Tokens struct {
+	SessionID        primitive.ObjectID
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// SessionService issues access and refresh tokens. Each login starts a
+// session whose refresh token is replaced on every use; presenting a token
+// that was already exchanged means it leaked, so the whole session is
+// revoked.
+type SessionService struct {
+	sessions   repo.SessionRepository
+	signer     *auth.Signer
+	security   *SecurityService
+	refreshTTL time.Duration
+	now        func() time.Time
+	logger     *slog.Logger
+}
+
+func NewSessionService(sessions repo.SessionRepository, signer *auth.Signer, security *SecurityService, refreshTTL time.Duration, logger *slog.Logger) *SessionService {
+	return &SessionService{
+		sessions:   sessions,
+		signer:     signer,
+		security:   security,
+		refreshTTL: refreshTTL,
+		now:        time.Now,
+		logger:     logger,
+	}
+}
+
+// Start opens a session for a user who has just logged in
+func (s *SessionService) Start(ctx context.Context, userID primitive.ObjectID, userAgent, ip string) (_ *Tokens, err error) {
+	ctx, span := startSpan(ctx, "SessionService.Start")
+	defer endSpan(span, &err)
+
+	now := s.now()
+	session := &models.Session{
+		UserID:     userID,
+		UserAgent:  truncate(userAgent, maxUserAgent),
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.refreshTTL),
+	}
+
+	// The session ID is part of the refresh token, so it is assigned here
+	// rather than by the repository
+	session.ID = primitive.NewObjectID()
+	refresh := newRefreshToken(session.ID)
+	session.RefreshHash = hashToken(refresh)
+
+	if err := s.sessions.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "session started", "user_id", userID.Hex(), "session_id", session.ID.Hex())
+	return s.issue(session, refresh, now)
+}
+
+// Refresh exchanges a refresh token for new access and refresh tokens
+func (s *SessionService) Refresh(ctx context.Context, refreshToken, userAgent, ip string) (_ *Tokens, err error) {
+	ctx, span := startSpan(ctx, "SessionService.Refresh")
+	defer endSpan(span, &err)
+
+	sessionHex, _, _ := strings.Cut(refreshToken, ".")
+	sessionID, err := primitive.ObjectIDFromHex(sessionHex)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, notFound(err, ErrInvalidRefreshToken)
+	}
+
+	now := s.now()
+	if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	hash := hashToken(refreshToken)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(session.RefreshHash)) != 1 {
+		if slices.Contains(session.PreviousHashes, hash) {
+			return nil, s.reused(ctx, session, ip)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	refresh := newRefreshToken(session.ID)
+	session.ExpiresAt = now.Add(s.refreshTTL)
+	err = s.sessions.RotateSession(ctx, session.ID, hash, hashToken(refresh), ip, truncate(userAgent, maxUserAgent), now, session.ExpiresAt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Exchanged by a concurrent request between the read and the rotation
+		return nil, s.reused(ctx, session, ip)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(session, refresh, now)
+}
+
+// reused revokes a session whose exchanged refresh token came back, since
+// either the client or an attacker holds a stolen copy
+func (s *SessionService) reused(ctx context.Context, session *models.Session, ip string) error {
+	err := s.sessions.RevokeSession(ctx, session.ID, session.UserID, models.SessionRevokedReuse, s.now())
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	s.logger.WarnContext(ctx, "refresh token reused, session revoked", "user_id", session.UserID.Hex(), "session_id", session.ID.Hex(), "client_ip", ip)
+	s.security.record(ctx, &models.SecurityEvent{
+		Type:   models.SecurityEventTokenReused,
+		UserID: &session.UserID,
+		IP:     ip,
+		Detail: "session " + session.ID.Hex() + " revoked",
+	})
+	return ErrRefreshTokenReused
+}
+
+// Logout revokes one of the user's sessions
+func (s *SessionService) Logout(ctx context.Context, userID, sessionID primitive.ObjectID) (err error) {
+	ctx, span := startSpan(ctx, "SessionService.Logout")
+	defer endSpan(span, &err)
+
+	if err := s.sessions.RevokeSession(ctx, sessionID, userID, models.SessionRevokedLogout, s.now()); err != nil {
+		return notFound(err, ErrSessionNotFound)
+	}
+
+	s.logger.InfoContext(ctx, "logged out", "user_id", userID.Hex(), "session_id", sessionID.Hex())
+	return nil
+}
+
+// LogoutAll revokes every session of the user and returns how many there were
+func (s *SessionService) LogoutAll(ctx context.Context, userID primitive.ObjectID) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "SessionService.LogoutAll")
+	defer endSpan(span, &err)
+
+	return s.revokeAll(ctx, userID, models.SessionRevokedLogoutAll)
+}
+
+// revokeAll revokes every session of the user, giving reason
+func (s *SessionService) revokeAll(ctx context.Context, userID primitive.ObjectID, reason string) (int64, error) {
+	revoked, err := s.sessions.RevokeUserSessions(ctx, userID, reason, s.now())
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.InfoContext(ctx, "revoked all sessions", "user_id", userID.Hex(), "sessions", revoked, "reason", reason)
+	return revoked, nil
+}
+
+// ListSessions returns the user's active sessions, most recently used first
+func (s *SessionService) ListSessions(ctx context.Context, userID primitive.ObjectID) (_ []models.Session, err error) {
+	ctx, span := startSpan(ctx, "SessionService.ListSessions")
+	defer endSpan(span, &err)
+
+	return s.sessions.ListActiveSessions(ctx, userID, s.now())
+}
+
+func (s *SessionService) issue(session *models.Session, refresh string, now time.Time) (*Tokens, error) {
+	access, expires, err := s.signer.Issue(session.UserID.Hex(), session.ID.Hex(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		SessionID:        session.ID,
+		AccessToken:      access,
+		AccessExpiresAt:  expires,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// newRefreshToken returns "<session ID>.<128 random bits>"; the prefix lets
+// Refresh find the session without indexing token hashes
+func newRefreshToken(sessionID primitive.ObjectID) string {
+	return sessionID.Hex() + "." + rand.Text()
+}
+
+// hashToken is how refresh tokens are stored: a leaked sessions collection
+// must not let anyone refresh
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
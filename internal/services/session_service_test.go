@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+)
+
+func TestRefreshRotatesToken(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 0)
+
+	first, err := env.sessionService.Start(t.Context(), userID, "phone", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	second, err := env.sessionService.Refresh(t.Context(), first.RefreshToken, "phone", "10.0.0.2")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.SessionID != first.SessionID {
+		t.Errorf("refresh returned %+v, want a new token in the same session", second)
+	}
+
+	if _, err := env.sessionService.Refresh(t.Context(), second.RefreshToken, "phone", "10.0.0.2"); err != nil {
+		t.Fatalf("refresh with the new token: %v", err)
+	}
+
+	sessions, _ := env.sessionService.ListSessions(t.Context(), userID)
+	if len(sessions) != 1 || sessions[0].IP != "10.0.0.2" {
+		t.Errorf("sessions = %+v, want one last used from 10.0.0.2", sessions)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 0)
+
+	stolen, _ := env.sessionService.Start(t.Context(), userID, "laptop", "10.0.0.1")
+	rotated, err := env.sessionService.Refresh(t.Context(), stolen.RefreshToken, "laptop", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	if _, err := env.sessionService.Refresh(t.Context(), stolen.RefreshToken, "curl", "10.6.6.6"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse: err = %v, want ErrRefreshTokenReused", err)
+	}
+
+	// The legitimate holder of the family is logged out too
+	if _, err := env.sessionService.Refresh(t.Context(), rotated.RefreshToken, "laptop", "10.0.0.1"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after reuse: err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	events, _ := env.securityService.ListEvents(t.Context(), repo.SecurityEventFilter{UserID: userID})
+	if len(events) != 1 || events[0].Type != models.SecurityEventTokenReused || events[0].IP != "10.6.6.6" {
+		t.Errorf("events = %+v, want one REFRESH_TOKEN_REUSED", events)
+	}
+}
+
+func TestRefreshRejectsUnknownAndExpiredTokens(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 0)
+	tokens, _ := env.sessionService.Start(t.Context(), userID, "", "")
+
+	for _, token := range []string{"", "garbage", tokens.SessionID.Hex() + ".guess"} {
+		if _, err := env.sessionService.Refresh(t.Context(), token, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("refresh %q: err = %v, want ErrInvalidRefreshToken", token, err)
+		}
+	}
+
+	// A wrong guess is not treated as reuse
+	if _, err := env.sessionService.Refresh(t.Context(), tokens.RefreshToken, "", ""); err != nil {
+		t.Fatalf("refresh after bad guesses: %v", err)
+	}
+
+	later := time.Now().Add(25 * time.Hour)
+	env.sessionService.now = func() time.Time { return later }
+	if _, err := env.sessionService.Refresh(t.Context(), tokens.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after expiry: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutAndLogoutAll(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 0)
+	otherID := env.createUser(t, 0)
+
+	phone, _ := env.sessionService.Start(t.Context(), userID, "phone", "")
+	laptop, _ := env.sessionService.Start(t.Context(), userID, "laptop", "")
+	tablet, _ := env.sessionService.Start(t.Context(), userID, "tablet", "")
+	other, _ := env.sessionService.Start(t.Context(), otherID, "phone", "")
+
+	if err := env.sessionService.Logout(t.Context(), otherID, phone.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("logout of another user's session: err = %v, want ErrSessionNotFound", err)
+	}
+	if err := env.sessionService.Logout(t.Context(), userID, phone.SessionID); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if _, err := env.sessionService.Refresh(t.Context(), phone.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logout: err = %v", err)
+	}
+
+	revoked, err := env.sessionService.LogoutAll(t.Context(), userID)
+	if err != nil || revoked != 2 {
+		t.Fatalf("logout all = %d, %v, want 2 sessions", revoked, err)
+	}
+	for _, tokens := range []*Tokens{laptop, tablet} {
+		if _, err := env.sessionService.Refresh(t.Context(), tokens.RefreshToken, "", ""); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("refresh after logout all: err = %v", err)
+		}
+	}
+
+	if sessions, _ := env.sessionService.ListSessions(t.Context(), otherID); len(sessions) != 1 || sessions[0].ID != other.SessionID {
+		t.Errorf("other user's sessions = %+v, want untouched", sessions)
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/auth"
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/mail"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo/memory"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	// Hashing ten backup codes at the default cost would dominate test time
+	backupCodeCost = bcrypt.MinCost
+}
+
+// testEnv wires the services to a fresh in-memory store
+type testEnv struct {
+	users     *memory.UserRepository
+	wallets   *memory.WalletRepository
+	orders    *memory.OrderRepository
+	portfolio *memory.PortfolioRepository
+	events    *memory.SecurityEventRepository
+
+	userService     *UserService
+	securityService *SecurityService
+	sessionService  *SessionService
+	twoFactor       *TwoFactorService
+	kycService      *KYCService
+	accountService  *AccountService
+	outbox          *outbox
+	walletService   *WalletService
+	stockService    *StockService
+	orderService    *OrderService
+	auditService    *AuditService
+	watchlists      *WatchlistService
+	alerts          *AlertService
+	notifications   *memory.NotificationRepository
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	store := memory.NewStore()
+
+	env := &testEnv{
+		users:     memory.NewUserRepository(store),
+		wallets:   memory.NewWalletRepository(store),
+		orders:    memory.NewOrderRepository(store),
+		portfolio: memory.NewPortfolioRepository(store),
+		events:    memory.NewSecurityEventRepository(store),
+	}
+
+	logger := logging.Discard()
+
+	env.auditService = NewAuditService(memory.NewAuditRepository(store), logger)
+	env.securityService = NewSecurityService(memory.NewLoginAttemptRepository(store), env.events, testLoginPolicy, logger)
+	env.userService = NewUserService(env.users, env.securityService, env.auditService, logger)
+	signer := auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "test", 15*time.Minute)
+	env.sessionService = NewSessionService(memory.NewSessionRepository(store), signer, env.securityService, 24*time.Hour, logger)
+	challenges := auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "test/2fa", 5*time.Minute)
+	env.twoFactor = NewTwoFactorService(env.users, env.securityService, challenges, "Test", logger)
+	env.kycService = NewKYCService(env.users, memory.NewLimitUsageRepository(store), testTierLimits, logger)
+	env.walletService = NewWalletService(env.users, env.wallets, env.twoFactor, env.kycService, env.auditService, testStepUpAmount, logger)
+	env.outbox = &outbox{}
+	env.stockService = NewStockService(memory.NewStockRepository(store), env.auditService, logger)
+	env.notifications = memory.NewNotificationRepository(store)
+	env.alerts = NewAlertService(env.users, memory.NewAlertRepository(store), env.notifications, env.stockService, logger)
+	env.watchlists = NewWatchlistService(env.users, memory.NewWatchlistRepository(store), env.stockService, logger)
+	env.orderService = NewOrderService(env.orders, env.portfolio, env.walletService, env.stockService, env.kycService, env.auditService, logger)
+	env.accountService = NewAccountService(env.users, memory.NewUserTokenRepository(store), env.portfolio, env.sessionService, env.securityService,
+		env.walletService, env.orderService, env.outbox,
+		auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "test/verify-email", 48*time.Hour),
+		auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"), "test/reset-password", time.Hour),
+		"https://app.example.com/", logger)
+
+	return env
+}
+
+// testStepUpAmount is the largest withdrawal that needs no second factor
+const testStepUpAmount = 1000
+
+// testTierLimits keep the unverified limits out of the way of tests that
+// are not about them
+var testTierLimits = []TierLimits{
+	models.KYCTierNone:  {Deposit: 10000, Withdrawal: 10000},
+	models.KYCTierBasic: {Deposit: 1000, Withdrawal: 500, Order: 1000},
+	models.KYCTierFull:  {Deposit: 1e9, Withdrawal: 1e9, Order: 1e9},
+}
+
+var testLoginPolicy = LoginPolicy{
+	MaxFailures:   3,
+	MaxIPFailures: 5,
+	Window:        15 * time.Minute,
+	Lockout:       15 * time.Minute,
+	BaseDelay:     time.Second,
+	MaxDelay:      4 * time.Second,
+}
+
+// outbox is a mail.Sender that keeps the messages
+type outbox struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg *mail.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, *msg)
+	return nil
+}
+
+// lastToken returns the token of the link in the last message sent to
+func (o *outbox) lastToken(t *testing.T, to string) string {
+	t.Helper()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, msg := range slices.Backward(o.messages) {
+		if msg.To != to {
+			continue
+		}
+		_, rest, ok := strings.Cut(msg.Body, "?token=")
+		if !ok {
+			t.Fatalf("no link in %q", msg.Body)
+		}
+		token, _ := url.QueryUnescape(strings.Fields(rest)[0])
+		return token
+	}
+
+	t.Fatalf("no mail sent to %s", to)
+	return ""
+}
+
+// createUser registers a user verified at the full KYC tier and funds the
+// wallet with balance
+func (e *testEnv) createUser(t *testing.T, balance float64) primitive.ObjectID {
+	t.Helper()
+
+	user := &models.User{
+		Name:          "Test",
+		Email:         primitive.NewObjectID().Hex() + "@example.com",
+		EmailVerified: true,
+		KYC:           &models.KYC{Status: models.KYCVerified, Tier: models.KYCTierFull},
+	}
+	if err := e.users.CreateUser(t.Context(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	if balance > 0 {
+		if err := e.walletService.Deposit(t.Context(), user.ID, balance); err != nil {
+			t.Fatalf("fund wallet: %v", err)
+		}
+	}
+
+	return user.ID
+}
+
+func (e *testEnv) createStock(t *testing.T, symbol string, price float64) {
+	t.Helper()
+
+	_, err := e.stockService.CreateStock(t.Context(), &models.Stock{Symbol: symbol, Name: symbol + " Inc.", Price: price})
+	if err != nil {
+		t.Fatalf("create stock: %v", err)
+	}
+}
+
+func (e *testEnv) balance(t *testing.T, userID primitive.ObjectID) float64 {
+	t.Helper()
+
+	balance, err := e.walletService.GetBalance(t.Context(), userID, userID)
+	if err != nil {
+		t.Fatalf("get balance: %v", err)
+	}
+
+	return balance
+}
+
+// quantity returns the number of shares held, 0 when there is no position
+func (e *testEnv) quantity(t *testing.T, userID primitive.ObjectID, symbol string) int {
+	t.Helper()
+
+	p, err := e.portfolio.GetPortfolio(t.Context(), userID, symbol)
+	if err != nil {
+		return 0
+	}
+
+	return p.Qty
+}
@@ -0,0 +1,403 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+)
+
+// Bulk import/export file formats
+const (
+	StockFormatCSV  = "csv"
+	StockFormatJSON = "json"
+)
+
+// Columns understood by the CSV importer and written by the exporter
+var stockCSVColumns = []string{"symbol", "name", "price", "sector", "industry", "exchange", "marketCap"}
+
+// The exporter adds the status, which an import cannot set
+var stockExportColumns = append(slices.Clone(stockCSVColumns), "status")
+
+var stockSymbolPattern = regexp.MustCompile(`^[A-Z0-9.\-]{1,10}$`)
+
+// StockImportRow is one stock in a bulk import file. Row is the 1-based line
+// (CSV, excluding the header) or array position (JSON) used in error reports.
+type StockImportRow struct {
+	Row       int     `json:"-"`
+	Symbol    string  `json:"symbol"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Sector    string  `json:"sector"`
+	Industry  string  `json:"industry"`
+	Exchange  string  `json:"exchange"`
+	MarketCap float64 `json:"marketCap"`
+}
+
+type ImportRowError struct {
+	Row    int    `json:"row"`
+	Symbol string `json:"symbol,omitempty"`
+	Error  string `json:"error"`
+}
+
+type ImportResult struct {
+	Received int              `json:"received"`
+	Inserted int64            `json:"inserted"`
+	Updated  int64            `json:"updated"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ParseStockCSV reads stocks from CSV with a header row. Columns are matched
+// by name, so their order does not matter and unknown columns are ignored.
+func ParseStockCSV(r io.Reader) ([]StockImportRow, []ImportRowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, invalid("file", "missing CSV header")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{"symbol", "name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, invalid("file", fmt.Sprintf("missing CSV column %q", required))
+		}
+	}
+
+	var rows []StockImportRow
+	var rowErrors []ImportRowError
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, invalid("file", fmt.Sprintf("row %d: %v", line, err))
+		}
+
+		field := func(name string) string {
+			i, ok := columns[strings.ToLower(name)]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := StockImportRow{
+			Row:      line,
+			Symbol:   field("symbol"),
+			Name:     field("name"),
+			Sector:   field("sector"),
+			Industry: field("industry"),
+			Exchange: field("exchange"),
+		}
+
+		if row.Price, err = parseOptionalFloat(field("price")); err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Row: line, Symbol: row.Symbol, Error: "invalid price"})
+			continue
+		}
+
+		if row.MarketCap, err = parseOptionalFloat(field("marketCap")); err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Row: line, Symbol: row.Symbol, Error: "invalid marketCap"})
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+// ParseStockJSON reads stocks from a JSON array
+func ParseStockJSON(r io.Reader) ([]StockImportRow, error) {
+	var rows []StockImportRow
+
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, invalid("file", fmt.Sprintf("invalid JSON: %v", err))
+	}
+
+	for i := range rows {
+		rows[i].Row = i + 1
+	}
+
+	return rows, nil
+}
+
+// ImportStocks validates every row and upserts the valid ones in one bulk
+// write. Invalid rows are reported and skipped; re-running the same file is a
+// no-op apart from refreshing prices and metadata.
+func (s *StockService) ImportStocks(ctx context.Context, rows []StockImportRow, parseErrors []ImportRowError) (_ *ImportResult, err error) {
+	ctx, span := startSpan(ctx, "StockService.ImportStocks")
+	defer endSpan(span, &err)
+
+	result := &ImportResult{
+		Received: len(rows) + len(parseErrors),
+		Errors:   append([]ImportRowError{}, parseErrors...),
+	}
+
+	seen := map[string]int{}
+	var valid []models.Stock
+
+	for _, row := range rows {
+		row.Symbol = strings.ToUpper(strings.TrimSpace(row.Symbol))
+		row.Name = strings.TrimSpace(row.Name)
+
+		if err := validateImportRow(row); err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: row.Row, Symbol: row.Symbol, Error: err.Error()})
+			continue
+		}
+
+		if first, ok := seen[row.Symbol]; ok {
+			result.Errors = append(result.Errors, ImportRowError{
+				Row:    row.Row,
+				Symbol: row.Symbol,
+				Error:  fmt.Sprintf("duplicate symbol, first seen in row %d", first),
+			})
+			continue
+		}
+		seen[row.Symbol] = row.Row
+
+		valid = append(valid, models.Stock{
+			Symbol:    row.Symbol,
+			Name:      row.Name,
+			Price:     row.Price,
+			Sector:    row.Sector,
+			Industry:  row.Industry,
+			Exchange:  row.Exchange,
+			MarketCap: row.MarketCap,
+		})
+	}
+
+	current := map[string]models.Stock{}
+	if len(valid) > 0 {
+		symbols := make([]string, 0, len(valid))
+		for _, stock := range valid {
+			symbols = append(symbols, stock.Symbol)
+		}
+
+		existing, err := s.stockRepo.GetStocksBySymbols(ctx, symbols)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, stock := range existing {
+			current[stock.Symbol] = stock
+		}
+	}
+
+	// Prices change as with UpdatePrice, and the listeners hear of those that
+	// moved. Delisted stocks are retired for good and must not be revived.
+	now := time.Now().UTC()
+	kept := valid[:0]
+	var moved []models.Stock
+	for _, stock := range valid {
+		cur, ok := current[stock.Symbol]
+		if !ok {
+			stock.PrevClose = stock.Price
+			stock.PriceAt = &now
+			kept = append(kept, stock)
+			continue
+		}
+
+		if cur.CurrentStatus() == models.StockStatusDelisted {
+			result.Errors = append(result.Errors, ImportRowError{
+				Row:    seen[stock.Symbol],
+				Symbol: stock.Symbol,
+				Error:  "stock is delisted",
+			})
+			continue
+		}
+
+		changed := cur.Price != stock.Price
+		rollPrice(&cur, stock.Price, now)
+		stock.PrevClose = cur.PrevClose
+		stock.PriceAt = cur.PriceAt
+		kept = append(kept, stock)
+		if changed {
+			moved = append(moved, cur)
+		}
+	}
+	valid = kept
+
+	// A stock delisted since it was read above is left alone by the write
+	inserted, updated, delisted, err := s.stockRepo.BulkUpsertStocks(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, symbol := range delisted {
+		result.Errors = append(result.Errors, ImportRowError{
+			Row:    seen[symbol],
+			Symbol: symbol,
+			Error:  "stock is delisted",
+		})
+	}
+	valid = slices.DeleteFunc(valid, func(stock models.Stock) bool {
+		return slices.Contains(delisted, stock.Symbol)
+	})
+	moved = slices.DeleteFunc(moved, func(stock models.Stock) bool {
+		return slices.Contains(delisted, stock.Symbol)
+	})
+
+	for _, stock := range moved {
+		s.priceChanged(stock)
+	}
+
+	result.Inserted = inserted
+	result.Updated = updated
+	result.Failed = len(result.Errors)
+
+	s.logger.InfoContext(ctx, "stocks imported", "inserted", inserted, "updated", updated, "failed", result.Failed)
+
+	if len(valid) > 0 {
+		symbols := make([]string, len(valid))
+		for i, stock := range valid {
+			symbols[i] = stock.Symbol
+		}
+		s.audit.Record(ctx, models.AuditStocksImported, models.AuditTargetStock, "", nil, importSnapshot{
+			Symbols:  symbols,
+			Inserted: inserted,
+			Updated:  updated,
+		})
+	}
+
+	return result, nil
+}
+
+// importSnapshot is what an import is audited with; the stocks themselves
+// can be exported
+type importSnapshot struct {
+	Symbols  []string `json:"symbols"`
+	Inserted int64    `json:"inserted"`
+	Updated  int64    `json:"updated"`
+}
+
+// ImportStocksFrom parses a CSV or JSON file and imports it
+func (s *StockService) ImportStocksFrom(ctx context.Context, r io.Reader, format string) (_ *ImportResult, err error) {
+	ctx, span := startSpan(ctx, "StockService.ImportStocksFrom")
+	defer endSpan(span, &err)
+
+	switch strings.ToLower(format) {
+	case StockFormatCSV:
+		rows, rowErrors, err := ParseStockCSV(r)
+		if err != nil {
+			return nil, err
+		}
+		return s.ImportStocks(ctx, rows, rowErrors)
+	case StockFormatJSON:
+		rows, err := ParseStockJSON(r)
+		if err != nil {
+			return nil, err
+		}
+		return s.ImportStocks(ctx, rows, nil)
+	}
+
+	return nil, invalid("format", "format must be csv or json")
+}
+
+// ExportStocks writes every stock, including delisted ones, as CSV or JSON
+func (s *StockService) ExportStocks(ctx context.Context, w io.Writer, format string) (err error) {
+	ctx, span := startSpan(ctx, "StockService.ExportStocks")
+	defer endSpan(span, &err)
+
+	format = strings.ToLower(format)
+	if format != StockFormatCSV && format != StockFormatJSON {
+		return invalid("format", "format must be csv or json")
+	}
+
+	stocks, err := s.stockRepo.GetAllStocks(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	if format == StockFormatCSV {
+		return WriteStockCSV(w, stocks)
+	}
+
+	if stocks == nil {
+		stocks = []models.Stock{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stocks)
+}
+
+// WriteStockCSV writes stocks in the same layout ParseStockCSV accepts
+func WriteStockCSV(w io.Writer, stocks []models.Stock) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(stockExportColumns); err != nil {
+		return err
+	}
+
+	for _, stock := range stocks {
+		record := []string{
+			stock.Symbol,
+			stock.Name,
+			strconv.FormatFloat(stock.Price, 'f', -1, 64),
+			stock.Sector,
+			stock.Industry,
+			stock.Exchange,
+			strconv.FormatFloat(stock.MarketCap, 'f', -1, 64),
+			stock.CurrentStatus(),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func validateImportRow(row StockImportRow) error {
+	if row.Symbol == "" {
+		return errors.New("symbol is required")
+	}
+	if !stockSymbolPattern.MatchString(row.Symbol) {
+		return errors.New("symbol must be 1-10 letters, digits, dots or dashes")
+	}
+	if row.Name == "" {
+		return errors.New("name is required")
+	}
+	// ParseFloat accepts "NaN" and "Inf", which pass the range checks
+	if math.IsNaN(row.Price) || math.IsInf(row.Price, 0) {
+		return errors.New("price must be a finite number")
+	}
+	if row.Price <= 0 {
+		return errors.New("price must be greater than zero")
+	}
+	if math.IsNaN(row.MarketCap) || math.IsInf(row.MarketCap, 0) {
+		return errors.New("market cap must be a finite number")
+	}
+	if row.MarketCap < 0 {
+		return errors.New("market cap cannot be negative")
+	}
+	return nil
+}
+
+func parseOptionalFloat(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
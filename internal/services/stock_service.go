@@ -1,56 +1,241 @@
 package services
 
 import (
-	"errors"
+	"context"
+	"log/slog"
 	"strings"
+	"time"
 
 	"concurrent-wallet-order-system/internal/models"
 	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type StockService struct {
-	stockRepo *repo.StockRepository
+	stockRepo      repo.StockRepository
+	audit          *AuditService
+	logger         *slog.Logger
+	priceListeners []func(models.Stock)
 }
 
-func NewStockService(stockRepo *repo.StockRepository) *StockService {
+// NewStockService writes created and imported stocks to the audit log
+func NewStockService(stockRepo repo.StockRepository, audit *AuditService, logger *slog.Logger) *StockService {
 	return &StockService{
 		stockRepo: stockRepo,
+		audit:     audit,
+		logger:    logger,
 	}
 }
 
 // Create stock
-func (s *StockService) CreateStock(symbol, name string, price float64) (*models.Stock, error) {
+func (s *StockService) CreateStock(ctx context.Context, stock *models.Stock) (_ *models.Stock, err error) {
+	ctx, span := startSpan(ctx, "StockService.CreateStock")
+	defer endSpan(span, &err)
 
-	if price <= 0 {
-		return nil, errors.New("price must be greater than zero")
+	if stock.Price <= 0 {
+		return nil, invalid("price", "price must be greater than zero")
+	}
+
+	if stock.MarketCap < 0 {
+		return nil, invalid("marketCap", "market cap cannot be negative")
 	}
 
-	symbol = strings.ToUpper(symbol)
+	stock.Symbol = strings.ToUpper(stock.Symbol)
 
 	// Check if stock already exists
-	existing, _ := s.stockRepo.GetStockBySymbol(symbol)
+	existing, _ := s.stockRepo.GetStockBySymbol(ctx, stock.Symbol)
 	if existing != nil {
-		return nil, errors.New("stock already exists")
+		return nil, ErrStockExists
+	}
+
+	stock.Status = models.StockStatusActive
+	stock.PrevClose = stock.Price
+
+	err = s.stockRepo.CreateStock(ctx, stock)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrStockExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "stock created", "symbol", stock.Symbol, "price", stock.Price)
+
+	s.audit.Record(ctx, models.AuditStockCreated, models.AuditTargetStock, stock.Symbol, nil, stock)
+
+	return stock, nil
+}
+
+func (s *StockService) GetAllStocks(ctx context.Context, includeDelisted bool) (_ []models.Stock, err error) {
+	ctx, span := startSpan(ctx, "StockService.GetAllStocks")
+	defer endSpan(span, &err)
+
+	return s.stockRepo.GetAllStocks(ctx, includeDelisted)
+}
+
+// Sortable fields accepted by SearchStocks
+var stockSortFields = map[string]bool{
+	"symbol":    true,
+	"name":      true,
+	"price":     true,
+	"marketCap": true,
+}
+
+const (
+	defaultStockPageSize = 50
+	maxStockPageSize     = 200
+)
+
+func (s *StockService) SearchStocks(ctx context.Context, filter repo.StockFilter) (_ []models.Stock, _ int64, err error) {
+	ctx, span := startSpan(ctx, "StockService.SearchStocks")
+	defer endSpan(span, &err)
+
+	// Mongo cannot combine a $text search with the prefix match
+	if filter.Text != "" && filter.Prefix != "" {
+		return nil, 0, invalid("prefix", "q and prefix cannot be combined")
+	}
+
+	if filter.SortBy != "" && !stockSortFields[filter.SortBy] {
+		return nil, 0, invalid("sort", "sort must be one of symbol, name, price, marketCap")
 	}
 
-	stock := &models.Stock{
-		Symbol: symbol,
-		Name:   name,
-		Price:  price,
+	if filter.MinPrice < 0 || filter.MaxPrice < 0 {
+		return nil, 0, invalid("minPrice", "price range cannot be negative")
 	}
 
-	err := s.stockRepo.CreateStock(stock)
+	if filter.MaxPrice > 0 && filter.MinPrice > filter.MaxPrice {
+		return nil, 0, invalid("minPrice", "minPrice cannot exceed maxPrice")
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.Limit < 1 {
+		filter.Limit = defaultStockPageSize
+	}
+	if filter.Limit > maxStockPageSize {
+		filter.Limit = maxStockPageSize
+	}
+
+	return s.stockRepo.SearchStocks(ctx, filter)
+}
+
+func (s *StockService) GetStocksBySymbols(ctx context.Context, symbols []string) (_ []models.Stock, err error) {
+	ctx, span := startSpan(ctx, "StockService.GetStocksBySymbols")
+	defer endSpan(span, &err)
+
+	return s.stockRepo.GetStocksBySymbols(ctx, symbols)
+}
+
+func (s *StockService) GetStockBySymbol(ctx context.Context, symbol string) (_ *models.Stock, err error) {
+	ctx, span := startSpan(ctx, "StockService.GetStockBySymbol")
+	defer endSpan(span, &err)
+
+	stock, err := s.stockRepo.GetStockBySymbol(ctx, strings.ToUpper(symbol))
+	if err != nil {
+		return nil, notFound(err, ErrStockNotFound)
+	}
+
+	return stock, nil
+}
+
+// SetStatus halts or resumes trading in a stock. Delisting is final and goes
+// through OrderService.DelistStock so that open holdings are settled.
+func (s *StockService) SetStatus(ctx context.Context, symbol, status string) (_ *models.Stock, err error) {
+	ctx, span := startSpan(ctx, "StockService.SetStatus")
+	defer endSpan(span, &err)
+
+	status = strings.ToUpper(status)
+	if status != models.StockStatusActive && status != models.StockStatusHalted {
+		return nil, invalid("status", "status must be ACTIVE or HALTED")
+	}
+
+	stock, err := s.GetStockBySymbol(ctx, symbol)
 	if err != nil {
 		return nil, err
 	}
 
+	if stock.CurrentStatus() == models.StockStatusDelisted {
+		return nil, ErrStockDelisted
+	}
+
+	err = s.stockRepo.UpdateStatus(ctx, stock.Symbol, status)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "stock status changed", "symbol", stock.Symbol, "from", stock.CurrentStatus(), "to", status)
+
+	stock.Status = status
 	return stock, nil
 }
 
-func (s *StockService) GetAllStocks() ([]models.Stock, error) {
-	return s.stockRepo.GetAllStocks()
+func (s *StockService) markDelisted(ctx context.Context, symbol string, finalPrice float64) error {
+	return s.stockRepo.MarkDelisted(ctx, symbol, finalPrice)
+}
+
+// OnPriceChange registers a callback run after every successful price update,
+// imported prices included.
+// Listeners must be registered before the service starts handling requests.
+func (s *StockService) OnPriceChange(listener func(models.Stock)) {
+	s.priceListeners = append(s.priceListeners, listener)
+}
+
+// UpdatePrice sets a new market price. The first update of a calendar day
+// (UTC) rolls the previous price over into previousClose, which is the
+// reference for day-change figures and percentage alerts.
+func (s *StockService) UpdatePrice(ctx context.Context, symbol string, price float64) (_ *models.Stock, err error) {
+	ctx, span := startSpan(ctx, "StockService.UpdatePrice")
+	defer endSpan(span, &err)
+
+	if price <= 0 {
+		return nil, invalid("price", "price must be greater than zero")
+	}
+
+	stock, err := s.GetStockBySymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if stock.CurrentStatus() == models.StockStatusDelisted {
+		return nil, ErrStockDelisted
+	}
+
+	from := stock.Price
+	rollPrice(stock, price, time.Now().UTC())
+
+	err = s.stockRepo.UpdatePrice(ctx, stock.Symbol, price, stock.PrevClose, *stock.PriceAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.DebugContext(ctx, "stock price updated", "symbol", stock.Symbol, "from", from, "to", price)
+
+	s.priceChanged(*stock)
+	return stock, nil
+}
+
+// rollPrice moves stock to price as of now. The first change of a calendar
+// day (UTC) rolls the last price over into previousClose.
+func rollPrice(stock *models.Stock, price float64, now time.Time) {
+	if stock.PriceAt == nil || !sameDay(stock.PriceAt.UTC(), now) || stock.PrevClose == 0 {
+		stock.PrevClose = stock.Price
+	}
+	stock.Price = price
+	stock.PriceAt = &now
+}
+
+// priceChanged runs the listeners registered with OnPriceChange
+func (s *StockService) priceChanged(stock models.Stock) {
+	for _, listener := range s.priceListeners {
+		listener(stock)
+	}
 }
 
-func (s *StockService) GetStockBySymbol(symbol string) (*models.Stock, error) {
-	return s.stockRepo.GetStockBySymbol(strings.ToUpper(symbol))
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
 }
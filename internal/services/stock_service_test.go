@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+)
+
+func TestSearchStocksRejectsTextWithPrefix(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "ACME", 10)
+
+	_, _, err := env.stockService.SearchStocks(t.Context(), repo.StockFilter{Text: "acme", Prefix: "AC"})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "prefix" {
+		t.Errorf("err = %v, want a validation error on prefix", err)
+	}
+
+	stocks, total, err := env.stockService.SearchStocks(t.Context(), repo.StockFilter{Prefix: "AC"})
+	if err != nil || total != 1 || len(stocks) != 1 {
+		t.Errorf("prefix search = %d of %d, err = %v; want ACME", len(stocks), total, err)
+	}
+}
+
+func TestImportStocksMovesPricesLikeUpdatePrice(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "ACME", 10)
+	env.createStock(t, "SAME", 20)
+
+	var heard []models.Stock
+	env.stockService.OnPriceChange(func(stock models.Stock) {
+		heard = append(heard, stock)
+	})
+
+	csv := "symbol,name,price\nACME,Acme,12\nSAME,Same,20\nNEW,New,5\nNAN,Not a number,NaN\nINF,Infinite,Inf\n"
+	result, err := env.stockService.ImportStocksFrom(t.Context(), strings.NewReader(csv), StockFormatCSV)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if result.Inserted != 1 || result.Updated != 2 || result.Failed != 2 {
+		t.Errorf("result = %+v, want 1 inserted, 2 updated and the NaN and Inf rows failed", result)
+	}
+
+	// Only the price that moved is heard of
+	if len(heard) != 1 || heard[0].Symbol != "ACME" || heard[0].Price != 12 || heard[0].PrevClose != 10 {
+		t.Fatalf("listeners heard %+v, want ACME moving from 10 to 12", heard)
+	}
+
+	stock, err := env.stockService.GetStockBySymbol(t.Context(), "ACME")
+	if err != nil {
+		t.Fatalf("get stock: %v", err)
+	}
+	if stock.Price != 12 || stock.PrevClose != 10 || stock.PriceAt == nil {
+		t.Errorf("stock = %+v, want price 12 against a previous close of 10", stock)
+	}
+}
+
+// delistingStockRepo delists a stock right after an import has read it, as a
+// concurrent delisting would
+type delistingStockRepo struct {
+	repo.StockRepository
+	symbol string
+}
+
+func (r delistingStockRepo) GetStocksBySymbols(ctx context.Context, symbols []string) ([]models.Stock, error) {
+	stocks, err := r.StockRepository.GetStocksBySymbols(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+	return stocks, r.MarkDelisted(ctx, r.symbol, 10)
+}
+
+func TestImportStocksLeavesStockDelistedMidImportAlone(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "ACME", 10)
+	env.stockService.stockRepo = delistingStockRepo{env.stockService.stockRepo, "ACME"}
+
+	var heard []models.Stock
+	env.stockService.OnPriceChange(func(stock models.Stock) {
+		heard = append(heard, stock)
+	})
+
+	csv := "symbol,name,price\nNEW,New,5\nACME,Acme,12\n"
+	result, err := env.stockService.ImportStocksFrom(t.Context(), strings.NewReader(csv), StockFormatCSV)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := []ImportRowError{{Row: 2, Symbol: "ACME", Error: "stock is delisted"}}
+	if result.Inserted != 1 || result.Updated != 0 || result.Failed != 1 || !slices.Equal(result.Errors, want) {
+		t.Errorf("result = %+v, want NEW inserted and ACME reported as delisted", result)
+	}
+	if len(heard) != 0 {
+		t.Errorf("listeners heard %+v, want nothing", heard)
+	}
+
+	stock, err := env.stockService.GetStockBySymbol(t.Context(), "ACME")
+	if err != nil {
+		t.Fatalf("get stock: %v", err)
+	}
+	if stock.CurrentStatus() != models.StockStatusDelisted || stock.Price != 10 {
+		t.Errorf("stock = %+v, want it delisted at 10", stock)
+	}
+}
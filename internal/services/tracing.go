@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+
+	"concurrent-wallet-order-system/internal/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("concurrent-wallet-order-system/internal/services")
+
+// startSpan starts the span of a service method
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the method's error and ends the span. Business rejections
+// are recorded as events but do not mark the span as failed.
+func endSpan(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		if outcome(*err) == metrics.OutcomeError {
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+	}
+	span.End()
+}
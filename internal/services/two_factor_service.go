@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/auth"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// backupCodeCount is how many backup codes a user gets at a time
+const backupCodeCount = 10
+
+// backupCodeCost is the bcrypt cost of backup code hashes
+var backupCodeCost = bcrypt.DefaultCost
+
+// TwoFactorStatus describes a user's two-factor enrolment
+type TwoFactorStatus struct {
+	Enabled              bool       `json:"enabled"`
+	EnabledAt            *time.Time `json:"enabledAt,omitempty"`
+	BackupCodesRemaining int        `json:"backupCodesRemaining"`
+}
+
+// TwoFactorService manages TOTP enrolment and checks second factors: at
+// login, and for step-up verification of sensitive actions. A second factor
+// is a TOTP code or a single-use backup code. Wrong codes count as failed
+// logins, so SecurityService throttles guessing them.
+type TwoFactorService struct {
+	userRepo   repo.UserRepository
+	security   *SecurityService
+	challenges *auth.Signer
+	issuer     string
+	now        func() time.Time
+	logger     *slog.Logger
+}
+
+// NewTwoFactorService needs a signer of its own for login challenges, with
+// an issuer distinct from the access tokens' so neither passes for the other.
+// issuer is the name authenticator apps show for the account.
+func NewTwoFactorService(userRepo repo.UserRepository, security *SecurityService, challenges *auth.Signer, issuer string, logger *slog.Logger) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:   userRepo,
+		security:   security,
+		challenges: challenges,
+		issuer:     issuer,
+		now:        time.Now,
+		logger:     logger,
+	}
+}
+
+func (s *TwoFactorService) user(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return user, nil
+}
+
+func (s *TwoFactorService) Status(ctx context.Context, userID primitive.ObjectID) (_ *TwoFactorStatus, err error) {
+	ctx, span := startSpan(ctx, "TwoFactorService.Status")
+	defer endSpan(span, &err)
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return &TwoFactorStatus{}, nil
+	}
+	return &TwoFactorStatus{
+		Enabled:              true,
+		EnabledAt:            user.TwoFactor.EnabledAt,
+		BackupCodesRemaining: len(user.TwoFactor.BackupCodes),
+	}, nil
+}
+
+// Setup starts an enrolment with a new secret and returns it with its
+// provisioning URI. Setting up again replaces a pending secret.
+func (s *TwoFactorService) Setup(ctx context.Context, userID primitive.ObjectID) (secret, uri string, err error) {
+	ctx, span := startSpan(ctx, "TwoFactorService.Setup")
+	defer endSpan(span, &err)
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TwoFactorEnabled() {
+		return "", "", ErrTwoFactorEnabled
+	}
+
+	secret = auth.NewTOTPSecret()
+	if err := s.userRepo.SetTwoFactor(ctx, userID, &models.TwoFactor{Secret: secret}); err != nil {
+		return "", "", err
+	}
+
+	return secret, auth.TOTPURI(s.issuer, user.Email, secret), nil
+}
+
+// Enable completes an enrolment once the user enters a code from their
+// authenticator, and returns their backup codes. They are only stored
+// hashed, so this is the one time they can be shown.
+func (s *TwoFactorService) Enable(ctx context.Context, userID primitive.ObjectID, code string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "TwoFactorService.Enable")
+	defer endSpan(span, &err)
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TwoFactor == nil {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	now := s.now()
+	step, ok := auth.ValidateTOTP(user.TwoFactor.Secret, normalizeCode(code), now)
+	if !ok {
+		return nil, ErrInvalidSecondFactor
+	}
+
+	codes, hashes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userRepo.SetTwoFactor(ctx, userID, &models.TwoFactor{
+		Secret:       user.TwoFactor.Secret,
+		Enabled:      true,
+		BackupCodes:  hashes,
+		LastUsedStep: step,
+		EnabledAt:    &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "two-factor authentication enabled", "user_id", userID.Hex())
+	s.security.record(ctx, &models.SecurityEvent{Type: models.SecurityEventTwoFactorEnabled, UserID: &user.ID, Email: strings.ToLower(user.Email)})
+	return codes, nil
+}
+
+// Disable removes the enrolment; it takes a valid second factor
+func (s *TwoFactorService) Disable(ctx context.Context, userID primitive.ObjectID, code, ip string) (err error) {
+	ctx, span := startSpan(ctx, "TwoFactorService.Disable")
+	defer endSpan(span, &err)
+
+	user, err := s.enabledUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verify(ctx, user, code, ip); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetTwoFactor(ctx, userID, nil); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "two-factor authentication disabled", "user_id", userID.Hex())
+	s.security.record(ctx, &models.SecurityEvent{Type: models.SecurityEventTwoFactorDisabled, UserID: &user.ID, Email: strings.ToLower(user.Email), IP: ip})
+	return nil
+}
+
+// RegenerateBackupCodes replaces all backup codes, used or not; it takes a
+// valid second factor
+func (s *TwoFactorService) RegenerateBackupCodes(ctx context.Context, userID primitive.ObjectID, code, ip string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "TwoFactorService.RegenerateBackupCodes")
+	defer endSpan(span, &err)
+
+	user, err := s.enabledUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(ctx, user, code, ip); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetBackupCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "backup codes regenerated", "user_id", userID.Hex())
+	return codes, nil
+}
+
+func (s *TwoFactorService) enabledUser(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return user, nil
+}
+
+// Challenge returns a short-lived token for a user who has passed the
+// password check of a login, to present with their second factor
+func (s *TwoFactorService) Challenge(user *models.User) (string, time.Time, error) {
+	return s.challenges.Issue(user.ID.Hex(), "", s.now())
+}
+
+// CompleteLogin checks the second factor of a login started with Challenge
+func (s *TwoFactorService) CompleteLogin(ctx context.Context, challenge, code, ip string) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "TwoFactorService.CompleteLogin")
+	defer endSpan(span, &err)
+
+	claims, err := s.challenges.Verify(challenge, s.now())
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.user(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) || (err == nil && user.Closed()) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled() {
+		// Disabled since the challenge was issued; the password was checked
+		return user, s.security.LoginSucceeded(ctx, user.Email)
+	}
+
+	if err := s.verify(ctx, user, code, ip); err != nil {
+		return nil, err
+	}
+	if err := s.security.LoginSucceeded(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "second factor accepted", "user_id", user.ID.Hex())
+	return user, nil
+}
+
+// VerifyStepUp checks the second factor of a user about to do something
+// sensitive. Users without two-factor authentication cannot pass it.
+func (s *TwoFactorService) VerifyStepUp(ctx context.Context, userID primitive.ObjectID, code string) (err error) {
+	ctx, span := startSpan(ctx, "TwoFactorService.VerifyStepUp", attribute.String("user.id", userID.Hex()))
+	defer endSpan(span, &err)
+
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorRequired
+	}
+	if code == "" {
+		return ErrStepUpRequired
+	}
+
+	return s.verify(ctx, user, code, "")
+}
+
+// verify accepts a TOTP code or an unused backup code. Attempts go through
+// the login throttle of the user's account, and failures count towards it.
+func (s *TwoFactorService) verify(ctx context.Context, user *models.User, code, ip string) error {
+	if err := s.security.CheckLogin(ctx, user.Email, ip); err != nil {
+		return err
+	}
+
+	ok, err := s.accept(ctx, user, normalizeCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.logger.WarnContext(ctx, "invalid second factor", "user_id", user.ID.Hex())
+		if err := s.security.LoginFailed(ctx, user.Email, ip, &user.ID); err != nil {
+			return err
+		}
+		return ErrInvalidSecondFactor
+	}
+
+	return nil
+}
+
+// accept consumes code if it is valid: the TOTP time step it belongs to, or
+// the backup code itself, cannot be used again
+func (s *TwoFactorService) accept(ctx context.Context, user *models.User, code string) (bool, error) {
+	tf := user.TwoFactor
+
+	if step, ok := auth.ValidateTOTP(tf.Secret, code, s.now()); ok {
+		return consumed(s.userRepo.UseTOTPStep(ctx, user.ID, step))
+	}
+
+	for _, hash := range tf.BackupCodes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			return consumed(s.userRepo.UseBackupCode(ctx, user.ID, hash))
+		}
+	}
+
+	return false, nil
+}
+
+// consumed reads the result of marking a code used; a missing document means
+// a concurrent request used it first
+func consumed(err error) (bool, error) {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// normalizeCode lets users type backup codes with or without the dash, in
+// either case, and TOTP codes with spaces
+func normalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
+
+// newBackupCodes returns codes formatted for display, e.g. 7kq2m-x4hpa, and
+// the bcrypt hashes of their normalized form
+func newBackupCodes() (codes, hashes []string, err error) {
+	for range backupCodeCount {
+		code := strings.ToLower(rand.Text()[:10])
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), backupCodeCost)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, string(hash))
+	}
+	return codes, hashes, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/auth"
+	"concurrent-wallet-order-system/internal/models"
+)
+
+// newTwoFactorEnv returns a user with two-factor authentication enabled, the
+// secret of their authenticator and their backup codes
+func newTwoFactorEnv(t *testing.T) (*testEnv, *clock, *models.User, string, []string) {
+	t.Helper()
+
+	env, clk, user := newLoginEnv(t)
+	env.twoFactor.now = clk.now
+
+	secret, _, err := env.twoFactor.Setup(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	backupCodes, err := env.twoFactor.Enable(t.Context(), user.ID, totp(t, secret, clk))
+	if err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+
+	// The code used to enable cannot be used again
+	clk.advance(30 * time.Second)
+	return env, clk, user, secret, backupCodes
+}
+
+func totp(t *testing.T, secret string, clk *clock) string {
+	t.Helper()
+
+	code, err := auth.TOTPCode(secret, clk.now())
+	if err != nil {
+		t.Fatalf("totp code: %v", err)
+	}
+	return code
+}
+
+func TestTwoFactorEnableRequiresValidCode(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+	env.twoFactor.now = clk.now
+
+	if _, err := env.twoFactor.Enable(t.Context(), user.ID, "123456"); !errors.Is(err, ErrTwoFactorNotSetUp) {
+		t.Fatalf("enable before setup: err = %v, want ErrTwoFactorNotSetUp", err)
+	}
+
+	secret, uri, err := env.twoFactor.Setup(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if want := auth.TOTPURI("Test", user.Email, secret); uri != want {
+		t.Errorf("uri = %s, want %s", uri, want)
+	}
+
+	if _, err := env.twoFactor.Enable(t.Context(), user.ID, "000000"); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Fatalf("enable with wrong code: err = %v, want ErrInvalidSecondFactor", err)
+	}
+
+	codes, err := env.twoFactor.Enable(t.Context(), user.ID, totp(t, secret, clk))
+	if err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if len(codes) != backupCodeCount {
+		t.Errorf("got %d backup codes, want %d", len(codes), backupCodeCount)
+	}
+
+	stored, _ := env.users.GetUserByID(t.Context(), user.ID)
+	for _, hash := range stored.TwoFactor.BackupCodes {
+		for _, code := range codes {
+			if hash == code || hash == normalizeCode(code) {
+				t.Fatal("backup code stored in plain text")
+			}
+		}
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	env, clk, user, secret, _ := newTwoFactorEnv(t)
+
+	if _, err := env.userService.Login(t.Context(), user.Email, "correct-horse", "10.0.0.1"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	loggedIn, _ := env.users.GetUserByID(t.Context(), user.ID)
+	challenge, _, err := env.twoFactor.Challenge(loggedIn)
+	if err != nil {
+		t.Fatalf("challenge: %v", err)
+	}
+
+	if _, err := env.twoFactor.CompleteLogin(t.Context(), "not-a-challenge", totp(t, secret, clk), "10.0.0.1"); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("forged challenge: err = %v, want ErrInvalidChallenge", err)
+	}
+	if _, err := env.twoFactor.CompleteLogin(t.Context(), challenge, "000000", "10.0.0.1"); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Fatalf("wrong code: err = %v, want ErrInvalidSecondFactor", err)
+	}
+	clk.advance(testLoginPolicy.BaseDelay)
+
+	code := totp(t, secret, clk)
+	got, err := env.twoFactor.CompleteLogin(t.Context(), challenge, code, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("complete login: %v", err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("logged in as %s, want %s", got.ID.Hex(), user.ID.Hex())
+	}
+
+	// A code works once, even within its period
+	if _, err := env.twoFactor.CompleteLogin(t.Context(), challenge, code, "10.0.0.1"); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Errorf("replayed code: err = %v, want ErrInvalidSecondFactor", err)
+	}
+}
+
+func TestTwoFactorBackupCodesAreSingleUse(t *testing.T) {
+	env, _, user, _, codes := newTwoFactorEnv(t)
+
+	if err := env.twoFactor.VerifyStepUp(t.Context(), user.ID, codes[0]); err != nil {
+		t.Fatalf("backup code rejected: %v", err)
+	}
+	if err := env.twoFactor.VerifyStepUp(t.Context(), user.ID, codes[0]); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Errorf("reused backup code: err = %v, want ErrInvalidSecondFactor", err)
+	}
+
+	status, err := env.twoFactor.Status(t.Context(), user.ID)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.BackupCodesRemaining != backupCodeCount-1 {
+		t.Errorf("backup codes remaining = %d, want %d", status.BackupCodesRemaining, backupCodeCount-1)
+	}
+}
+
+func TestWithdrawAboveStepUpAmount(t *testing.T) {
+	env, clk, user, secret, _ := newTwoFactorEnv(t)
+	plain := env.createUser(t, 5000)
+	if err := env.users.MarkEmailVerified(t.Context(), user.ID, user.Email, clk.now()); err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	if err := env.walletService.Deposit(t.Context(), user.ID, 5000); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+
+	if err := env.walletService.Withdraw(t.Context(), user.ID, testStepUpAmount, ""); err != nil {
+		t.Errorf("withdraw at the step-up amount: %v", err)
+	}
+	if err := env.walletService.Withdraw(t.Context(), user.ID, 1500, ""); !errors.Is(err, ErrStepUpRequired) {
+		t.Errorf("withdraw without code: err = %v, want ErrStepUpRequired", err)
+	}
+	if err := env.walletService.Withdraw(t.Context(), plain, 1500, ""); !errors.Is(err, ErrTwoFactorRequired) {
+		t.Errorf("withdraw without two-factor: err = %v, want ErrTwoFactorRequired", err)
+	}
+	if err := env.walletService.Withdraw(t.Context(), user.ID, 1500, totp(t, secret, clk)); err != nil {
+		t.Fatalf("withdraw with code: %v", err)
+	}
+
+	if got := env.balance(t, user.ID); got != 2500 {
+		t.Errorf("balance = %v, want 2500", got)
+	}
+}
@@ -1,32 +1,45 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"time"
 
+	"concurrent-wallet-order-system/internal/metrics"
 	"concurrent-wallet-order-system/internal/models"
 	"concurrent-wallet-order-system/internal/repo"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	userRepo *repo.UserRepository
+	userRepo repo.UserRepository
+	security *SecurityService
+	audit    *AuditService
+	logger   *slog.Logger
 }
 
-func NewUserService(userRepo *repo.UserRepository) *UserService {
+// NewUserService writes role changes to the audit log
+func NewUserService(userRepo repo.UserRepository, security *SecurityService, audit *AuditService, logger *slog.Logger) *UserService {
 	return &UserService{
 		userRepo: userRepo,
+		security: security,
+		audit:    audit,
+		logger:   logger,
 	}
 }
 
-func (s *UserService) Register(name, email, password string) (*models.User, error) {
+func (s *UserService) Register(ctx context.Context, name, email, password string) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.Register")
+	defer endSpan(span, &err)
 
 	// Check if user already exists
-	existingUser, _ := s.userRepo.GetUserByEmail(email)
+	existingUser, _ := s.userRepo.GetUserByEmail(ctx, email)
 	if existingUser != nil {
-		return nil, errors.New("email already registered")
+		return nil, ErrEmailTaken
 	}
 
 	// Hash password
@@ -43,33 +56,193 @@ func (s *UserService) Register(name, email, password string) (*models.User, erro
 		CreatedAt:     time.Now(),
 	}
 
-	err = s.userRepo.CreateUser(user)
+	err = s.userRepo.CreateUser(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrEmailTaken
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	s.logger.InfoContext(ctx, "user registered", "user_id", user.ID.Hex())
 	return user, nil
 }
 
-func (s *UserService) Login(email, password string) (*models.User, error) {
+// Login checks a user's credentials. ip is the client address, used to
+// throttle failed attempts per IP as well as per account. For a user with
+// two-factor authentication the password only starts the login; the caller
+// must go on to TwoFactorService.Challenge.
+func (s *UserService) Login(ctx context.Context, email, password, ip string) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.Login")
+	defer endSpan(span, &err)
+	defer func() { metrics.LoginAttempts.WithLabelValues(loginResult(err)).Inc() }()
 
-	user, err := s.userRepo.GetUserByEmail(email)
+	if err := s.security.CheckLogin(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && user.Closed()) {
+		return nil, s.loginFailed(ctx, email, ip, nil)
+	}
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, err
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		s.logger.WarnContext(ctx, "login failed: wrong password", "user_id", user.ID.Hex())
+		return nil, s.loginFailed(ctx, email, ip, &user.ID)
+	}
+
+	// With two-factor authentication the login is only complete, and the
+	// failures forgotten, once TwoFactorService.CompleteLogin accepts a code
+	if user.TwoFactorEnabled() {
+		return user, nil
+	}
+	if err := s.security.LoginSucceeded(ctx, email); err != nil {
+		return nil, err
 	}
 
 	return user, nil
 }
 
-func (s *UserService) GetUserByID(userID primitive.ObjectID) (*models.User, error) {
-	return s.userRepo.GetUserByID(userID)
+// loginFailed records a failed login and returns the error for the client
+func (s *UserService) loginFailed(ctx context.Context, email, ip string, userID *primitive.ObjectID) error {
+	if err := s.security.LoginFailed(ctx, email, ip, userID); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// UnlockUser lifts a login lockout on the user's account
+func (s *UserService) UnlockUser(ctx context.Context, userID primitive.ObjectID) (err error) {
+	ctx, span := startSpan(ctx, "UserService.UnlockUser")
+	defer endSpan(span, &err)
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+
+	return s.security.UnlockAccount(ctx, user)
+}
+
+// GetUser returns the user's profile. Anyone but the user needs the admin
+// role.
+func (s *UserService) GetUser(ctx context.Context, callerID, userID primitive.ObjectID) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.GetUser")
+	defer endSpan(span, &err)
+
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, userID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	return user, nil
 }
 
-func (s *UserService) GetAllUsers() ([]models.User, error) {
-	return s.userRepo.GetAllUsers()
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+// SearchUsers returns a page of the users matching filter, newest first,
+// and how many match in all; admin only
+func (s *UserService) SearchUsers(ctx context.Context, callerID primitive.ObjectID, filter repo.UserFilter) (_ []models.User, _ int64, err error) {
+	ctx, span := startSpan(ctx, "UserService.SearchUsers")
+	defer endSpan(span, &err)
+
+	if err := requireAdmin(ctx, s.userRepo, callerID); err != nil {
+		return nil, 0, err
+	}
+
+	switch filter.Status {
+	case "", models.UserStatusActive, models.UserStatusClosed:
+	default:
+		return nil, 0, invalid("status", "status must be active or closed")
+	}
+
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		return nil, 0, invalid("createdFrom", "createdFrom must be before createdTo")
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.Limit < 1 {
+		filter.Limit = defaultUserPageSize
+	}
+	if filter.Limit > maxUserPageSize {
+		filter.Limit = maxUserPageSize
+	}
+
+	return s.userRepo.SearchUsers(ctx, filter)
+}
+
+// SetRole grants a user the admin role or takes it away; admin only
+func (s *UserService) SetRole(ctx context.Context, userID primitive.ObjectID, role string) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.SetRole")
+	defer endSpan(span, &err)
+
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return nil, invalid("role", "role must be user or admin")
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	if user.Closed() {
+		return nil, ErrAccountClosed
+	}
+
+	if err := s.userRepo.SetRole(ctx, userID, role); err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	before := roleSnapshot{Role: models.RoleUser}
+	if user.Role != "" {
+		before.Role = user.Role
+	}
+	user.Role = role
+
+	s.logger.InfoContext(ctx, "user role changed", "user_id", userID.Hex(), "role", role)
+
+	s.audit.Record(ctx, models.AuditRoleChanged, models.AuditTargetUser, userID.Hex(), before, roleSnapshot{Role: role})
+
+	return user, nil
+}
+
+// roleSnapshot is the state a role change is audited with
+type roleSnapshot struct {
+	Role string `json:"role"`
+}
+
+// requireAdmin checks the caller has the admin role and an open account
+func requireAdmin(ctx context.Context, users repo.UserRepository, callerID primitive.ObjectID) error {
+	caller, err := users.GetUserByID(ctx, callerID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrAdminRequired
+	}
+	if err != nil {
+		return err
+	}
+	if !caller.IsAdmin() || caller.Closed() {
+		return ErrAdminRequired
+	}
+	return nil
+}
+
+// requireSelfOrAdmin lets callers at their own user's data; anyone else's
+// needs the admin role
+func requireSelfOrAdmin(ctx context.Context, users repo.UserRepository, callerID, userID primitive.ObjectID) error {
+	if callerID == userID {
+		return nil
+	}
+	return requireAdmin(ctx, users, callerID)
 }
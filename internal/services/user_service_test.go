@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"concurrent-wallet-order-system/internal/logging"
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// clock is a fake time source for the security service
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newLoginEnv(t *testing.T) (*testEnv, *clock, *models.User) {
+	t.Helper()
+
+	env := newTestEnv(t)
+	clk := &clock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	env.securityService.now = clk.now
+
+	user, err := env.userService.Register(t.Context(), "Alice", "alice@example.com", "correct-horse")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	return env, clk, user
+}
+
+// retryAfterOf returns the error's retry delay, failing unless it wraps want
+func retryAfterOf(t *testing.T, err, want error) time.Duration {
+	t.Helper()
+
+	var retryErr *RetryError
+	if !errors.Is(err, want) || !errors.As(err, &retryErr) {
+		t.Fatalf("err = %v, want %v with a retry delay", err, want)
+	}
+	return retryErr.RetryAfter
+}
+
+func TestLoginFailuresDelayNextAttempt(t *testing.T) {
+	env, clk, _ := newLoginEnv(t)
+
+	_, err := env.userService.Login(t.Context(), "alice@example.com", "wrong", "10.0.0.1")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+
+	_, err = env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.1")
+	if d := retryAfterOf(t, err, ErrLoginThrottled); d != time.Second {
+		t.Errorf("retry after = %v, want 1s", d)
+	}
+
+	clk.advance(time.Second)
+	env.userService.Login(t.Context(), "Alice@Example.com", "wrong", "10.0.0.1")
+
+	_, err = env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.1")
+	if d := retryAfterOf(t, err, ErrLoginThrottled); d != 2*time.Second {
+		t.Errorf("retry after = %v, want 2s after the second failure", d)
+	}
+
+	clk.advance(2 * time.Second)
+	if _, err := env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.1"); err != nil {
+		t.Fatalf("login after the delay: %v", err)
+	}
+
+	// A success forgets the failures
+	env.userService.Login(t.Context(), "alice@example.com", "wrong", "10.0.0.1")
+	clk.advance(time.Second)
+	if _, err := env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.1"); err != nil {
+		t.Fatalf("login after one new failure: %v", err)
+	}
+}
+
+func TestLoginLocksAccountAndAdminUnlocks(t *testing.T) {
+	env, clk, user := newLoginEnv(t)
+
+	var err error
+	for range testLoginPolicy.MaxFailures {
+		_, err = env.userService.Login(t.Context(), "alice@example.com", "wrong", "10.0.0.1")
+		clk.advance(testLoginPolicy.MaxDelay)
+	}
+	if d := retryAfterOf(t, err, ErrAccountLocked); d != testLoginPolicy.Lockout {
+		t.Errorf("retry after = %v, want the lockout of %v", d, testLoginPolicy.Lockout)
+	}
+
+	// The right password does not get through a lockout, from any IP
+	_, err = env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.2")
+	retryAfterOf(t, err, ErrAccountLocked)
+
+	events, err := env.securityService.ListEvents(t.Context(), repo.SecurityEventFilter{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != models.SecurityEventAccountLocked || events[0].IP != "10.0.0.1" {
+		t.Fatalf("events = %+v, want one ACCOUNT_LOCKED", events)
+	}
+
+	if err := env.userService.UnlockUser(t.Context(), user.ID); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if _, err := env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.2"); err != nil {
+		t.Fatalf("login after unlock: %v", err)
+	}
+
+	events, _ = env.securityService.ListEvents(t.Context(), repo.SecurityEventFilter{Type: models.SecurityEventAccountUnlocked})
+	if len(events) != 1 || events[0].UserID == nil || *events[0].UserID != user.ID {
+		t.Errorf("events = %+v, want one ACCOUNT_UNLOCKED", events)
+	}
+}
+
+func TestLoginLockoutExpires(t *testing.T) {
+	env, clk, _ := newLoginEnv(t)
+
+	for range testLoginPolicy.MaxFailures {
+		env.userService.Login(t.Context(), "alice@example.com", "wrong", "10.0.0.1")
+		clk.advance(testLoginPolicy.MaxDelay)
+	}
+
+	clk.advance(testLoginPolicy.Lockout)
+	if _, err := env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.1"); err != nil {
+		t.Fatalf("login after the lockout: %v", err)
+	}
+}
+
+func TestLoginBlocksIPAcrossAccounts(t *testing.T) {
+	env, clk, _ := newLoginEnv(t)
+
+	// Unknown emails count against the IP like real ones
+	for i := range testLoginPolicy.MaxIPFailures {
+		email := string(rune('a'+i)) + "@example.com"
+		if _, err := env.userService.Login(t.Context(), email, "guess", "10.0.0.9"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i, err)
+		}
+	}
+
+	_, err := env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.9")
+	retryAfterOf(t, err, ErrLoginBlocked)
+
+	// Other clients are unaffected
+	if _, err := env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.1"); err != nil {
+		t.Fatalf("login from another IP: %v", err)
+	}
+
+	clk.advance(testLoginPolicy.Lockout)
+	if _, err := env.userService.Login(t.Context(), "alice@example.com", "correct-horse", "10.0.0.9"); err != nil {
+		t.Fatalf("login after the IP lockout: %v", err)
+	}
+}
+
+// newAdmin creates a user with the admin role
+func (e *testEnv) newAdmin(t *testing.T) primitive.ObjectID {
+	t.Helper()
+
+	adminID := e.createUser(t, 0)
+	if _, err := e.userService.SetRole(t.Context(), adminID, models.RoleAdmin); err != nil {
+		t.Fatalf("grant admin: %v", err)
+	}
+	return adminID
+}
+
+func TestGetUserOnlyOwnUnlessAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	alice := env.createUser(t, 0)
+	bob := env.createUser(t, 0)
+
+	if _, err := env.userService.GetUser(t.Context(), alice, alice); err != nil {
+		t.Fatalf("own profile: %v", err)
+	}
+	if _, err := env.userService.GetUser(t.Context(), alice, bob); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("other profile: err = %v, want ErrAdminRequired", err)
+	}
+
+	admin := env.newAdmin(t)
+	if _, err := env.userService.GetUser(t.Context(), admin, bob); err != nil {
+		t.Errorf("admin reading a profile: %v", err)
+	}
+
+	// Revoking the role takes the access away
+	if _, err := env.userService.SetRole(t.Context(), admin, models.RoleUser); err != nil {
+		t.Fatalf("revoke admin: %v", err)
+	}
+	if _, err := env.userService.GetUser(t.Context(), admin, bob); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("after revoking: err = %v, want ErrAdminRequired", err)
+	}
+
+	if _, err := env.userService.SetRole(t.Context(), bob, "root"); !errors.As(err, new(*ValidationError)) {
+		t.Errorf("unknown role: err = %v, want a validation error", err)
+	}
+}
+
+func TestWalletAndPortfolioOnlyOwnUnlessAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	portfolios := NewPortfolioService(env.users, env.portfolio, env.stockService, logging.Discard())
+	alice := env.createUser(t, 100)
+	bob := env.createUser(t, 100)
+	admin := env.newAdmin(t)
+
+	reads := map[string]func(callerID, userID primitive.ObjectID) error{
+		"balance": func(callerID, userID primitive.ObjectID) error {
+			_, err := env.walletService.GetBalance(t.Context(), callerID, userID)
+			return err
+		},
+		"history": func(callerID, userID primitive.ObjectID) error {
+			_, err := env.walletService.GetHistory(t.Context(), callerID, userID)
+			return err
+		},
+		"portfolio": func(callerID, userID primitive.ObjectID) error {
+			_, err := portfolios.GetPortfolio(t.Context(), callerID, userID)
+			return err
+		},
+	}
+
+	for name, read := range reads {
+		t.Run(name, func(t *testing.T) {
+			if err := read(alice, alice); err != nil {
+				t.Errorf("own: %v", err)
+			}
+			if err := read(alice, bob); !errors.Is(err, ErrAdminRequired) {
+				t.Errorf("other user's: err = %v, want ErrAdminRequired", err)
+			}
+			if err := read(admin, bob); err != nil {
+				t.Errorf("admin: %v", err)
+			}
+		})
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.newAdmin(t)
+
+	var ids []primitive.ObjectID
+	for _, name := range []string{"Ada Lovelace", "Alan Turing", "Grace Hopper"} {
+		user := &models.User{Name: name, Email: strings.ToLower(strings.Fields(name)[1]) + "@example.com"}
+		if err := env.users.CreateUser(t.Context(), user); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		ids = append(ids, user.ID)
+	}
+	if err := env.users.CloseUser(t.Context(), ids[2], time.Now()); err != nil {
+		t.Fatalf("close user: %v", err)
+	}
+
+	if _, _, err := env.userService.SearchUsers(t.Context(), ids[0], repo.UserFilter{}); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("search as a user: err = %v, want ErrAdminRequired", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter repo.UserFilter
+		want   []primitive.ObjectID
+		total  int64
+	}{
+		{"name prefix", repo.UserFilter{Name: "a"}, []primitive.ObjectID{ids[1], ids[0]}, 2},
+		{"email prefix", repo.UserFilter{Email: "HOP"}, []primitive.ObjectID{ids[2]}, 1},
+		{"closed", repo.UserFilter{Status: models.UserStatusClosed}, []primitive.ObjectID{ids[2]}, 1},
+		{"paged, newest first", repo.UserFilter{Status: models.UserStatusActive, Page: 2, Limit: 1}, []primitive.ObjectID{ids[0]}, 3},
+		{"created before", repo.UserFilter{CreatedTo: time.Now().Add(-time.Hour)}, nil, 0},
+		{"created since", repo.UserFilter{Name: "grace", CreatedFrom: time.Now().Add(-time.Hour)}, []primitive.ObjectID{ids[2]}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := env.userService.SearchUsers(t.Context(), admin, tt.filter)
+			if err != nil {
+				t.Fatalf("search: %v", err)
+			}
+			var got []primitive.ObjectID
+			for _, u := range users {
+				got = append(got, u.ID)
+			}
+			if !slices.Equal(got, tt.want) || total != tt.total {
+				t.Errorf("got %v of %d, want %v of %d", got, total, tt.want, tt.total)
+			}
+		})
+	}
+
+	if _, _, err := env.userService.SearchUsers(t.Context(), admin, repo.UserFilter{Status: "deleted"}); !errors.As(err, new(*ValidationError)) {
+		t.Errorf("unknown status: err = %v, want a validation error", err)
+	}
+}
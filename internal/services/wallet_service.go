@@ -1,101 +1,337 @@
 package services
 
 import (
-	"errors"
+	"context"
+	"log/slog"
 	"sync"
+	"time"
 
+	"concurrent-wallet-order-system/internal/metrics"
 	"concurrent-wallet-order-system/internal/models"
 	"concurrent-wallet-order-system/internal/repo"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type WalletService struct {
-	userRepo   *repo.UserRepository
-	walletRepo *repo.WalletRepository
-	mu         sync.Mutex
+	userRepo     repo.UserRepository
+	walletRepo   repo.WalletRepository
+	twoFactor    *TwoFactorService
+	kyc          *KYCService
+	audit        *AuditService
+	stepUpAmount float64
+	logger       *slog.Logger
+	mu           sync.Mutex
 }
 
+// NewWalletService takes the amount above which a withdrawal needs step-up
+// verification with a second factor. Deposits and withdrawals count against
+// the daily limits of the user's KYC tier. Every balance change, trades'
+// included, is written to the audit log.
 func NewWalletService(
-	userRepo *repo.UserRepository,
-	walletRepo *repo.WalletRepository,
+	userRepo repo.UserRepository,
+	walletRepo repo.WalletRepository,
+	twoFactor *TwoFactorService,
+	kyc *KYCService,
+	audit *AuditService,
+	stepUpAmount float64,
+	logger *slog.Logger,
 ) *WalletService {
 	return &WalletService{
-		userRepo:   userRepo,
-		walletRepo: walletRepo,
+		userRepo:     userRepo,
+		walletRepo:   walletRepo,
+		twoFactor:    twoFactor,
+		kyc:          kyc,
+		audit:        audit,
+		stepUpAmount: stepUpAmount,
+		logger:       logger,
 	}
 }
 
-func (s *WalletService) Deposit(userID primitive.ObjectID, amount float64) error {
+func (s *WalletService) Deposit(ctx context.Context, userID primitive.ObjectID, amount float64) (err error) {
+	ctx, span := startSpan(ctx, "WalletService.Deposit",
+		attribute.String("user.id", userID.Hex()),
+		attribute.Float64("wallet.amount", amount),
+	)
+	defer endSpan(span, &err)
+
+	err = s.credit(ctx, userID, amount, depositChange)
+	s.record(ctx, "deposit", userID, amount, err)
+	return err
+}
+
+// Withdraw takes from a balance. Only users with a verified email can
+// withdraw, and above the step-up amount code must be a TOTP or backup code
+// of their two-factor authentication. A wrong code counts against the user's
+// login lockout, so userID must be the caller's own, authenticated user.
+func (s *WalletService) Withdraw(ctx context.Context, userID primitive.ObjectID, amount float64, code string) (err error) {
+	ctx, span := startSpan(ctx, "WalletService.Withdraw",
+		attribute.String("user.id", userID.Hex()),
+		attribute.Float64("wallet.amount", amount),
+	)
+	defer endSpan(span, &err)
+
+	err = s.checkVerified(ctx, userID)
+	if err == nil && amount > s.stepUpAmount {
+		err = s.twoFactor.VerifyStepUp(ctx, userID, code)
+	}
+	if err == nil {
+		err = s.debit(ctx, userID, amount, withdrawalChange)
+	}
+	s.record(ctx, "withdraw", userID, amount, err)
+	return err
+}
+
+func (s *WalletService) checkVerified(ctx context.Context, userID primitive.ObjectID) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// walletChange says how a balance change is recorded: its method in the
+// wallet history, the daily limit it counts against and its audit action.
+// An empty limit or action means none.
+type walletChange struct {
+	method string
+	limit  string
+	action string
+}
+
+var (
+	depositChange    = walletChange{method: "deposit", limit: models.LimitDeposit, action: models.AuditWalletDeposit}
+	withdrawalChange = walletChange{method: "withdraw", limit: models.LimitWithdrawal, action: models.AuditWalletWithdrawal}
+
+	// Trades count against the order limit instead, and their order is
+	// what gets audited
+	tradeChange = walletChange{method: "trade"}
+
+	// The reversal of a trade whose order failed
+	reversalChange = walletChange{method: "reversal"}
+)
+
+// credit adds to a balance, recorded as change says. Orders use it directly
+// so that trade proceeds are not counted or recorded as deposits.
+func (s *WalletService) credit(ctx context.Context, userID primitive.ObjectID, amount float64, change walletChange) (err error) {
+	ctx, span := startSpan(ctx, "WalletService.credit",
+		attribute.String("user.id", userID.Hex()),
+		attribute.Float64("wallet.amount", amount),
+	)
+	defer endSpan(span, &err)
+
 	if amount <= 0 {
-		return errors.New("amount must be greater than zero")
+		return invalid("amount", "amount must be greater than zero")
 	}
 
-	s.mu.Lock()
+	s.lock(ctx)
 	defer s.mu.Unlock()
 
-	user, err := s.userRepo.GetUserByID(userID)
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if user.Closed() {
+		return ErrAccountClosed
+	}
+
+	release, err := s.reserve(ctx, user, change.limit, amount)
 	if err != nil {
 		return err
 	}
 
 	newBalance := user.WalletBalance + amount
 
-	err = s.userRepo.UpdateWalletBalance(userID, newBalance)
+	err = s.userRepo.UpdateWalletBalance(ctx, userID, newBalance)
 	if err != nil {
+		release()
 		return err
 	}
 
+	// The balance has changed, so record it even if the client goes away
+	ctx = context.WithoutCancel(ctx)
+
 	tx := &models.WalletTransaction{
 		UserID: userID,
-		Method: "deposit",
+		Method: change.method,
 		Amount: amount,
 	}
 
-	return s.walletRepo.InsertTransaction(tx)
+	if err := s.walletRepo.InsertTransaction(ctx, tx); err != nil {
+		return err
+	}
+
+	if change.action != "" {
+		s.audit.Record(ctx, change.action, models.AuditTargetUser, userID.Hex(),
+			balanceSnapshot{user.WalletBalance}, balanceSnapshot{newBalance})
+	}
+	return nil
 }
-func (s *WalletService) Withdraw(userID primitive.ObjectID, amount float64) error {
+
+// debit takes from a balance, refusing to overdraw it, recorded as change
+// says
+func (s *WalletService) debit(ctx context.Context, userID primitive.ObjectID, amount float64, change walletChange) (err error) {
+	ctx, span := startSpan(ctx, "WalletService.debit",
+		attribute.String("user.id", userID.Hex()),
+		attribute.Float64("wallet.amount", amount),
+	)
+	defer endSpan(span, &err)
+
 	if amount <= 0 {
-		return errors.New("amount must be greater than zero")
+		return invalid("amount", "amount must be greater than zero")
 	}
 
-	s.mu.Lock()
+	s.lock(ctx)
 	defer s.mu.Unlock()
 
-	user, err := s.userRepo.GetUserByID(userID)
+	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
-		return err
+		return notFound(err, ErrUserNotFound)
+	}
+	if user.Closed() {
+		return ErrAccountClosed
 	}
 
 	if user.WalletBalance < amount {
-		return errors.New("insufficient balance")
+		return ErrInsufficientBalance
+	}
+
+	release, err := s.reserve(ctx, user, change.limit, amount)
+	if err != nil {
+		return err
 	}
 
 	newBalance := user.WalletBalance - amount
 
-	err = s.userRepo.UpdateWalletBalance(userID, newBalance)
+	err = s.userRepo.UpdateWalletBalance(ctx, userID, newBalance)
 	if err != nil {
+		release()
 		return err
 	}
 
+	// The balance has changed, so record it even if the client goes away
+	ctx = context.WithoutCancel(ctx)
+
 	tx := &models.WalletTransaction{
 		UserID: userID,
-		Method: "withdraw",
+		Method: change.method,
 		Amount: amount,
 	}
 
-	return s.walletRepo.InsertTransaction(tx)
+	if err := s.walletRepo.InsertTransaction(ctx, tx); err != nil {
+		return err
+	}
+
+	if change.action != "" {
+		s.audit.Record(ctx, change.action, models.AuditTargetUser, userID.Hex(),
+			balanceSnapshot{user.WalletBalance}, balanceSnapshot{newBalance})
+	}
+	return nil
 }
 
-func (s *WalletService) GetBalance(userID primitive.ObjectID) (float64, error) {
-	user, err := s.userRepo.GetUserByID(userID)
-	if err != nil {
+// settle pays amount once per key, however often it is called, so that a
+// failed settlement can be retried without paying twice. It reports whether
+// this call paid. The order the payment settles is what gets audited.
+func (s *WalletService) settle(ctx context.Context, userID primitive.ObjectID, key string, amount float64) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "WalletService.settle",
+		attribute.String("user.id", userID.Hex()),
+		attribute.Float64("wallet.amount", amount),
+	)
+	defer endSpan(span, &err)
+
+	s.lock(ctx)
+	defer s.mu.Unlock()
+
+	paid, err := s.userRepo.CreditOnce(ctx, userID, key, amount)
+	if err != nil || !paid {
+		return false, err
+	}
+
+	// The balance has changed, so record it even if the client goes away
+	ctx = context.WithoutCancel(ctx)
+
+	tx := &models.WalletTransaction{
+		UserID: userID,
+		Method: "delisting",
+		Amount: amount,
+	}
+
+	// A retry would not pay again, so would not record it either
+	if err := s.walletRepo.InsertTransaction(ctx, tx); err != nil {
+		s.logger.ErrorContext(ctx, "settlement paid but not in wallet history", "user_id", userID.Hex(), "key", key, "amount", amount, "error", err)
+	}
+	return true, nil
+}
+
+// balanceSnapshot is the state a wallet change is audited with
+type balanceSnapshot struct {
+	WalletBalance float64 `json:"walletbalance"`
+}
+
+// reserve counts amount against the user's daily limit of kind; an empty
+// kind counts nothing
+func (s *WalletService) reserve(ctx context.Context, user *models.User, kind string, amount float64) (func(), error) {
+	if kind == "" {
+		return func() {}, nil
+	}
+	return s.kyc.reserve(ctx, user, kind, amount)
+}
+
+// record counts and logs the result of a wallet API operation
+func (s *WalletService) record(ctx context.Context, operation string, userID primitive.ObjectID, amount float64, err error) {
+	result := outcome(err)
+	metrics.WalletOperations.WithLabelValues(operation, result).Inc()
+
+	switch result {
+	case metrics.OutcomeSuccess:
+		s.logger.InfoContext(ctx, "wallet "+operation, "user_id", userID.Hex(), "amount", amount)
+	case metrics.OutcomeRejected:
+		s.logger.InfoContext(ctx, "wallet "+operation+" rejected", "user_id", userID.Hex(), "amount", amount, "reason", err.Error())
+	default:
+		s.logger.ErrorContext(ctx, "wallet "+operation+" failed", "user_id", userID.Hex(), "amount", amount, "error", err)
+	}
+}
+
+// lock acquires mu, recording how long it waited
+func (s *WalletService) lock(ctx context.Context) {
+	_, span := startSpan(ctx, "WalletService.mu wait")
+	start := time.Now()
+	s.mu.Lock()
+	span.End()
+	metrics.ObserveLockWait("WalletService.mu", start)
+}
+
+// GetBalance returns the user's balance. Anyone but the user needs the admin
+// role.
+func (s *WalletService) GetBalance(ctx context.Context, callerID, userID primitive.ObjectID) (_ float64, err error) {
+	ctx, span := startSpan(ctx, "WalletService.GetBalance")
+	defer endSpan(span, &err)
+
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, userID); err != nil {
 		return 0, err
 	}
 
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, notFound(err, ErrUserNotFound)
+	}
+
 	return user.WalletBalance, nil
 }
 
-func (s *WalletService) GetHistory(userID primitive.ObjectID) ([]models.WalletTransaction, error) {
-	return s.walletRepo.GetTransactionsByUser(userID)
+// GetHistory lists the user's wallet transactions. Anyone but the user needs
+// the admin role.
+func (s *WalletService) GetHistory(ctx context.Context, callerID, userID primitive.ObjectID) (_ []models.WalletTransaction, err error) {
+	ctx, span := startSpan(ctx, "WalletService.GetHistory")
+	defer endSpan(span, &err)
+
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.walletRepo.GetTransactionsByUser(ctx, userID)
 }
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDepositIncreasesBalanceAndRecordsTransaction(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 0)
+
+	if err := env.walletService.Deposit(t.Context(), userID, 150.5); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+
+	if got := env.balance(t, userID); got != 150.5 {
+		t.Errorf("balance = %v, want 150.5", got)
+	}
+
+	history, err := env.walletService.GetHistory(t.Context(), userID, userID)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+
+	if len(history) != 1 || history[0].Method != "deposit" || history[0].Amount != 150.5 {
+		t.Errorf("history = %+v, want one deposit of 150.5", history)
+	}
+}
+
+func TestWithdrawDecreasesBalance(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 100)
+
+	if err := env.walletService.Withdraw(t.Context(), userID, 40, ""); err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+
+	if got := env.balance(t, userID); got != 60 {
+		t.Errorf("balance = %v, want 60", got)
+	}
+}
+
+func TestWithdrawInsufficientBalance(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 100)
+
+	err := env.walletService.Withdraw(t.Context(), userID, 100.01, "")
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("err = %v, want ErrInsufficientBalance", err)
+	}
+
+	if got := env.balance(t, userID); got != 100 {
+		t.Errorf("balance = %v, want 100 after failed withdraw", got)
+	}
+}
+
+func TestWalletRejectsNonPositiveAmounts(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 100)
+
+	for _, amount := range []float64{0, -1} {
+		var validationErr *ValidationError
+		if err := env.walletService.Deposit(t.Context(), userID, amount); !errors.As(err, &validationErr) || validationErr.Field != "amount" {
+			t.Errorf("Deposit(%v) err = %v, want amount validation error", amount, err)
+		}
+		if err := env.walletService.Withdraw(t.Context(), userID, amount, ""); err == nil {
+			t.Errorf("Withdraw(%v) succeeded, want error", amount)
+		}
+	}
+
+	if got := env.balance(t, userID); got != 100 {
+		t.Errorf("balance = %v, want 100", got)
+	}
+}
+
+func TestWalletUnknownUser(t *testing.T) {
+	env := newTestEnv(t)
+
+	if err := env.walletService.Deposit(t.Context(), primitive.NewObjectID(), 10); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestConcurrentDepositsAreNotLost(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 0)
+
+	const workers = 100
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := env.walletService.Deposit(t.Context(), userID, 10); err != nil {
+				t.Errorf("deposit: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := env.balance(t, userID); got != workers*10 {
+		t.Errorf("balance = %v, want %v", got, workers*10)
+	}
+}
+
+func TestConcurrentWithdrawalsNeverOverdraw(t *testing.T) {
+	env := newTestEnv(t)
+	userID := env.createUser(t, 100)
+
+	const workers = 50
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := env.walletService.Withdraw(t.Context(), userID, 10, ""); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 10 {
+		t.Errorf("%d withdrawals succeeded, want 10", succeeded)
+	}
+
+	if got := env.balance(t, userID); got != 0 {
+		t.Errorf("balance = %v, want 0", got)
+	}
+}
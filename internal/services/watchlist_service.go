@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"concurrent-wallet-order-system/internal/models"
+	"concurrent-wallet-order-system/internal/repo"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxWatchlistsPerUser   = 20
+	maxSymbolsPerWatchlist = 50
+	maxWatchlistNameLength = 50
+)
+
+type WatchlistService struct {
+	userRepo      repo.UserRepository
+	watchlistRepo repo.WatchlistRepository
+	stockService  *StockService
+	logger        *slog.Logger
+}
+
+// NewWatchlistService takes the user repository, since watchlists are only
+// their owner's unless the caller is an admin.
+func NewWatchlistService(
+	userRepo repo.UserRepository,
+	watchlistRepo repo.WatchlistRepository,
+	stockService *StockService,
+	logger *slog.Logger,
+) *WatchlistService {
+	return &WatchlistService{
+		userRepo:      userRepo,
+		watchlistRepo: watchlistRepo,
+		stockService:  stockService,
+		logger:        logger,
+	}
+}
+
+type WatchlistItem struct {
+	Symbol           string  `json:"symbol"`
+	StockName        string  `json:"stockName"`
+	CurrentPrice     float64 `json:"currentPrice"`
+	DayChange        float64 `json:"dayChange"`
+	DayChangePercent float64 `json:"dayChangePercent"`
+	Status           string  `json:"status"`
+}
+
+type WatchlistResponse struct {
+	ID        primitive.ObjectID `json:"id"`
+	UserID    primitive.ObjectID `json:"userId"`
+	Name      string             `json:"name"`
+	Items     []WatchlistItem    `json:"items"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+func (s *WatchlistService) CreateWatchlist(ctx context.Context, userID primitive.ObjectID, name string, symbols []string) (_ *models.Watchlist, err error) {
+	ctx, span := startSpan(ctx, "WatchlistService.CreateWatchlist")
+	defer endSpan(span, &err)
+
+	name, err = validateWatchlistName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols, err = s.normalizeSymbols(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.watchlistRepo.CountWatchlistsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if count >= maxWatchlistsPerUser {
+		return nil, ErrWatchlistLimit
+	}
+
+	watchlist := &models.Watchlist{
+		UserID:  userID,
+		Name:    name,
+		Symbols: symbols,
+	}
+
+	err = s.watchlistRepo.CreateWatchlist(ctx, watchlist)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrWatchlistNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "watchlist created", "watchlist_id", watchlist.ID.Hex(), "user_id", userID.Hex(), "symbols", len(symbols))
+	return watchlist, nil
+}
+
+func (s *WatchlistService) GetUserWatchlists(ctx context.Context, callerID, userID primitive.ObjectID) (_ []models.Watchlist, err error) {
+	ctx, span := startSpan(ctx, "WatchlistService.GetUserWatchlists")
+	defer endSpan(span, &err)
+
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.watchlistRepo.GetWatchlistsByUser(ctx, userID)
+}
+
+// GetWatchlist returns a watchlist with live prices and the change since the
+// previous close for each symbol
+func (s *WatchlistService) GetWatchlist(ctx context.Context, callerID, id primitive.ObjectID) (_ *WatchlistResponse, err error) {
+	ctx, span := startSpan(ctx, "WatchlistService.GetWatchlist")
+	defer endSpan(span, &err)
+
+	watchlist, err := s.owned(ctx, callerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes, err := s.watchlistRepo.GetWatchlistQuotes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &WatchlistResponse{
+		ID:        watchlist.ID,
+		UserID:    watchlist.UserID,
+		Name:      watchlist.Name,
+		Items:     make([]WatchlistItem, 0, len(quotes)),
+		CreatedAt: watchlist.CreatedAt,
+		UpdatedAt: watchlist.UpdatedAt,
+	}
+
+	for _, q := range quotes {
+		item := WatchlistItem{
+			Symbol:       q.Symbol,
+			StockName:    q.Name,
+			CurrentPrice: q.Price,
+			Status:       q.Status,
+		}
+
+		if item.Status == "" && q.Name != "" {
+			item.Status = models.StockStatusActive
+		}
+
+		if q.PreviousClose > 0 {
+			item.DayChange = q.Price - q.PreviousClose
+			item.DayChangePercent = math.Round(item.DayChange/q.PreviousClose*10000) / 100
+		}
+
+		response.Items = append(response.Items, item)
+	}
+
+	return response, nil
+}
+
+func (s *WatchlistService) UpdateWatchlist(ctx context.Context, callerID, id primitive.ObjectID, name string, symbols []string) (_ *models.Watchlist, err error) {
+	ctx, span := startSpan(ctx, "WatchlistService.UpdateWatchlist")
+	defer endSpan(span, &err)
+
+	if _, err := s.owned(ctx, callerID, id); err != nil {
+		return nil, err
+	}
+
+	name, err = validateWatchlistName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols, err = s.normalizeSymbols(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.watchlistRepo.UpdateWatchlist(ctx, id, name, symbols)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrWatchlistNameTaken
+	}
+	if err != nil {
+		return nil, notFound(err, ErrWatchlistNotFound)
+	}
+
+	return s.watchlistRepo.GetWatchlist(ctx, id)
+}
+
+func (s *WatchlistService) AddSymbol(ctx context.Context, callerID, id primitive.ObjectID, symbol string) (_ *models.Watchlist, err error) {
+	ctx, span := startSpan(ctx, "WatchlistService.AddSymbol")
+	defer endSpan(span, &err)
+
+	watchlist, err := s.owned(ctx, callerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := s.normalizeSymbols(ctx, []string{symbol})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(watchlist.Symbols) >= maxSymbolsPerWatchlist && !slices.Contains(watchlist.Symbols, added[0]) {
+		return nil, ErrWatchlistFull
+	}
+
+	err = s.watchlistRepo.AddSymbol(ctx, id, added[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return s.watchlistRepo.GetWatchlist(ctx, id)
+}
+
+func (s *WatchlistService) RemoveSymbol(ctx context.Context, callerID, id primitive.ObjectID, symbol string) (_ *models.Watchlist, err error) {
+	ctx, span := startSpan(ctx, "WatchlistService.RemoveSymbol")
+	defer endSpan(span, &err)
+
+	if _, err := s.owned(ctx, callerID, id); err != nil {
+		return nil, err
+	}
+
+	err = s.watchlistRepo.RemoveSymbol(ctx, id, strings.ToUpper(strings.TrimSpace(symbol)))
+	if err != nil {
+		return nil, notFound(err, ErrWatchlistNotFound)
+	}
+
+	return s.watchlistRepo.GetWatchlist(ctx, id)
+}
+
+func (s *WatchlistService) DeleteWatchlist(ctx context.Context, callerID, id primitive.ObjectID) (err error) {
+	ctx, span := startSpan(ctx, "WatchlistService.DeleteWatchlist")
+	defer endSpan(span, &err)
+
+	if _, err := s.owned(ctx, callerID, id); err != nil {
+		return err
+	}
+
+	err = s.watchlistRepo.DeleteWatchlist(ctx, id)
+	if err != nil {
+		return notFound(err, ErrWatchlistNotFound)
+	}
+
+	s.logger.InfoContext(ctx, "watchlist deleted", "watchlist_id", id.Hex())
+	return nil
+}
+
+// owned returns the watchlist if the caller owns it or is an admin
+func (s *WatchlistService) owned(ctx context.Context, callerID, id primitive.ObjectID) (*models.Watchlist, error) {
+	watchlist, err := s.watchlistRepo.GetWatchlist(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrWatchlistNotFound)
+	}
+	if err := requireSelfOrAdmin(ctx, s.userRepo, callerID, watchlist.UserID); err != nil {
+		return nil, err
+	}
+	return watchlist, nil
+}
+
+// normalizeSymbols upper-cases and de-duplicates symbols, keeping their order,
+// and checks that every one of them is a known stock
+func (s *WatchlistService) normalizeSymbols(ctx context.Context, symbols []string) ([]string, error) {
+
+	seen := map[string]bool{}
+	normalized := make([]string, 0, len(symbols))
+
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" {
+			return nil, invalid("symbols", "symbol cannot be empty")
+		}
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		normalized = append(normalized, symbol)
+	}
+
+	if len(normalized) > maxSymbolsPerWatchlist {
+		return nil, invalid("symbols", "too many symbols in watchlist")
+	}
+
+	if len(normalized) == 0 {
+		return normalized, nil
+	}
+
+	stocks, err := s.stockService.GetStocksBySymbols(ctx, normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, stock := range stocks {
+		known[stock.Symbol] = true
+	}
+
+	for _, symbol := range normalized {
+		if !known[symbol] {
+			return nil, withDetail(ErrStockNotFound, symbol)
+		}
+	}
+
+	return normalized, nil
+}
+
+func validateWatchlistName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return "", invalid("name", "watchlist name is required")
+	}
+
+	if len(name) > maxWatchlistNameLength {
+		return "", invalid("name", "watchlist name is too long")
+	}
+
+	return name, nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWatchlistsOnlyOwnUnlessAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	env.createStock(t, "AAPL", 100)
+	alice := env.createUser(t, 0)
+	bob := env.createUser(t, 0)
+	admin := env.newAdmin(t)
+
+	watchlist, err := env.watchlists.CreateWatchlist(t.Context(), alice, "Tech", []string{"aapl"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	id := watchlist.ID
+
+	if _, err := env.watchlists.GetUserWatchlists(t.Context(), bob, alice); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("list: err = %v, want ErrAdminRequired", err)
+	}
+	if _, err := env.watchlists.GetWatchlist(t.Context(), bob, id); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("get: err = %v, want ErrAdminRequired", err)
+	}
+	if _, err := env.watchlists.UpdateWatchlist(t.Context(), bob, id, "Mine", nil); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("update: err = %v, want ErrAdminRequired", err)
+	}
+	if _, err := env.watchlists.AddSymbol(t.Context(), bob, id, "AAPL"); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("add symbol: err = %v, want ErrAdminRequired", err)
+	}
+	if _, err := env.watchlists.RemoveSymbol(t.Context(), bob, id, "AAPL"); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("remove symbol: err = %v, want ErrAdminRequired", err)
+	}
+	if err := env.watchlists.DeleteWatchlist(t.Context(), bob, id); !errors.Is(err, ErrAdminRequired) {
+		t.Errorf("delete: err = %v, want ErrAdminRequired", err)
+	}
+
+	// Nothing changed, and the owner and admins still get at it
+	got, err := env.watchlists.GetWatchlist(t.Context(), alice, id)
+	if err != nil {
+		t.Fatalf("get own: %v", err)
+	}
+	if got.Name != "Tech" || len(got.Items) != 1 {
+		t.Errorf("watchlist = %+v, want Tech with AAPL", got)
+	}
+	if _, err := env.watchlists.GetUserWatchlists(t.Context(), admin, alice); err != nil {
+		t.Errorf("admin list: %v", err)
+	}
+	if err := env.watchlists.DeleteWatchlist(t.Context(), admin, id); err != nil {
+		t.Errorf("admin delete: %v", err)
+	}
+}
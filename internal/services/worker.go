@@ -0,0 +1,12 @@
+package services
+
+import "time"
+
+// WorkerStatus describes a background worker for the health endpoints
+type WorkerStatus struct {
+	Running        bool       `json:"running"`
+	Queued         int        `json:"queued"`
+	Processed      int64      `json:"processed"`
+	Failures       int64      `json:"failures"`
+	LastActivityAt *time.Time `json:"lastActivityAt,omitempty"`
+}
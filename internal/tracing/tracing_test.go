@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"concurrent-wallet-order-system/internal/config"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestFileExporter(t *testing.T) {
+	cfg := config.Default().Tracing
+	cfg.Exporter = "file"
+	cfg.File = filepath.Join(t.TempDir(), "traces.jsonl")
+
+	shutdown, err := Setup(t.Context(), cfg)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(t.Context(), "OrderService.Buy")
+	span.End()
+
+	if err := shutdown(t.Context()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Name":"OrderService.Buy"`, `"Value":"wallet-order-system"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("trace file does not contain %s:\n%s", want, data)
+		}
+	}
+}